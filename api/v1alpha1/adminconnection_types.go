@@ -25,7 +25,9 @@ import (
 	gormmysql "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"log"
@@ -33,7 +35,6 @@ import (
 	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -55,6 +56,82 @@ type AdminConnectionSpec struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	AllowedNamespaces []string `json:"allowedNamespaces,omitEmpty"`
+	// Template for the schema name of each Database, e.g. {{.Namespace}}_{{.Name}} where .Name is spec.name.
+	// Names beyond 64 characters are shortened with a hash suffix.
+	// +kubebuilder:validation:Optional
+	DatabaseNameTemplate string `json:"databaseNameTemplate,omitempty"`
+	// Template for the user name of each DatabaseUser, e.g. {{.Namespace}}_{{.Name}} where .Name is spec.username.
+	// Names beyond 32 characters are shortened with a hash suffix.
+	// +kubebuilder:validation:Optional
+	UsernameTemplate string `json:"usernameTemplate,omitempty"`
+	// Character set written into Databases which do not specify one, in place of the server default
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength:=64
+	DefaultCharacterSet string `json:"defaultCharacterSet,omitempty"`
+	// Collation written into Databases which do not specify one, used when it belongs to their character set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength:=64
+	DefaultCollation string `json:"defaultCollation,omitempty"`
+	// Defaults and caps applied to the quota of each Database
+	// +kubebuilder:validation:Optional
+	// +nullable
+	QuotaPolicy *QuotaPolicy `json:"quotaPolicy,omitempty"`
+	// Defaults and maximums applied to the resource limits of each DatabaseUser
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ResourceLimitPolicy *ResourceLimitPolicy `json:"resourceLimitPolicy,omitempty"`
+	// Secret in the namespace of the AdminConnection holding the CA key pair (tls.crt and tls.key) client
+	// certificates of DatabaseUsers are signed with
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ClientCertificateAuthority *v1.LocalObjectReference `json:"clientCertificateAuthority,omitempty"`
+	// Global privileges DatabaseUsers in the namespaces listed may request, none without a matching rule
+	// +kubebuilder:validation:Optional
+	// +nullable
+	GlobalPrivilegePolicy []GlobalPrivilegeRule `json:"globalPrivilegePolicy,omitempty"`
+}
+
+type GlobalPrivilegeRule struct {
+	// Namespaces the rule applies to, allowing a trailing '*' to match by prefix (e.g. monitoring-*)
+	// +kubebuilder:validation:MinItems:=1
+	Namespaces []string `json:"namespaces"`
+	// Privileges granted ON *.* they may request, e.g. PROCESS, REPLICATION CLIENT or SHOW DATABASES
+	// +kubebuilder:validation:MinItems:=1
+	Privileges []string `json:"privileges"`
+}
+
+const (
+	DefaultQuotaWarningPercent = 80
+)
+
+type QuotaPolicy struct {
+	// Quota for Databases which do not specify spec.quota.maxSize
+	// +kubebuilder:validation:Optional
+	// +nullable
+	DefaultMaxSize *resource.Quantity `json:"defaultMaxSize,omitempty"`
+	// Largest spec.quota.maxSize a Database may request
+	// +kubebuilder:validation:Optional
+	// +nullable
+	MaxSizeLimit *resource.Quantity `json:"maxSizeLimit,omitempty"`
+	// Percentage of the quota at which a warning is raised
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	WarningPercent *int32 `json:"warningPercent,omitempty"`
+	// Action for Databases which do not specify spec.quota.action
+	// +kubebuilder:validation:Optional
+	DefaultAction QuotaAction `json:"defaultAction,omitempty"`
+}
+
+type ResourceLimitPolicy struct {
+	// Limits for DatabaseUsers which do not specify them
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Defaults *ResourceLimits `json:"defaults,omitempty"`
+	// Largest limits a DatabaseUser may request, a user asking for unlimited is refused
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Maximums *ResourceLimits `json:"maximums,omitempty"`
 }
 
 // AdminConnectionStatus defines the observed state of AdminConnection
@@ -80,6 +157,15 @@ type AdminConnectionStatus struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	AvailableCharsets []Charset `json:"availableCharsets,omitEmpty"`
+	// The server version as reported by VERSION()
+	// +kubebuilder:validation:Optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// Whether a keyring plugin or component is active, required for encrypted databases
+	// +kubebuilder:validation:Optional
+	KeyringAvailable bool `json:"keyringAvailable,omitempty"`
+	// The value of event_scheduler, events only run when ON
+	// +kubebuilder:validation:Optional
+	EventScheduler string `json:"eventScheduler,omitempty"`
 }
 
 type Charset struct {
@@ -154,17 +240,24 @@ func GetAdminConnection(ctx context.Context, client client.Client, namespace str
 	return adminConnection, err
 }
 
-func (in *AdminConnection) getDbConfig(ctx context.Context, client client.Client) (mysql.Config, error) {
-	var err error
+// serverDbConfig The protocol and address settings shared by every connection to the server
+func (in *AdminConnection) serverDbConfig() mysql.Config {
 	var dbConfig mysql.Config
 
-	// Reading the admin connection details
 	dbConfig.Net = "tcp"
-	dbConfig.DBName = "mysql"
 	dbConfig.ParseTime = true
 	dbConfig.AllowNativePasswords = true
 	dbConfig.TLSConfig = "preferred"
 	dbConfig.Addr = in.Spec.Host + ":" + strconv.Itoa(int(in.Spec.Port))
+	return dbConfig
+}
+
+func (in *AdminConnection) getDbConfig(ctx context.Context, client client.Client) (mysql.Config, error) {
+	var err error
+
+	// Reading the admin connection details
+	dbConfig := in.serverDbConfig()
+	dbConfig.DBName = "mysql"
 	// Default the admin user to root if it was not specified by the definition
 	dbConfig.User = "root"
 	if in.Spec.AdminUser != nil {
@@ -236,6 +329,29 @@ func (in *AdminConnection) GetDatabaseConnection(ctx context.Context, client cli
 
 }
 
+// GetUserConnection A connection to the server logged in as another account with the schema as the default
+// database, limited to a single session. It is not cached, the caller closes it.
+func (in *AdminConnection) GetUserConnection(ctx context.Context, username string, password string,
+	schema string) (*sql.DB, error) {
+
+	dbConfig := in.serverDbConfig()
+	dbConfig.User = username
+	dbConfig.Passwd = password
+	dbConfig.DBName = schema
+
+	db, err := sql.Open("mysql", dbConfig.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	err = db.PingContext(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
 func (in *AdminConnection) createFreshConnection(ctx context.Context, dbConfig mysql.Config) (*gorm.DB, error) {
 
 	db, err := sql.Open("mysql", dbConfig.FormatDSN())
@@ -273,7 +389,8 @@ func (in *AdminConnection) createFreshConnection(ctx context.Context, dbConfig m
 
 	// Creating and switching to the control database.
 	in.switchDatabase(ctx, gormDB)
-	err = gormDB.AutoMigrate(&orm.ManagedDatabase{}, &orm.ManagedUser{})
+	err = gormDB.AutoMigrate(&orm.ManagedDatabase{}, &orm.ManagedUser{}, &orm.MigrationHistory{},
+		&orm.InitScriptRun{})
 	if err != nil {
 		newLogger.Error(ctx, "Failed to migrate content for AdminConnection")
 		defer func(db *sql.DB) {
@@ -308,18 +425,156 @@ func (in *AdminConnection) AllowedNamespace(namespace string) bool {
 		return true
 	}
 
-	for _, allowedNamespace := range in.Spec.AllowedNamespaces {
-		if allowedNamespace == namespace {
-			return true
+	return namespaceMatches(in.Spec.AllowedNamespaces, namespace)
+}
+
+// DisallowedGlobalPrivileges The global privileges given which no rule of the policy permits in the namespace.
+func (in *AdminConnection) DisallowedGlobalPrivileges(namespace string, privileges []string) []string {
+	allowed := make([]string, 0)
+	for _, rule := range in.Spec.GlobalPrivilegePolicy {
+		if namespaceMatches(rule.Namespaces, namespace) {
+			allowed = append(allowed, rule.Privileges...)
+		}
+	}
+	disallowed := make([]string, 0)
+	for _, privilege := range privileges {
+		if !containsFold(allowed, privilege) {
+			disallowed = append(disallowed, privilege)
+		}
+	}
+	return disallowed
+}
+
+// DatabaseName The schema name on the server for the Database, spec.name unless a template is set. Once named
+// the Database keeps the template recorded in its status, later template changes only apply to new databases.
+// An invalid template is reported by the AdminConnection and spec.name used meanwhile.
+func (in *AdminConnection) DatabaseName(database *Database) string {
+	template := in.Spec.DatabaseNameTemplate
+	if database.Status.Name != "" {
+		template = database.Status.NameTemplate
+	}
+	name, _ := ApplyNameTemplate(template, database.Namespace, database.Spec.Name, MaxDatabaseNameLength)
+	return name
+}
+
+// Username The user name on the server for the DatabaseUser, spec.username unless a template is set. Once named
+// the DatabaseUser keeps the template recorded in its status.
+func (in *AdminConnection) Username(user *DatabaseUser) string {
+	template := in.Spec.UsernameTemplate
+	if user.Status.Username != "" {
+		template = user.Status.UsernameTemplate
+	}
+	name, _ := ApplyNameTemplate(template, user.Namespace, user.Spec.Username, MaxUsernameLength)
+	return name
+}
+
+// ValidateNameTemplates Checks the name templates render.
+func (in *AdminConnection) ValidateNameTemplates() error {
+	if _, err := ApplyNameTemplate(in.Spec.DatabaseNameTemplate, in.Namespace, "name", MaxDatabaseNameLength); err != nil {
+		return fmt.Errorf("invalid databaseNameTemplate: %w", err)
+	}
+	if _, err := ApplyNameTemplate(in.Spec.UsernameTemplate, in.Namespace, "name", MaxUsernameLength); err != nil {
+		return fmt.Errorf("invalid usernameTemplate: %w", err)
+	}
+	return nil
+}
+
+// GetAvailableCharsets The character sets of the server with their collations
+func GetAvailableCharsets(db *gorm.DB) ([]Charset, error) {
+
+	var toReturn []Charset
+
+	query := "SHOW COLLATION WHERE Charset IS NOT NULL"
+	var results []map[string]interface{}
+	tx := db.Raw(query).Scan(&results)
+	if tx.Error != nil {
+		return toReturn, tx.Error
+	}
+
+	var collation, charset string
+	var isDefault bool
+	var sortedResults map[string][]Collation
+	sortedResults = make(map[string][]Collation)
+	for _, row := range results {
+		collation = row["Collation"].(string)
+		charset = row["Charset"].(string)
+		isDefault = row["Default"].(string) == "Yes"
+
+		if _, ok := sortedResults[charset]; !ok {
+			sortedResults[charset] = make([]Collation, 0)
+		}
+		sortedResults[charset] = append(sortedResults[charset], Collation{
+			Name:    collation,
+			Default: isDefault,
+		})
+	}
+
+	for charset, collations := range sortedResults {
+		toReturn = append(toReturn, Charset{
+			Name:       charset,
+			Collations: collations,
+		})
+	}
+
+	return toReturn, nil
+}
+
+// ResolveCharsetCollation Fills in whichever of the character set and collation is empty. The preferred
+// defaults in the spec are used over the server defaults, and a character set on its own takes the preferred
+// collation only when it belongs to that character set, otherwise the character set's default collation.
+func (in *AdminConnection) ResolveCharsetCollation(charset string, collation string) (string, string) {
+
+	if charset == "" && collation == "" {
+		charset = in.Spec.DefaultCharacterSet
+		if charset == "" && in.Spec.DefaultCollation != "" {
+			charset = in.Status.charsetOf(in.Spec.DefaultCollation)
+		}
+		if charset == "" {
+			return in.Status.CharacterSet, in.Status.Collation
 		}
-		if strings.HasSuffix(allowedNamespace, "*") {
-			if strings.HasPrefix(namespace, strings.TrimSuffix(allowedNamespace, "*")) {
-				return true
+	}
+
+	if charset == "" {
+		return in.Status.charsetOf(collation), collation
+	}
+
+	if collation == "" {
+		preferredCharset := in.Status.charsetOf(in.Spec.DefaultCollation)
+		if in.Spec.DefaultCollation != "" &&
+			(preferredCharset == charset || (preferredCharset == "" && charset == in.Spec.DefaultCharacterSet)) {
+			collation = in.Spec.DefaultCollation
+		} else {
+			collation = in.Status.defaultCollation(charset)
+		}
+	}
+	return charset, collation
+}
+
+// charsetOf The character set the collation belongs to, empty when not known
+func (in *AdminConnectionStatus) charsetOf(collation string) string {
+	for _, charset := range in.AvailableCharsets {
+		for _, entry := range charset.Collations {
+			if entry.Name == collation {
+				return charset.Name
 			}
 		}
 	}
+	return ""
+}
 
-	return false
+// defaultCollation The default collation of the character set, empty when not known
+func (in *AdminConnectionStatus) defaultCollation(charset string) string {
+	for _, available := range in.AvailableCharsets {
+		if available.Name != charset {
+			continue
+		}
+		for _, entry := range available.Collations {
+			if entry.Default {
+				return entry.Name
+			}
+		}
+	}
+	return ""
 }
 
 func (in *AdminConnection) DatabaseMine(gormDB *gorm.DB, database *Database) bool {
@@ -327,13 +582,14 @@ func (in *AdminConnection) DatabaseMine(gormDB *gorm.DB, database *Database) boo
 	var managedDatabase orm.ManagedDatabase
 
 	// If it doesn't exist, go ahead and take it!
-	if orm.DatabaseExists(gormDB, database.Spec.Name) == nil {
+	name := in.DatabaseName(database)
+	if orm.DatabaseExists(gormDB, name) == nil {
 		return true
 	}
 
 	// If it does exist, let's check the triple after fetching by UID
 	gormDB.Limit(1).Find(&managedDatabase, "uuid = ?", string(database.UID))
-	if managedDatabase.DatabaseName == database.Spec.Name &&
+	if managedDatabase.DatabaseName == name &&
 		managedDatabase.Name == database.Name &&
 		managedDatabase.Namespace == database.Namespace {
 		return true
@@ -341,27 +597,34 @@ func (in *AdminConnection) DatabaseMine(gormDB *gorm.DB, database *Database) boo
 	return false
 }
 
+// UserMine Whether the user@host accounts of the DatabaseUser are ours to manage. Accounts sharing the user name on
+// hosts we do not want are left alone, while any wanted account must have been created by this DatabaseUser.
 func (in *AdminConnection) UserMine(gormDB *gorm.DB, user *DatabaseUser) bool {
 
 	var managedUser orm.ManagedUser
 	names := make([]string, 0, 2)
-	names = append(names, user.Spec.Username)
-	if user.Spec.Username != user.Status.Username && user.Status.Username != "" {
+	username := in.Username(user)
+	names = append(names, username)
+	if username != user.Status.Username && user.Status.Username != "" {
 		names = append(names, user.Status.Username)
 	}
+	wanted := user.Spec.EffectiveHosts()
+	gormDB.Limit(1).Find(&managedUser, "uuid = ?", string(user.UID))
 
 	for _, name := range names {
-		// If it doesn't exist, go ahead and take it!
-		if orm.UserExists(gormDB, name) == nil {
-			continue
-		}
+		for _, host := range orm.UserHosts(gormDB, name) {
+			// If it doesn't exist on a wanted host, go ahead and take it!
+			if !containsFold(wanted, host) {
+				continue
+			}
 
-		// If it does exist, let's check the triple after fetching by UID
-		gormDB.Limit(1).Find(&managedUser, "uuid = ?", string(user.UID))
-		if managedUser.Username != name ||
-			managedUser.Name != user.Name ||
-			managedUser.Namespace != user.Namespace {
-			return false
+			// If it does exist, let's check the triple and the recorded hosts
+			if managedUser.Username != name ||
+				managedUser.Name != user.Name ||
+				managedUser.Namespace != user.Namespace ||
+				!containsFold(managedUser.HostList(), host) {
+				return false
+			}
 		}
 	}
 	return true
@@ -36,6 +36,44 @@ var _ = Describe("AdminConnection_Types", func() {
 		)
 	})
 
+	Describe("ResolveCharsetCollation", func() {
+		DescribeTable("Defaults",
+			func(preferredCharset string, preferredCollation string, charset string, collation string,
+				expectedCharset string, expectedCollation string) {
+				adminConnection := &AdminConnection{
+					Spec: AdminConnectionSpec{
+						DefaultCharacterSet: preferredCharset,
+						DefaultCollation:    preferredCollation,
+					},
+					Status: AdminConnectionStatus{
+						CharacterSet: "latin1",
+						Collation:    "latin1_swedish_ci",
+						AvailableCharsets: []Charset{
+							{Name: "latin1", Collations: []Collation{
+								{Name: "latin1_swedish_ci", Default: true},
+								{Name: "latin1_bin"},
+							}},
+							{Name: "utf8mb4", Collations: []Collation{
+								{Name: "utf8mb4_0900_ai_ci", Default: true},
+								{Name: "utf8mb4_unicode_ci"},
+							}},
+						},
+					},
+				}
+				resolvedCharset, resolvedCollation := adminConnection.ResolveCharsetCollation(charset, collation)
+				Expect(resolvedCharset).To(Equal(expectedCharset))
+				Expect(resolvedCollation).To(Equal(expectedCollation))
+			},
+			Entry("Server defaults", "", "", "", "", "latin1", "latin1_swedish_ci"),
+			Entry("Preferred defaults", "utf8mb4", "utf8mb4_unicode_ci", "", "", "utf8mb4", "utf8mb4_unicode_ci"),
+			Entry("Preferred charset only", "utf8mb4", "", "", "", "utf8mb4", "utf8mb4_0900_ai_ci"),
+			Entry("Preferred collation only", "", "utf8mb4_unicode_ci", "", "", "utf8mb4", "utf8mb4_unicode_ci"),
+			Entry("Charset given", "utf8mb4", "utf8mb4_unicode_ci", "latin1", "", "latin1", "latin1_swedish_ci"),
+			Entry("Collation given", "utf8mb4", "", "", "latin1_bin", "latin1", "latin1_bin"),
+			Entry("Both given", "utf8mb4", "", "latin1", "latin1_bin", "latin1", "latin1_bin"),
+		)
+	})
+
 	Describe("DatabaseMine", func() {
 		var gormDB *gorm.DB
 		var err error
@@ -281,6 +319,27 @@ var _ = Describe("AdminConnection_Types", func() {
 			})
 		})
 
+		Describe("User does exist in the table, but on a host not recorded", func() {
+			BeforeEach(func() {
+				managedUser.Hosts = "localhost"
+			})
+			It("returns false for mine.", func() {
+				isUserMine := ServerAdminConnection.UserMine(gormDB, user)
+				Expect(isUserMine).To(BeFalse())
+			})
+		})
+
+		Describe("User does exist in the server, but only on a host not wanted", func() {
+			BeforeEach(func() {
+				saveGormDb = false
+				user.Spec.Hosts = []string{"localhost"}
+			})
+			It("returns true for mine.", func() {
+				isUserMine := ServerAdminConnection.UserMine(gormDB, user)
+				Expect(isUserMine).To(BeTrue())
+			})
+		})
+
 		Describe("User does exist in the table, and in the server, but not my user name", func() {
 			BeforeEach(func() {
 				managedUser.Username = "wrongusername"
@@ -291,4 +350,53 @@ var _ = Describe("AdminConnection_Types", func() {
 			})
 		})
 	})
+
+	DescribeTable("DisallowedGlobalPrivileges",
+		func(namespace string, privileges []string, expected []string) {
+			adminConnection := &AdminConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "dba"},
+				Spec: AdminConnectionSpec{
+					GlobalPrivilegePolicy: []GlobalPrivilegeRule{
+						{Namespaces: []string{"monitoring-*"}, Privileges: []string{"PROCESS", "REPLICATION CLIENT"}},
+						{Namespaces: []string{"tools"}, Privileges: []string{"SHOW DATABASES"}},
+					},
+				},
+			}
+			Expect(adminConnection.DisallowedGlobalPrivileges(namespace, privileges)).To(Equal(expected))
+		},
+		Entry("Permitted by prefix", "monitoring-prod", []string{"process", "Replication Client"}, []string{}),
+		Entry("Permitted elsewhere only", "tools", []string{"PROCESS", "SHOW DATABASES"}, []string{"PROCESS"}),
+		Entry("No rule for the namespace", "dba", []string{"PROCESS"}, []string{"PROCESS"}),
+	)
+
+	DescribeTable("Name templates",
+		func(statusName string, recordedTemplate string, specName string, expected string) {
+			adminConnection := &AdminConnection{
+				Spec: AdminConnectionSpec{
+					DatabaseNameTemplate: "{{.Namespace}}_{{.Name}}",
+					UsernameTemplate:     "{{.Namespace}}_{{.Name}}",
+				},
+			}
+			database := &Database{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "blog"},
+				Spec:       DatabaseSpec{Name: specName},
+				Status:     DatabaseStatus{Name: statusName, NameTemplate: recordedTemplate},
+			}
+			Expect(adminConnection.DatabaseName(database)).To(Equal(expected))
+
+			user := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "blog"},
+				Spec:       DatabaseUserSpec{Username: specName},
+				Status:     DatabaseUserStatus{Username: statusName, UsernameTemplate: recordedTemplate},
+			}
+			Expect(adminConnection.Username(user)).To(Equal(expected))
+		},
+		Entry("Not yet named", "", "", "posts", "blog_posts"),
+		Entry("Named before the template", "posts", "", "posts", "posts"),
+		Entry("Renamed after the template", "posts", "", "articles", "articles"),
+		Entry("Named with the template", "blog_posts", "{{.Namespace}}_{{.Name}}", "posts", "blog_posts"),
+		Entry("Named with an older template", "blog-posts", "{{.Namespace}}-{{.Name}}", "posts", "blog-posts"),
+		Entry("Renamed with an older template", "blog-posts", "{{.Namespace}}-{{.Name}}", "articles",
+			"blog-articles"),
+	)
 })
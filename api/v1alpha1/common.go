@@ -19,15 +19,25 @@ package v1alpha1
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"math/big"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
+	"text/template"
+	"time"
 )
 
 const (
+	// MaxDatabaseNameLength The longest schema name MySQL accepts
+	MaxDatabaseNameLength = 64
+	// MaxUsernameLength The longest user name MySQL accepts
+	MaxUsernameLength = 32
+
 	lowerCharSet   = "abcdedfghijklmnopqrst"
 	upperCharSet   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	specialCharSet = "!@#$%&*"
@@ -46,6 +56,40 @@ type SecretKeySource struct {
 	SecretKeyRef v1.SecretKeySelector `json:"secretKeyRef"`
 }
 
+// ScriptSource SQL content held in either a ConfigMap or a Secret key
+type ScriptSource struct {
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// MaintenanceWindow A recurring period during which disruptive changes may be made
+type MaintenanceWindow struct {
+	// Start of the window as HH:MM in UTC
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+	// Length of the window (e.g. 2h)
+	Duration metav1.Duration `json:"duration"`
+	// Days on which the window starts, every day when empty
+	// +kubebuilder:validation:Optional
+	// +nullable
+	DaysOfWeek []Weekday `json:"daysOfWeek,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Sun;Mon;Tue;Wed;Thu;Fri;Sat
+type Weekday string
+
+// NameTemplateData The values available to the name templates of an AdminConnection
+type NameTemplateData struct {
+	// Namespace of the Database or DatabaseUser
+	Namespace string
+	// The name from the spec (spec.name or spec.username)
+	Name string
+}
+
 // GetSecretRefValue returns the value of a secret in the supplied namespace
 func GetSecretRefValue(ctx context.Context, client client.Client, namespace string, secretSelector *v1.SecretKeySelector) (string, error) {
 
@@ -61,6 +105,31 @@ func GetSecretRefValue(ctx context.Context, client client.Client, namespace stri
 
 }
 
+// GetConfigMapRefValue returns the value of a config map in the supplied namespace
+func GetConfigMapRefValue(ctx context.Context, client client.Client, namespace string, configMapSelector *v1.ConfigMapKeySelector) (string, error) {
+
+	configMap := &v1.ConfigMap{}
+	err := client.Get(ctx, types.NamespacedName{Name: configMapSelector.Name, Namespace: namespace}, configMap)
+	if err != nil {
+		return "", err
+	}
+	if data, ok := configMap.Data[configMapSelector.Key]; ok {
+		return data, nil
+	}
+	return "", fmt.Errorf("key %s not found in config map %s", configMapSelector.Key, configMapSelector.Name)
+}
+
+// GetScriptSourceValue returns the SQL content referenced by the script source in the supplied namespace
+func GetScriptSourceValue(ctx context.Context, client client.Client, namespace string, source *ScriptSource) (string, error) {
+	if source.ConfigMapKeyRef != nil {
+		return GetConfigMapRefValue(ctx, client, namespace, source.ConfigMapKeyRef)
+	}
+	if source.SecretKeyRef != nil {
+		return GetSecretRefValue(ctx, client, namespace, source.SecretKeyRef)
+	}
+	return "", fmt.Errorf("script source has neither a config map nor a secret reference")
+}
+
 func GetSecret(ctx context.Context, client client.Client, namespace string, secretSelector *v1.SecretKeySelector) (*v1.Secret, error) {
 	var namespacedName types.NamespacedName
 
@@ -76,6 +145,34 @@ func GetSecret(ctx context.Context, client client.Client, namespace string, secr
 	return secret, nil
 }
 
+// namespaceMatches Whether the namespace is in the list, allowing a trailing '*' to match by prefix.
+func namespaceMatches(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if pattern == namespace {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(namespace, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// QuoteIdentifier Wraps a schema object name in backticks, doubling any embedded backticks.
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteSchemaPattern Quotes a schema name for a database level GRANT or REVOKE, where _ and % are wildcards. They
+// are escaped so the grant covers only the one schema, e.g. team_a_app and not team-a_app.
+func QuoteSchemaPattern(name string) string {
+	return QuoteIdentifier(schemaWildcards.Replace(name))
+}
+
+var schemaWildcards = strings.NewReplacer("_", "\\_", "%", "\\%")
+
 // Escape Not all statements can be prepared with parameters (usernames/passwords).
 // For escaping MySQL strings.
 // See: https://stackoverflow.com/questions/31647406/mysql-real-escape-string-equivalent-for-golang
@@ -120,6 +217,20 @@ func Escape(sql string) string {
 	return string(dest)
 }
 
+// Account The quoted 'user'@'host' account name.
+func Account(username string, host string) string {
+	return "'" + Escape(username) + "'@'" + Escape(host) + "'"
+}
+
+// AccountList The comma separated accounts of the user on each of the hosts, as taken by CREATE, DROP, GRANT and REVOKE.
+func AccountList(username string, hosts []string) string {
+	accounts := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		accounts = append(accounts, Account(username, host))
+	}
+	return strings.Join(accounts, ", ")
+}
+
 // GeneratePassword Formulated from: https://golangbyexample.com/generate-random-password-golang/
 func GeneratePassword(passwordLength, minSpecialChar, minNum, minUpperCase int) string {
 	var password strings.Builder
@@ -156,3 +267,75 @@ func GeneratePassword(passwordLength, minSpecialChar, minNum, minUpperCase int)
 	}
 	return string(inRune)
 }
+
+// startOn The start of the window on the day of t, false when the window does not start that day.
+func (in *MaintenanceWindow) startOn(t time.Time) (time.Time, bool) {
+	clock, err := time.Parse("15:04", in.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	if len(in.DaysOfWeek) == 0 {
+		return start, true
+	}
+	for _, day := range in.DaysOfWeek {
+		if string(day) == start.Weekday().String()[:3] {
+			return start, true
+		}
+	}
+	return start, false
+}
+
+// InWindow Whether t falls within the window, including one started the previous day and running past midnight.
+func (in *MaintenanceWindow) InWindow(t time.Time) bool {
+	t = t.UTC()
+	for _, days := range []int{0, -1} {
+		start, ok := in.startOn(t.AddDate(0, 0, days))
+		if ok && !t.Before(start) && t.Before(start.Add(in.Duration.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStart The next time the window opens after t, zero when it never does.
+func (in *MaintenanceWindow) NextStart(t time.Time) time.Time {
+	t = t.UTC()
+	for days := 0; days <= 7; days++ {
+		start, ok := in.startOn(t.AddDate(0, 0, days))
+		if ok && start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// ApplyNameTemplate Renders the template for the namespace and name, shortening the result to the limit with a
+// hash suffix so distinct long names stay distinct. An empty template leaves the name as it is.
+func ApplyNameTemplate(nameTemplate string, namespace string, name string, limit int) (string, error) {
+	result := name
+	if nameTemplate != "" {
+		parsed, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+		if err != nil {
+			return name, err
+		}
+		var builder strings.Builder
+		err = parsed.Execute(&builder, NameTemplateData{Namespace: namespace, Name: name})
+		if err != nil {
+			return name, err
+		}
+		result = builder.String()
+	}
+	return truncateName(result, limit), nil
+}
+
+// truncateName Shortens the name to the limit, replacing the tail with _ and eight characters of its hash.
+func truncateName(name string, limit int) string {
+	runes := []rune(name)
+	if len(runes) <= limit {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := "_" + hex.EncodeToString(sum[:])[:8]
+	return string(runes[:limit-len(suffix)]) + suffix
+}
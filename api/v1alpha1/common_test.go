@@ -4,7 +4,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"regexp"
+	"time"
 )
 
 var hasSpecial = regexp.MustCompile(`[!@#$%&*]+`).MatchString
@@ -107,4 +109,63 @@ var _ = Describe("Common", func() {
 			})
 		})
 	})
+
+	Describe("MaintenanceWindow", func() {
+		// Wednesday 2024-01-03 22:00 UTC for 4 hours, running past midnight
+		window := &MaintenanceWindow{
+			Start:      "22:00",
+			Duration:   metav1.Duration{Duration: 4 * time.Hour},
+			DaysOfWeek: []Weekday{"Wed"},
+		}
+		DescribeTable("InWindow",
+			func(at string, expected bool) {
+				t, err := time.Parse(time.RFC3339, at)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(window.InWindow(t)).To(Equal(expected))
+			},
+			Entry("Before start", "2024-01-03T21:59:00Z", false),
+			Entry("At start", "2024-01-03T22:00:00Z", true),
+			Entry("After midnight", "2024-01-04T01:30:00Z", true),
+			Entry("At end", "2024-01-04T02:00:00Z", false),
+			Entry("Other day", "2024-01-04T22:30:00Z", false),
+		)
+		It("Should find the next start", func() {
+			t, _ := time.Parse(time.RFC3339, "2024-01-04T12:00:00Z")
+			Expect(window.NextStart(t)).To(Equal(time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC)))
+		})
+	})
+
+	Describe("ApplyNameTemplate", func() {
+		DescribeTable("Rendering",
+			func(nameTemplate string, namespace string, name string, limit int, expected string) {
+				result, err := ApplyNameTemplate(nameTemplate, namespace, name, limit)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(expected))
+			},
+			Entry("No template", "", "team-a", "app", 64, "app"),
+			Entry("Namespace prefix", "{{.Namespace}}_{{.Name}}", "team-a", "app", 64, "team-a_app"),
+			Entry("Shortened with hash", "{{.Namespace}}_{{.Name}}", "a-very-long-namespace", "application", 32,
+				"a-very-long-namespace_a_4d4aca3c"),
+		)
+		It("Should keep distinct long names distinct and within the limit", func() {
+			first, _ := ApplyNameTemplate("{{.Namespace}}_{{.Name}}", "a-very-long-namespace", "application1", 32)
+			second, _ := ApplyNameTemplate("{{.Namespace}}_{{.Name}}", "a-very-long-namespace", "application2", 32)
+			Expect(first).ToNot(Equal(second))
+			Expect(len(first)).To(Equal(32))
+		})
+		It("Should report an invalid template", func() {
+			_, err := ApplyNameTemplate("{{.Cluster}}", "team-a", "app", 64)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	DescribeTable("QuoteSchemaPattern",
+		func(name string, expected string) {
+			Expect(QuoteSchemaPattern(name)).To(Equal(expected))
+		},
+		Entry("Plain", "app", "`app`"),
+		Entry("Templated", "team-a_app", "`team-a\\_app`"),
+		Entry("Percent", "100%", "`100\\%`"),
+		Entry("Backtick", "a`b", "`a``b`"),
+	)
 })
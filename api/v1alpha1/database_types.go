@@ -19,12 +19,48 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+const (
+	// DatabaseInitialized Whether spec.initScripts have all been run against the database
+	DatabaseInitialized = "Initialized"
+	// DatabaseQuotaWarning Whether usage has crossed the warning threshold of the quota
+	DatabaseQuotaWarning = "QuotaWarning"
+	// DatabaseQuotaExceeded Whether usage has crossed the quota limit
+	DatabaseQuotaExceeded = "QuotaExceeded"
+	// DatabaseInvalidCharset Whether the requested character set or collation is not available on the server
+	DatabaseInvalidCharset = "InvalidCharset"
+)
+
+// DatabaseMode Whether DatabaseUsers may currently write to a Database
+type DatabaseMode string
+
+const (
+	DatabaseModeReadWrite DatabaseMode = "ReadWrite"
+	// DatabaseModeReadOnly Read only, via the schema READ ONLY option or by narrowing grants to SELECT
+	DatabaseModeReadOnly DatabaseMode = "ReadOnly"
+	// DatabaseModeRestricted Some privileges are withheld from DatabaseUsers, e.g. by a quota action
+	DatabaseModeRestricted DatabaseMode = "Restricted"
+)
+
+// QuotaAction What happens when a Database grows beyond its quota
+// +kubebuilder:validation:Enum=Alert;RevokeWrite;ReadOnly
+type QuotaAction string
+
+const (
+	// QuotaActionAlert Only sets the QuotaExceeded condition and emits an Event
+	QuotaActionAlert QuotaAction = "Alert"
+	// QuotaActionRevokeWrite Withholds INSERT and UPDATE from DatabaseUsers granted the database
+	QuotaActionRevokeWrite QuotaAction = "RevokeWrite"
+	// QuotaActionReadOnly Withholds all but SELECT, DELETE and DROP from DatabaseUsers granted the database
+	QuotaActionReadOnly QuotaAction = "ReadOnly"
+)
+
 // DatabaseSpec defines the desired state of Database
 type DatabaseSpec struct {
 	AdminConnection AdminConnectionRef `json:"adminConnection"`
@@ -39,6 +75,190 @@ type DatabaseSpec struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	Collate string `json:"collate,omitEmpty"`
+	// Copies the tables, views and data of another Database on the same AdminConnection when first created
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CloneFrom *DatabaseCloneSource `json:"cloneFrom,omitempty"`
+	// Namespaces other than this one permitted to clone this database (trailing '*' permits a prefix)
+	// +kubebuilder:validation:Optional
+	// +nullable
+	AllowCloneNamespaces []string `json:"allowCloneNamespaces,omitempty"`
+	// SQL scripts run in order once the database is first created, each exactly once. Scripts added to a database
+	// already created are not run.
+	// +kubebuilder:validation:Optional
+	// +nullable
+	InitScripts []ScriptSource `json:"initScripts,omitempty"`
+	// Name of the DatabaseUser in this namespace the init scripts run as, required with initScripts. It must be
+	// granted this Database and nothing else, and have a clear text password.
+	// +kubebuilder:validation:Optional
+	InitUser string `json:"initUser,omitempty"`
+	// Limits the space the database may use, see also the AdminConnection quota policy
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Quota *DatabaseQuota `json:"quota,omitempty"`
+	// Sets DEFAULT ENCRYPTION on the schema so new tables are encrypted at rest (MySQL 8.0.16+ with a keyring),
+	// the server default applies when unset
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Encryption *bool `json:"encryption,omitempty"`
+	// Converts existing tables with ALTER TABLE ... CONVERT TO CHARACTER SET when the character set or collation
+	// differs from the database default
+	// +kubebuilder:validation:Optional
+	ConvertTables bool `json:"convertTables,omitempty"`
+	// Restricts disruptive changes such as table conversion to a recurring window, any time when unset
+	// +kubebuilder:validation:Optional
+	// +nullable
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+	// Freezes the schema against writes without removing DatabaseUsers or their grants
+	// +kubebuilder:validation:Optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+type DatabaseQuota struct {
+	// Largest size of data and indexes permitted, defaults to the AdminConnection quota policy
+	// +kubebuilder:validation:Optional
+	// +nullable
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+	// Percentage of maxSize at which a warning is raised, defaults to the AdminConnection quota policy
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	WarningPercent *int32 `json:"warningPercent,omitempty"`
+	// Action taken while usage is beyond maxSize, defaults to the AdminConnection quota policy
+	// +kubebuilder:validation:Optional
+	Action QuotaAction `json:"action,omitempty"`
+}
+
+type QuotaStatus struct {
+	// The effective limit, human readable
+	Limit string `json:"limit"`
+	// The effective limit in bytes
+	LimitBytes int64 `json:"limitBytes"`
+	// Usage as a percentage of the limit
+	UsedPercent int32 `json:"usedPercent"`
+	// One of Ok, Warning or Exceeded
+	State string `json:"state"`
+	// The effective action when exceeded
+	Action QuotaAction `json:"action"`
+}
+
+type ConversionStatus struct {
+	// The character set tables are converted to
+	CharacterSet string `json:"characterSet"`
+	// The collation tables are converted to
+	Collation string `json:"collation"`
+	// Tables converted so far
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Tables []string `json:"tables,omitempty"`
+	// Number of tables still to be converted
+	Pending int32 `json:"pending"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+}
+
+// RenamePhase The step a rename of the underlying schema has reached
+type RenamePhase string
+
+const (
+	RenamePhaseCreatingSchema    RenamePhase = "CreatingSchema"
+	RenamePhaseMovingTables      RenamePhase = "MovingTables"
+	RenamePhaseRecreatingObjects RenamePhase = "RecreatingObjects"
+	RenamePhaseRegranting        RenamePhase = "Regranting"
+	RenamePhaseDroppingSchema    RenamePhase = "DroppingSchema"
+	RenamePhaseComplete          RenamePhase = "Complete"
+)
+
+type RenameStatus struct {
+	// The schema being renamed
+	From string `json:"from"`
+	// The new schema name
+	To string `json:"to"`
+	// The step reached, each is completed before moving to the next
+	Phase RenamePhase `json:"phase"`
+	// Tables already moved to the new schema
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Tables []string `json:"tables,omitempty"`
+	// Triggers dropped from a table being moved, awaiting recreation in the new schema
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Triggers []RenameTrigger `json:"triggers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+}
+
+type RenameTrigger struct {
+	Name string `json:"name"`
+	// The CREATE TRIGGER statement as reported by SHOW CREATE TRIGGER
+	Statement string `json:"statement"`
+}
+
+type DatabaseCloneSource struct {
+	// Namespace of the source Database, defaults to the namespace of this Database
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the source Database object
+	Name string `json:"name"`
+}
+
+type CloneStatus struct {
+	// The source Database as namespace/name
+	Source string `json:"source"`
+	// The source schema name on the server
+	// +kubebuilder:validation:Optional
+	SourceName string `json:"sourceName,omitempty"`
+	// Tables already fully copied from the source
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Tables []string `json:"tables,omitempty"`
+	// Timestamp identifying when the clone was completed
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+}
+
+type TableSize struct {
+	Name string `json:"name"`
+	// Bytes used by the table rows
+	DataLength int64 `json:"dataLength"`
+	// Bytes used by the table indexes
+	IndexLength int64 `json:"indexLength"`
+	// Approximate number of rows
+	Rows int64 `json:"rows"`
+}
+
+type DatabaseSize struct {
+	// Data and index length combined, human readable
+	Total string `json:"total"`
+	// Data and index length combined in bytes
+	TotalBytes int64 `json:"totalBytes"`
+	// Bytes used by table rows
+	DataLength int64 `json:"dataLength"`
+	// Bytes used by indexes
+	IndexLength int64 `json:"indexLength"`
+	// Bytes allocated but unused
+	DataFree int64 `json:"dataFree"`
+	Tables   int32 `json:"tables"`
+	Views    int32 `json:"views"`
+	Routines int32 `json:"routines"`
+	// The tables using the most space, largest first
+	// +kubebuilder:validation:Optional
+	// +nullable
+	LargestTables []TableSize `json:"largestTables,omitempty"`
+	// Timestamp identifying when the statistics were gathered
+	// +kubebuilder:validation:Optional
+	// +nullable
+	RefreshTime metav1.Time `json:"refreshTime,omitempty"`
 }
 
 // DatabaseStatus defines the observed state of Database
@@ -54,12 +274,18 @@ type DatabaseStatus struct {
 	CharacterSet string `json:"defaultCharacterSet,omitEmpty"`
 	// +kubebuilder:validation:Optional
 	Collate string `json:"defaultCollation,omitEmpty"`
+	// DEFAULT_ENCRYPTION of the schema (YES or NO), empty where the server does not support it
+	// +kubebuilder:validation:Optional
+	DefaultEncryption string `json:"defaultEncryption,omitempty"`
 	// Indicates current state, phase or issue
 	// +kubebuilder:validation:Optional
 	Message string `json:"message,omitEmpty"`
 	// +kubebuilder:validation:Optional
 	// +nullable
 	Name string `json:"name,omitempty"`
+	// The databaseNameTemplate of the AdminConnection when the schema was named
+	// +kubebuilder:validation:Optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +nullable
 	Host string `json:"host,omitempty"`
@@ -68,10 +294,53 @@ type DatabaseStatus struct {
 	// +kubebuilder:validation:Maximum=65535
 	// +kubebuilder:validation:Optional
 	Port int32 `json:"port"`
+	// Progress of copying from spec.cloneFrom, present only when cloned at creation
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Clone *CloneStatus `json:"clone,omitempty"`
+	// Progress of converting existing tables to the database character set and collation
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Conversion *ConversionStatus `json:"conversion,omitempty"`
+	// Progress of a rename of the underlying schema to spec.name
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Rename *RenameStatus `json:"rename,omitempty"`
+	// The most recent version applied by a DatabaseMigration
+	// +kubebuilder:validation:Optional
+	SchemaVersion string `json:"schemaVersion,omitempty"`
+	// Space used as reported by INFORMATION_SCHEMA.TABLES
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Size *DatabaseSize `json:"size,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Quota *QuotaStatus `json:"quota,omitempty"`
+	// Privileges currently withheld from DatabaseUsers granted this database
+	// +kubebuilder:validation:Optional
+	// +nullable
+	RevokedPrivileges []string `json:"revokedPrivileges,omitempty"`
+	// Whether the schema has the READ ONLY option set on the server
+	// +kubebuilder:validation:Optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// The effective access mode, one of ReadWrite, ReadOnly or Restricted
+	// +kubebuilder:validation:Optional
+	Mode DatabaseMode `json:"mode,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schema",type=string,JSONPath=`.status.name`
+// +kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.size.total`
+// +kubebuilder:printcolumn:name="Tables",type=integer,JSONPath=`.status.size.tables`,priority=1
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.mode`,priority=1
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Database is the Schema for the databases API
 type Database struct {
@@ -94,3 +363,57 @@ type DatabaseList struct {
 func init() {
 	SchemeBuilder.Register(&Database{}, &DatabaseList{})
 }
+
+// CloneAllowed Whether a Database in the given namespace may clone this one.
+func (in *Database) CloneAllowed(namespace string) bool {
+	return namespace == in.Namespace || namespaceMatches(in.Spec.AllowCloneNamespaces, namespace)
+}
+
+// EffectiveQuota The quota for this database after applying the defaults and cap of the AdminConnection policy,
+// nil when neither sets a limit.
+func (in *Database) EffectiveQuota(adminConnection *AdminConnection) *DatabaseQuota {
+
+	quota := &DatabaseQuota{Action: QuotaActionAlert}
+	policy := adminConnection.Spec.QuotaPolicy
+	if policy != nil {
+		quota.MaxSize = policy.DefaultMaxSize
+		quota.WarningPercent = policy.WarningPercent
+		if policy.DefaultAction != "" {
+			quota.Action = policy.DefaultAction
+		}
+	}
+	if in.Spec.Quota != nil {
+		if in.Spec.Quota.MaxSize != nil {
+			quota.MaxSize = in.Spec.Quota.MaxSize
+		}
+		if in.Spec.Quota.WarningPercent != nil {
+			quota.WarningPercent = in.Spec.Quota.WarningPercent
+		}
+		if in.Spec.Quota.Action != "" {
+			quota.Action = in.Spec.Quota.Action
+		}
+	}
+	if policy != nil && policy.MaxSizeLimit != nil &&
+		(quota.MaxSize == nil || quota.MaxSize.Cmp(*policy.MaxSizeLimit) > 0) {
+		quota.MaxSize = policy.MaxSizeLimit
+	}
+	if quota.MaxSize == nil {
+		return nil
+	}
+	if quota.WarningPercent == nil {
+		warningPercent := int32(DefaultQuotaWarningPercent)
+		quota.WarningPercent = &warningPercent
+	}
+	return quota
+}
+
+// RenameInProgress Whether a rename of the underlying schema has started and not yet completed.
+func (in *Database) RenameInProgress() bool {
+	return in.Status.Rename != nil && in.Status.Rename.Phase != RenamePhaseComplete
+}
+
+// CloneComplete Whether there is no clone requested or pending for this database. The clone status is recorded
+// before the schema is created, so a schema without it was not created as a clone.
+func (in *Database) CloneComplete() bool {
+	return in.Spec.CloneFrom == nil || in.Status.Clone == nil || !in.Status.Clone.CompletionTime.IsZero()
+}
@@ -0,0 +1,119 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Database_Types", func() {
+
+	Describe("CloneAllowed", func() {
+		DescribeTable("Namespace rules",
+			func(namespaceList []string, namespace string, good bool) {
+				database := &Database{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "staging",
+						Namespace: "default",
+					},
+					Spec: DatabaseSpec{
+						Name:                 "staging",
+						AllowCloneNamespaces: namespaceList,
+					},
+				}
+				Expect(database.CloneAllowed(namespace)).To(Equal(good))
+			},
+			Entry("Allow itself when no list", []string{}, "default", true),
+			Entry("Disallow other tenant when no list", []string{}, "tenant1", false),
+			Entry("Allow explicit thing in list", []string{"tenant1"}, "tenant1", true),
+			Entry("Allow prefix", []string{"preview-*"}, "preview-42", true),
+			Entry("Disallow non-match with prefix", []string{"preview-*"}, "tenant1", false),
+		)
+	})
+
+	Describe("CloneComplete", func() {
+		DescribeTable("Clone progress",
+			func(cloneFrom *DatabaseCloneSource, clone *CloneStatus, complete bool) {
+				database := &Database{
+					Spec:   DatabaseSpec{Name: "preview-42", CloneFrom: cloneFrom},
+					Status: DatabaseStatus{Clone: clone},
+				}
+				Expect(database.CloneComplete()).To(Equal(complete))
+			},
+			Entry("No clone requested", nil, nil, true),
+			Entry("Requested after the schema was created", &DatabaseCloneSource{Name: "staging"}, nil, true),
+			Entry("Recorded and not yet copied", &DatabaseCloneSource{Name: "staging"}, &CloneStatus{}, false),
+			Entry("Part way through", &DatabaseCloneSource{Name: "staging"},
+				&CloneStatus{Source: "default/staging", Tables: []string{"a"}}, false),
+			Entry("Copied", &DatabaseCloneSource{Name: "staging"},
+				&CloneStatus{Source: "default/staging", CompletionTime: metav1.Now()}, true),
+		)
+	})
+
+	Describe("EffectiveQuota", func() {
+		quantity := func(value string) *resource.Quantity {
+			q := resource.MustParse(value)
+			return &q
+		}
+		DescribeTable("Policy and spec merging",
+			func(policy *QuotaPolicy, quota *DatabaseQuota, maxSize string, action QuotaAction) {
+				adminConnection := &AdminConnection{Spec: AdminConnectionSpec{QuotaPolicy: policy}}
+				database := &Database{Spec: DatabaseSpec{Name: "quota", Quota: quota}}
+				effective := database.EffectiveQuota(adminConnection)
+				if maxSize == "" {
+					Expect(effective).To(BeNil())
+					return
+				}
+				Expect(effective.MaxSize.String()).To(Equal(maxSize))
+				Expect(effective.Action).To(Equal(action))
+				Expect(*effective.WarningPercent).To(BeNumerically(">", 0))
+			},
+			Entry("No quota anywhere", nil, nil, "", QuotaAction("")),
+			Entry("Spec only", nil, &DatabaseQuota{MaxSize: quantity("1Gi")}, "1Gi", QuotaActionAlert),
+			Entry("Policy default", &QuotaPolicy{DefaultMaxSize: quantity("2Gi"),
+				DefaultAction: QuotaActionRevokeWrite}, nil, "2Gi", QuotaActionRevokeWrite),
+			Entry("Spec overrides default", &QuotaPolicy{DefaultMaxSize: quantity("2Gi")},
+				&DatabaseQuota{MaxSize: quantity("1Gi"), Action: QuotaActionReadOnly}, "1Gi", QuotaActionReadOnly),
+			Entry("Capped at limit", &QuotaPolicy{MaxSizeLimit: quantity("5Gi")},
+				&DatabaseQuota{MaxSize: quantity("10Gi")}, "5Gi", QuotaActionAlert),
+		)
+	})
+
+	Describe("EffectiveGrants", func() {
+		It("Leaves grants alone when nothing is withheld", func() {
+			database := &Database{}
+			Expect(database.EffectiveGrants(nil)).To(BeNil())
+			Expect(database.EffectiveGrants([]string{"select", "insert"})).To(Equal([]string{"select", "insert"}))
+		})
+		It("Removes withheld privileges", func() {
+			database := &Database{Status: DatabaseStatus{RevokedPrivileges: WritePrivileges}}
+			Expect(database.EffectiveGrants([]string{"select", "insert"})).To(Equal([]string{"select"}))
+			Expect(database.EffectiveGrants([]string{"update"})).To(BeEmpty())
+			all := database.EffectiveGrants(nil)
+			Expect(all).To(ContainElement("SELECT"))
+			Expect(all).NotTo(ContainElement("INSERT"))
+		})
+	})
+
+	Describe("EffectiveTables", func() {
+		tables := []TablePermission{
+			{Name: "orders", Grants: []string{"SELECT", "INSERT"}},
+			{Name: "notes", Columns: []ColumnPermission{{Privilege: "UPDATE", Columns: []string{"note"}}}},
+			{Name: "audit", Grants: []string{"ALL"}},
+		}
+		It("Leaves tables alone when nothing is withheld", func() {
+			database := &Database{}
+			Expect(database.EffectiveTables(tables)).To(Equal(tables))
+		})
+		It("Removes withheld privileges and tables left without any", func() {
+			database := &Database{Status: DatabaseStatus{RevokedPrivileges: WritePrivileges}}
+			effective := database.EffectiveTables(tables)
+			Expect(effective).To(HaveLen(2))
+			Expect(effective[0]).To(Equal(TablePermission{Name: "orders", Grants: []string{"SELECT"}}))
+			Expect(effective[1].Name).To(Equal("audit"))
+			Expect(effective[1].Grants).To(ContainElement("SELECT"))
+			Expect(effective[1].Grants).NotTo(ContainElement("UPDATE"))
+		})
+	})
+})
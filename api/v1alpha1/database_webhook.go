@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"context"
+	"github.com/cuppett/mysql-dba-operator/orm"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"regexp"
@@ -32,10 +33,39 @@ var (
 	nameRegEx = regexp.MustCompile(`^[^\\/?%*:|"<>.]{1,64}$`)
 )
 
+// CharsetValidation How the webhook treats a Database whose AdminConnection cannot yet confirm the character set
+// and collation
+type CharsetValidation string
+
+const (
+	// CharsetValidationStrict Rejects the Database
+	CharsetValidationStrict CharsetValidation = "strict"
+	// CharsetValidationLenient Admits the Database with a warning, the Database reconciler checks it against the
+	// server and sets the InvalidCharset condition
+	CharsetValidationLenient CharsetValidation = "lenient"
+)
+
+// DatabaseWebhookOptions Operator settings used by the Database webhook
+// +kubebuilder:object:generate=false
+type DatabaseWebhookOptions struct {
+	CharsetValidation CharsetValidation
+	// In strict mode, query the server when the AdminConnection has not yet reported its character sets
+	QueryServer bool
+	// The connection cache shared with the controllers
+	Connections map[types.UID]*orm.ConnectionDefinition
+}
+
+var databaseWebhookOptions = DatabaseWebhookOptions{CharsetValidation: CharsetValidationStrict}
+
 // log is for logging in this package.
 var databaseLog = logf.Log.WithName("database-resource")
 var k8sClient client.Client
 
+// ConfigureDatabaseWebhook Applies the operator settings for the Database webhook
+func ConfigureDatabaseWebhook(options DatabaseWebhookOptions) {
+	databaseWebhookOptions = options
+}
+
 func (r *Database) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	k8sClient = mgr.GetClient()
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -53,6 +83,35 @@ func (e *validationError) Error() string {
 	return e.s
 }
 
+// +kubebuilder:webhook:path=/mutate-mysql-apps-cuppett-dev-v1alpha1-database,mutating=true,failurePolicy=fail,sideEffects=None,groups=mysql.apps.cuppett.dev,resources=databases,verbs=create;update,versions=v1alpha1,name=mdatabase.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Database{}
+
+// Default implements webhook.Defaulter so the character set and collation a Database is created with are
+// recorded in its spec rather than left to the server default of the day.
+func (r *Database) Default() {
+	databaseLog.Info("default", "namespace", r.Namespace, "name", r.Name)
+
+	if r.Spec.AdminConnection.Name == "" || r.GetDeletionTimestamp() != nil ||
+		(r.Spec.CharacterSet != "" && r.Spec.Collate != "") {
+		return
+	}
+
+	// Existing databases keep what they were created with
+	if r.Spec.CharacterSet == "" && r.Spec.Collate == "" && r.Status.CharacterSet != "" {
+		r.Spec.CharacterSet = r.Status.CharacterSet
+		r.Spec.Collate = r.Status.Collate
+		return
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		// Reported by the charset and collation validation
+		return
+	}
+	r.Spec.CharacterSet, r.Spec.Collate = adminConnection.ResolveCharsetCollation(r.Spec.CharacterSet, r.Spec.Collate)
+}
+
 // +kubebuilder:webhook:path=/validate-mysql-apps-cuppett-dev-v1alpha1-database,mutating=false,failurePolicy=fail,sideEffects=None,groups=mysql.apps.cuppett.dev,resources=databases,verbs=create;update;delete,versions=v1alpha1,name=vdatabase.kb.io,admissionReviewVersions=v1
 
 var _ webhook.Validator = &Database{}
@@ -66,7 +125,21 @@ func (r *Database) ValidateCreate() (admission.Warnings, error) {
 		return nil, &validationError{"Invalid database name."}
 	}
 
-	return r.ValidateCharsetCollationCombo()
+	if err := r.ValidateQuota(); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateInitScripts(); err != nil {
+		return nil, err
+	}
+
+	warnings, err := r.ValidateEncryption(true)
+	if err != nil {
+		return warnings, err
+	}
+
+	charsetWarnings, err := r.ValidateCharsetCollationCombo()
+	return append(warnings, charsetWarnings...), err
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -76,11 +149,34 @@ func (r *Database) ValidateUpdate(old runtime.Object) (admission.Warnings, error
 	// Converting to Database type
 	oldDatabase := old.(*Database)
 
+	// Renames are carried out by the operator, one at a time
 	if r.Spec.Name != oldDatabase.Spec.Name {
-		return nil, &validationError{"Name not allowed to be changed"}
+		if !nameRegEx.MatchString(r.Spec.Name) {
+			return nil, &validationError{"Invalid database name."}
+		}
+		if oldDatabase.RenameInProgress() {
+			return nil, &validationError{"Name not allowed to be changed while a rename is in progress"}
+		}
+		if !oldDatabase.CloneComplete() {
+			return nil, &validationError{"Name not allowed to be changed while cloning"}
+		}
 	}
 
-	return r.ValidateCharsetCollationCombo()
+	if err := r.ValidateQuota(); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateInitScripts(); err != nil {
+		return nil, err
+	}
+
+	warnings, err := r.ValidateEncryption(false)
+	if err != nil {
+		return warnings, err
+	}
+
+	charsetWarnings, err := r.ValidateCharsetCollationCombo()
+	return append(warnings, charsetWarnings...), err
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -90,6 +186,71 @@ func (r *Database) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
+// ValidateQuota Rejects a requested quota larger than the AdminConnection quota policy permits.
+func (r *Database) ValidateQuota() error {
+
+	if r.Spec.Quota == nil || r.Spec.Quota.MaxSize == nil || r.Spec.AdminConnection.Name == "" {
+		return nil
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		// Reported by the charset and collation validation
+		return nil
+	}
+
+	policy := adminConnection.Spec.QuotaPolicy
+	if policy != nil && policy.MaxSizeLimit != nil && r.Spec.Quota.MaxSize.Cmp(*policy.MaxSizeLimit) > 0 {
+		return &validationError{"Quota maxSize exceeds the limit of " + policy.MaxSizeLimit.String() +
+			" permitted by the AdminConnection"}
+	}
+	return nil
+}
+
+// ValidateInitScripts Requires the user init scripts run as, they are never run with the admin connection.
+func (r *Database) ValidateInitScripts() error {
+
+	if len(r.Spec.InitScripts) > 0 && r.Spec.InitUser == "" {
+		return &validationError{"initUser is required to run initScripts"}
+	}
+	return nil
+}
+
+// ValidateEncryption Checks the server can encrypt the database. New databases are rejected without a keyring,
+// existing ones only warned so unrelated changes are not blocked.
+func (r *Database) ValidateEncryption(create bool) (admission.Warnings, error) {
+
+	if r.Spec.Encryption == nil || !*r.Spec.Encryption || r.Spec.AdminConnection.Name == "" {
+		return nil, nil
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		// Reported by the charset and collation validation
+		return nil, nil
+	}
+	if adminConnection.Status.ServerVersion == "" {
+		return admission.Warnings{"Encryption support of the server not yet known"}, nil
+	}
+
+	problem := ""
+	server := orm.ParseServerVersion(adminConnection.Status.ServerVersion)
+	if server.IsMariaDB() || !server.AtLeast(8, 0, 16) {
+		problem = "Encryption requires MySQL 8.0.16 or later"
+	} else if !adminConnection.Status.KeyringAvailable {
+		problem = "Encryption requires a keyring on the server"
+	}
+	if problem == "" {
+		return nil, nil
+	}
+	if create {
+		return nil, &validationError{problem}
+	}
+	return admission.Warnings{problem}, nil
+}
+
+// ValidateCharsetCollationCombo Checks the character set and collation against those of the server. When the
+// AdminConnection is missing or not yet synced the outcome follows the configured CharsetValidation.
 func (r *Database) ValidateCharsetCollationCombo() (admission.Warnings, error) {
 
 	// If there is no admin connection, we can skip this validation for now.
@@ -99,35 +260,40 @@ func (r *Database) ValidateCharsetCollationCombo() (admission.Warnings, error) {
 
 	databaseLog.Info("validate charset collation combo", "namespace", r.Namespace, "name", r.Name)
 
-	// Getting admin connection
-	adminNamespace := r.Namespace
-	if r.Spec.AdminConnection.Namespace != "" {
-		adminNamespace = r.Spec.AdminConnection.Namespace
-	}
-	adminConnectionNamespacedName := types.NamespacedName{
-		Namespace: adminNamespace,
-		Name:      r.Spec.AdminConnection.Name,
-	}
-
-	adminConnection := &AdminConnection{}
-	err := k8sClient.Get(context.TODO(), adminConnectionNamespacedName, adminConnection)
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
 	if err != nil {
 		return nil, err
 	}
+	if adminConnection == nil {
+		return deferCharsetValidation("AdminConnection " + r.Spec.AdminConnection.Name + " not found")
+	}
 	logger := databaseLog.WithValues("AdminConnection", types.NamespacedName{Name: adminConnection.Name, Namespace: adminConnection.Namespace})
 
+	server := &adminConnection.Status
+	if len(server.AvailableCharsets) == 0 && databaseWebhookOptions.CharsetValidation == CharsetValidationStrict &&
+		databaseWebhookOptions.QueryServer {
+		server, err = adminConnection.queryServerCharsets(context.TODO())
+		if err != nil {
+			logger.Info("Unable to query the server for character sets", "error", err.Error())
+			server = &adminConnection.Status
+		}
+	}
+	if len(server.AvailableCharsets) == 0 {
+		return deferCharsetValidation("AdminConnection " + adminConnection.Name + " has not yet reported the server character sets")
+	}
+
 	// Getting charset and collation
 	charset := r.Spec.CharacterSet
 	if charset == "" {
-		charset = adminConnection.Status.CharacterSet
+		charset = server.CharacterSet
 	}
 	collation := r.Spec.Collate
 	if collation == "" {
-		collation = adminConnection.Status.Collation
+		collation = server.Collation
 	}
 	logger.Info("Validating charset and collation", "charset", charset, "collation", collation, "adminConnection", adminConnection.Status)
 
-	for _, charsetCollationCombo := range adminConnection.Status.AvailableCharsets {
+	for _, charsetCollationCombo := range server.AvailableCharsets {
 		logger.Info("Checking", "current charset", charsetCollationCombo.Name, "charset", charset)
 		if charsetCollationCombo.Name == charset {
 			for _, collationEntry := range charsetCollationCombo.Collations {
@@ -149,3 +315,28 @@ func (r *Database) ValidateCharsetCollationCombo() (admission.Warnings, error) {
 	}
 	return nil, &validationError{"Charset not valid for this server"}
 }
+
+// deferCharsetValidation Rejects in strict mode, otherwise admits with a warning leaving the check to the operator.
+func deferCharsetValidation(reason string) (admission.Warnings, error) {
+	if databaseWebhookOptions.CharsetValidation == CharsetValidationLenient {
+		return admission.Warnings{reason + ", the character set and collation will be checked by the operator"}, nil
+	}
+	return nil, &validationError{reason}
+}
+
+// queryServerCharsets Reads the server default and available character sets through the shared connection cache,
+// for AdminConnections the operator has not yet synced.
+func (in *AdminConnection) queryServerCharsets(ctx context.Context) (*AdminConnectionStatus, error) {
+
+	gormDB, err := in.GetDatabaseConnection(ctx, k8sClient, databaseWebhookOptions.Connections)
+	if err != nil {
+		return nil, err
+	}
+	server := &AdminConnectionStatus{}
+	row := gormDB.Raw("SELECT @@GLOBAL.character_set_server, @@GLOBAL.collation_server").Row()
+	if err = row.Scan(&server.CharacterSet, &server.Collation); err != nil {
+		return nil, err
+	}
+	server.AvailableCharsets, err = GetAvailableCharsets(gormDB)
+	return server, err
+}
@@ -3,6 +3,7 @@ package v1alpha1
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -43,12 +44,34 @@ var _ = Describe("Database Webhook", func() {
 			}
 		})
 
-		It("should not allow changing names", func() {
+		It("should allow changing names", func() {
 			err := k8sClient.Create(ctx, database)
 			Expect(err).NotTo(HaveOccurred())
 
 			database.Spec.Name = "test2"
 			err = k8sClient.Update(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not allow changing to an invalid name", func() {
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			database.Spec.Name = "test?test"
+			err = k8sClient.Update(ctx, database)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not allow changing names during a rename", func() {
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			database.Status.Rename = &RenameStatus{From: "test", To: "test2", Phase: RenamePhaseMovingTables}
+			err = k8sClient.Status().Update(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			database.Spec.Name = "test3"
+			err = k8sClient.Update(ctx, database)
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -103,4 +126,208 @@ var _ = Describe("Database Webhook", func() {
 			Entry("Non default collation for charset", "non-default-collate", "utf8mb4", "utf8mb4_unicode_ci", false, nil, admission.Warnings{"Collation not the default for this charset"}),
 		)
 	})
+
+	Describe("Charset validation policy", func() {
+		BeforeEach(func() {
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "policy",
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					Name: "policy",
+					AdminConnection: AdminConnectionRef{
+						Name: "not-yet-synced",
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			ConfigureDatabaseWebhook(DatabaseWebhookOptions{CharsetValidation: CharsetValidationStrict})
+		})
+
+		It("should reject a missing AdminConnection when strict", func() {
+			err := k8sClient.Create(ctx, database)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("AdminConnection not-yet-synced not found"))
+		})
+
+		It("should admit a missing AdminConnection when lenient", func() {
+			ConfigureDatabaseWebhook(DatabaseWebhookOptions{CharsetValidation: CharsetValidationLenient})
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Delete(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Encryption rules", func() {
+		var adminConnection *AdminConnection
+
+		BeforeEach(func() {
+			adminConnection = &AdminConnection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "encryption",
+					Namespace: "default",
+				},
+				Spec: *ServerAdminConnection.Spec.DeepCopy(),
+			}
+			err := k8sClient.Create(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+			adminConnection.Status = *ServerAdminConnection.Status.DeepCopy()
+
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "encryption",
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					Name: "encryption",
+					AdminConnection: AdminConnectionRef{
+						Name: adminConnection.Name,
+					},
+				},
+			}
+		})
+
+		setServer := func(version string, keyring bool) {
+			adminConnection.Status.ServerVersion = version
+			adminConnection.Status.KeyringAvailable = keyring
+			err := k8sClient.Status().Update(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		DescribeTable("on create",
+			func(version string, keyring bool, expectedError string) {
+				setServer(version, keyring)
+				encryption := true
+				database.Spec.Encryption = &encryption
+				err := k8sClient.Create(ctx, database)
+				if expectedError != "" {
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(expectedError))
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				err = k8sClient.Delete(ctx, database)
+				Expect(err).NotTo(HaveOccurred())
+			},
+			Entry("MySQL with a keyring", "8.0.36", true, ""),
+			Entry("MySQL without a keyring", "8.0.36", false, "Encryption requires a keyring on the server"),
+			Entry("Before MySQL 8.0.16", "8.0.15", true, "Encryption requires MySQL 8.0.16 or later"),
+			Entry("MariaDB", "10.11.6-MariaDB", true, "Encryption requires MySQL 8.0.16 or later"),
+		)
+
+		DescribeTable("on update",
+			func(version string, keyring bool, expectedWarning string) {
+				setServer(version, keyring)
+				err := k8sClient.Create(ctx, database)
+				Expect(err).NotTo(HaveOccurred())
+
+				old := database.DeepCopy()
+				encryption := true
+				database.Spec.Encryption = &encryption
+				warnings, err := database.ValidateUpdate(old)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(warnings).To(ContainElement(expectedWarning))
+
+				// Unrelated changes are not blocked
+				err = k8sClient.Update(ctx, database)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = k8sClient.Delete(ctx, database)
+				Expect(err).NotTo(HaveOccurred())
+			},
+			Entry("MySQL without a keyring", "8.0.36", false, "Encryption requires a keyring on the server"),
+			Entry("MariaDB", "10.11.6-MariaDB", true, "Encryption requires MySQL 8.0.16 or later"),
+			Entry("Server not yet known", "", false, "Encryption support of the server not yet known"),
+		)
+
+		AfterEach(func() {
+			err := k8sClient.Delete(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Init script rules", func() {
+		BeforeEach(func() {
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "initscripts",
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					Name: "initscripts",
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					InitScripts: []ScriptSource{
+						{
+							ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "initscripts"},
+								Key:                  "seed.sql",
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("should require an init user", func() {
+			err := k8sClient.Create(ctx, database)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("initUser is required to run initScripts"))
+		})
+
+		It("should allow init scripts with an init user", func() {
+			database.Spec.InitUser = "initscripts-owner"
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			database.Spec.InitUser = ""
+			err = k8sClient.Update(ctx, database)
+			Expect(err).To(HaveOccurred())
+
+			err = k8sClient.Delete(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("Defaulting charset and collation", func() {
+		BeforeEach(func() {
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "defaulting",
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					Name: "defaulting",
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+				},
+			}
+		})
+
+		It("should record the server defaults", func() {
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(database.Spec.CharacterSet).To(Equal("utf8mb4"))
+			Expect(database.Spec.Collate).To(Equal("utf8mb4_general_ci"))
+		})
+
+		It("should record the collation of a given charset", func() {
+			database.Spec.CharacterSet = "utf8mb4"
+			err := k8sClient.Create(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(database.Spec.Collate).To(Equal("utf8mb4_general_ci"))
+		})
+
+		AfterEach(func() {
+			err := k8sClient.Delete(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
 })
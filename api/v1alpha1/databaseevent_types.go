@@ -0,0 +1,122 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// DatabaseEventSpec defines the desired state of DatabaseEvent
+type DatabaseEventSpec struct {
+	// Name of the Database object in this namespace holding the event
+	// +kubebuilder:validation:MinLength:=1
+	Database string `json:"databaseName"`
+	// Name of the event in the schema
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// Name of the DatabaseUser object in this namespace set as DEFINER. It must be created by the same
+	// AdminConnection as the Database.
+	// +kubebuilder:validation:MinLength:=1
+	Definer string `json:"definer"`
+	// The schedule as written after ON SCHEDULE, e.g. EVERY 1 DAY STARTS '2024-01-01 03:00:00'
+	// +kubebuilder:validation:MinLength:=1
+	Schedule string `json:"schedule"`
+	// Whether the event is ENABLE or DISABLE
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	Enabled bool `json:"enabled"`
+	// Keep a one-time or expired event on the server after it completes (ON COMPLETION PRESERVE)
+	// +kubebuilder:validation:Optional
+	Preserve bool `json:"preserve,omitempty"`
+	// The statement run by the event as written after DO
+	// +kubebuilder:validation:MinLength:=1
+	Body string `json:"body"`
+}
+
+// DatabaseEventStatus defines the observed state of DatabaseEvent
+type DatabaseEventStatus struct {
+	// Timestamp identifying when the event was created
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CreationTime metav1.Time `json:"creationTime,omitEmpty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	SyncTime metav1.Time `json:"syncTime,omitEmpty"`
+	// Indicates current state, phase or issue
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitEmpty"`
+	// Name of the event on the server, used to rename or drop it
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitEmpty"`
+	// The definer as user@host
+	// +kubebuilder:validation:Optional
+	Definer string `json:"definer,omitEmpty"`
+	// SHA-256 of the statement last used to create or alter the event
+	// +kubebuilder:validation:Optional
+	Checksum string `json:"checksum,omitEmpty"`
+	// SHA-256 of the definition reported by INFORMATION_SCHEMA.EVENTS after the change, a difference indicates drift
+	// +kubebuilder:validation:Optional
+	ServerChecksum string `json:"serverChecksum,omitEmpty"`
+	// STATUS as reported by INFORMATION_SCHEMA.EVENTS, e.g. ENABLED or DISABLED
+	// +kubebuilder:validation:Optional
+	State string `json:"state,omitEmpty"`
+	// LAST_EXECUTED as reported by INFORMATION_SCHEMA.EVENTS
+	// +kubebuilder:validation:Optional
+	// +nullable
+	LastExecuted metav1.Time `json:"lastExecuted,omitEmpty"`
+	// Whether the event scheduler was running on the server at the last sync
+	// +kubebuilder:validation:Optional
+	SchedulerRunning bool `json:"schedulerRunning,omitEmpty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseName`
+// +kubebuilder:printcolumn:name="Event",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Last Executed",type=date,JSONPath=`.status.lastExecuted`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+
+// DatabaseEvent is the Schema for the databaseevents API
+type DatabaseEvent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatabaseEventSpec   `json:"spec,omitempty"`
+	Status DatabaseEventStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseEventList contains a list of DatabaseEvent
+type DatabaseEventList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseEvent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseEvent{}, &DatabaseEventList{})
+}
+
+// OneTime Whether the schedule is a single AT rather than EVERY
+func (in *DatabaseEventSpec) OneTime() bool {
+	fields := strings.Fields(in.Schedule)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "AT")
+}
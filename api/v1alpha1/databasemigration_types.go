@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseMigrationSpec defines the desired state of DatabaseMigration
+type DatabaseMigrationSpec struct {
+	// Name of the Database object in this namespace the migrations are applied to
+	// +kubebuilder:validation:MinLength:=1
+	Database string `json:"databaseName"`
+	// Name of the DatabaseUser in this namespace the migrations run as. It must be granted the Database and nothing
+	// else, and have a clear text password.
+	// +kubebuilder:validation:MinLength:=1
+	User string `json:"user"`
+	// Migrations applied in the order listed, each version exactly once
+	// +kubebuilder:validation:MinItems:=1
+	Migrations []Migration `json:"migrations"`
+}
+
+type Migration struct {
+	// Identifies the migration in the history table, must be unique for the database
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Version string `json:"version"`
+	// The SQL statements to apply
+	Script ScriptSource `json:"script"`
+}
+
+type AppliedMigration struct {
+	Version string `json:"version"`
+	// SHA-256 of the script as applied
+	Checksum string `json:"checksum"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	AppliedTime metav1.Time `json:"appliedTime,omitEmpty"`
+}
+
+// DatabaseMigrationStatus defines the observed state of DatabaseMigration
+type DatabaseMigrationStatus struct {
+	// +kubebuilder:validation:Optional
+	// +nullable
+	SyncTime metav1.Time `json:"syncTime,omitEmpty"`
+	// Indicates current state, phase or issue
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitEmpty"`
+	// The last version applied to the database
+	// +kubebuilder:validation:Optional
+	CurrentVersion string `json:"currentVersion,omitEmpty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Applied []AppliedMigration `json:"applied,omitEmpty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseName`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+
+// DatabaseMigration is the Schema for the databasemigrations API
+type DatabaseMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatabaseMigrationSpec   `json:"spec,omitempty"`
+	Status DatabaseMigrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseMigrationList contains a list of DatabaseMigration
+type DatabaseMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseMigration{}, &DatabaseMigrationList{})
+}
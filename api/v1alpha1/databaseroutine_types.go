@@ -0,0 +1,143 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RoutineType The kind of stored object
+// +kubebuilder:validation:Enum=VIEW;PROCEDURE;FUNCTION;TRIGGER
+type RoutineType string
+
+const (
+	RoutineView      RoutineType = "VIEW"
+	RoutineProcedure RoutineType = "PROCEDURE"
+	RoutineFunction  RoutineType = "FUNCTION"
+	RoutineTrigger   RoutineType = "TRIGGER"
+)
+
+// SqlSecurity Whose privileges a view or routine runs with
+// +kubebuilder:validation:Enum=DEFINER;INVOKER
+type SqlSecurity string
+
+const (
+	SqlSecurityDefiner SqlSecurity = "DEFINER"
+	SqlSecurityInvoker SqlSecurity = "INVOKER"
+)
+
+// DatabaseRoutineSpec defines the desired state of DatabaseRoutine
+type DatabaseRoutineSpec struct {
+	// Name of the Database object in this namespace holding the routine
+	// +kubebuilder:validation:MinLength:=1
+	Database string `json:"databaseName"`
+	// Name of the view, procedure, function or trigger in the schema
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string      `json:"name"`
+	Type RoutineType `json:"type"`
+	// Name of the DatabaseUser object in this namespace set as DEFINER. It must be created by the same
+	// AdminConnection as the Database.
+	// +kubebuilder:validation:MinLength:=1
+	Definer string `json:"definer"`
+	// SQL SECURITY of a view, procedure or function, triggers always run as the definer
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=DEFINER
+	SqlSecurity SqlSecurity `json:"sqlSecurity,omitempty"`
+	// Parameter list of a procedure or function without the surrounding parentheses,
+	// e.g. IN account BIGINT, OUT total DECIMAL(10,2)
+	// +kubebuilder:validation:Optional
+	Parameters string `json:"parameters,omitempty"`
+	// Return type of a function, e.g. DECIMAL(10,2)
+	// +kubebuilder:validation:Optional
+	Returns string `json:"returns,omitempty"`
+	// Characteristics of a procedure or function, e.g. DETERMINISTIC or READS SQL DATA
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Characteristics []string `json:"characteristics,omitempty"`
+	// Table a trigger is attached to
+	// +kubebuilder:validation:Optional
+	Table string `json:"table,omitempty"`
+	// BEFORE or AFTER for a trigger
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=BEFORE;AFTER
+	Timing string `json:"timing,omitempty"`
+	// INSERT, UPDATE or DELETE for a trigger
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=INSERT;UPDATE;DELETE
+	Event string `json:"event,omitempty"`
+	// The SELECT of a view, or the body of a procedure, function or trigger as written after its header
+	// +kubebuilder:validation:MinLength:=1
+	Body string `json:"body"`
+}
+
+// DatabaseRoutineStatus defines the observed state of DatabaseRoutine
+type DatabaseRoutineStatus struct {
+	// Timestamp identifying when the routine was last created or replaced
+	// +kubebuilder:validation:Optional
+	// +nullable
+	CreationTime metav1.Time `json:"creationTime,omitEmpty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	SyncTime metav1.Time `json:"syncTime,omitEmpty"`
+	// Indicates current state, phase or issue
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitEmpty"`
+	// Type and name of the object on the server, used to drop it when renamed or deleted
+	// +kubebuilder:validation:Optional
+	Type RoutineType `json:"type,omitEmpty"`
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitEmpty"`
+	// The definer as user@host
+	// +kubebuilder:validation:Optional
+	Definer string `json:"definer,omitEmpty"`
+	// SHA-256 of the statement last used to create the routine
+	// +kubebuilder:validation:Optional
+	Checksum string `json:"checksum,omitEmpty"`
+	// SHA-256 of the definition reported by INFORMATION_SCHEMA after creation, a change indicates drift
+	// +kubebuilder:validation:Optional
+	ServerChecksum string `json:"serverChecksum,omitEmpty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseName`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Name",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+
+// DatabaseRoutine is the Schema for the databaseroutines API
+type DatabaseRoutine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatabaseRoutineSpec   `json:"spec,omitempty"`
+	Status DatabaseRoutineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseRoutineList contains a list of DatabaseRoutine
+type DatabaseRoutineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseRoutine `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseRoutine{}, &DatabaseRoutineList{})
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseTableSpec defines the desired state of DatabaseTable
+type DatabaseTableSpec struct {
+	// Name of the Database object in this namespace holding the table
+	// +kubebuilder:validation:MinLength:=1
+	Database string `json:"databaseName"`
+	// Name of the table in the schema
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems:=1
+	Columns []TableColumn `json:"columns"`
+	// Columns making up the primary key, in order
+	// +kubebuilder:validation:Optional
+	// +nullable
+	PrimaryKey []string `json:"primaryKey,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Indexes []TableIndex `json:"indexes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ForeignKeys []TableForeignKey `json:"foreignKeys,omitempty"`
+	// Permits changes which may lose data, such as dropping a column or changing its type. Without it those
+	// changes are listed in status.pending and not applied.
+	// +kubebuilder:validation:Optional
+	AllowDestructiveChanges bool `json:"allowDestructiveChanges,omitempty"`
+}
+
+type TableColumn struct {
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// The column type as written in DDL, e.g. VARCHAR(255), BIGINT UNSIGNED or ENUM('new','done'). Lengths and
+	// attributes the server would report differently, e.g. TEXT(100) or BINARY, are not accepted
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:Pattern=`(?i)^(tinyint|smallint|mediumint|int|integer|bigint|bool|boolean|decimal|dec|numeric|fixed|float|double|double precision|real|bit|date|datetime|timestamp|time|year|char|character|varchar|character varying|binary|varbinary|tinytext|text|mediumtext|longtext|tinyblob|blob|mediumblob|longblob|enum|set|json) ?(\((?: ?(?:[0-9]+|'[^'\\]*') ?,)* ?(?:[0-9]+|'[^'\\]*') ?\))?((?: (?:unsigned|signed|zerofill|(?:character set|charset|collate) [a-z0-9_]+))*)$`
+	Type string `json:"type"`
+	// Whether NULL is permitted, primary key columns are never nullable
+	// +kubebuilder:validation:Optional
+	Nullable bool `json:"nullable,omitempty"`
+	// The default value, e.g. active or 0. NULL and CURRENT_TIMESTAMP (with an optional precision) are written as
+	// expressions, numbers as is and anything else as a string
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Default *string `json:"default,omitempty"`
+	// +kubebuilder:validation:Optional
+	AutoIncrement bool `json:"autoIncrement,omitempty"`
+}
+
+type TableIndex struct {
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems:=1
+	Columns []string `json:"columns"`
+	// +kubebuilder:validation:Optional
+	Unique bool `json:"unique,omitempty"`
+}
+
+// ReferentialAction What happens to referencing rows when the referenced row changes
+// +kubebuilder:validation:Enum=RESTRICT;CASCADE;SET NULL;NO ACTION
+type ReferentialAction string
+
+type TableForeignKey struct {
+	// Name of the constraint
+	// +kubebuilder:validation:MaxLength:=64
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems:=1
+	Columns []string `json:"columns"`
+	// Table in the same schema being referenced
+	ReferencedTable string `json:"referencedTable"`
+	// +kubebuilder:validation:MinItems:=1
+	ReferencedColumns []string `json:"referencedColumns"`
+	// +kubebuilder:validation:Optional
+	OnDelete ReferentialAction `json:"onDelete,omitempty"`
+	// +kubebuilder:validation:Optional
+	OnUpdate ReferentialAction `json:"onUpdate,omitempty"`
+}
+
+type AppliedStatement struct {
+	Statement string `json:"statement"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	AppliedTime metav1.Time `json:"appliedTime,omitEmpty"`
+}
+
+// DatabaseTableStatus defines the observed state of DatabaseTable
+type DatabaseTableStatus struct {
+	// +kubebuilder:validation:Optional
+	// +nullable
+	SyncTime metav1.Time `json:"syncTime,omitEmpty"`
+	// Indicates current state, phase or issue
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitEmpty"`
+	// DDL statements applied to the table, the most recent last
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Applied []AppliedStatement `json:"applied,omitEmpty"`
+	// Destructive statements awaiting spec.allowDestructiveChanges
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Pending []string `json:"pending,omitEmpty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type=string,JSONPath=`.spec.databaseName`
+// +kubebuilder:printcolumn:name="Table",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+
+// DatabaseTable is the Schema for the databasetables API
+type DatabaseTable struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatabaseTableSpec   `json:"spec,omitempty"`
+	Status DatabaseTableStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseTableList contains a list of DatabaseTable
+type DatabaseTableList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseTable `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseTable{}, &DatabaseTableList{})
+}
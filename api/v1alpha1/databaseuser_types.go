@@ -17,16 +17,30 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"github.com/cuppett/mysql-dba-operator/orm"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
+// DefaultHost The host of the account when spec.hosts is not given, which is any host.
+const DefaultHost = "%"
+
 // DatabaseUserSpec defines the desired state of DatabaseUser
 type DatabaseUserSpec struct {
 	AdminConnection AdminConnectionRef `json:"adminConnection"`
 	// +kubebuilder:validation:MaxLength:=32
 	// +kubebuilder:validation:MinLength:=1
 	Username string `json:"username"`
+	// Host patterns the account is limited to, one user@host account per entry (e.g. 10.128.%, app.example.com).
+	// Defaults to any host.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:MinLength:=1
+	// +kubebuilder:validation:items:MaxLength:=255
+	Hosts []string `json:"hosts,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +nullable
 	Identification *Identification `json:"identification,omitEmpty"`
@@ -34,16 +48,195 @@ type DatabaseUserSpec struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	DatabaseList []DatabasePermission `json:"databasePermissions,omitEmpty"`
+	// Privileges granted ON *.* (e.g. PROCESS), as far as the globalPrivilegePolicy of the AdminConnection permits
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:MinLength:=1
+	GlobalPrivileges []string `json:"globalPrivileges,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +nullable
 	TlsOptions TlsOptions `json:"tlsOptions,omitEmpty"`
+	// Limits on the use of the server by the account, defaulted and capped by the AdminConnection
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ResourceLimits *ResourceLimits `json:"resourceLimits,omitempty"`
+	// Password expiry, reuse and failed login options of the account, as far as the server supports them
+	// +kubebuilder:validation:Optional
+	// +nullable
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+	// Suspends the user with ACCOUNT LOCK, keeping the accounts and their privileges
+	// +kubebuilder:validation:Optional
+	Locked bool `json:"locked,omitempty"`
+	// Disconnects the sessions of the user when it is locked, otherwise they run until they disconnect
+	// +kubebuilder:validation:Optional
+	KillSessionsOnLock bool `json:"killSessionsOnLock,omitempty"`
+	// Generates a new password on a schedule, writing it to the authString Secret
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Rotation *PasswordRotation `json:"rotation,omitempty"`
+	// Issues a client certificate for the user from the certificate authority of the AdminConnection, requiring
+	// its subject and issuer on connection
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ClientCertificate *ClientCertificate `json:"clientCertificate,omitempty"`
+}
+
+// KeyType The private key algorithm of a client certificate
+type KeyType string
+
+const (
+	// KeyTypeECDSA An ECDSA P-256 key
+	KeyTypeECDSA KeyType = "ECDSA"
+	// KeyTypeRSA An RSA 2048 bit key
+	KeyTypeRSA KeyType = "RSA"
+)
+
+type ClientCertificate struct {
+	// Secret tls.crt, tls.key and ca.crt are written to, defaults to <name>-client-tls
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength:=253
+	SecretName string `json:"secretName,omitempty"`
+	// How long each certificate is valid for, renewed once two thirds have passed
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:="2160h"
+	Validity metav1.Duration `json:"validity,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=ECDSA;RSA
+	// +kubebuilder:default:=ECDSA
+	KeyType KeyType `json:"keyType,omitempty"`
+}
+
+type ClientCertificateStatus struct {
+	// The Secret holding the certificate
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+	// Subject of the certificate as compared by the server, e.g. /CN=myuser
+	// +kubebuilder:validation:Optional
+	Subject string `json:"subject,omitempty"`
+	// Issuer of the certificate as compared by the server
+	// +kubebuilder:validation:Optional
+	Issuer string `json:"issuer,omitempty"`
+	// +kubebuilder:validation:Optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +nullable
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+}
+
+type PasswordRotation struct {
+	// How often a new password is generated, e.g. 720h
+	Interval metav1.Duration `json:"interval"`
+	// How long the previous password keeps working once the new one is in the Secret, e.g. 1h. The interval must be
+	// more than twice as long.
+	GracePeriod metav1.Duration `json:"gracePeriod"`
+	// Secret key the username to connect with is written to, which changes during an AccountSwap rotation
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=username
+	UsernameKey string `json:"usernameKey,omitempty"`
+}
+
+// RotationStrategy How a password is rotated without breaking the sessions still using the previous one
+type RotationStrategy string
+
+const (
+	// RotationStrategyDualPassword Keeps the previous password as secondary with RETAIN CURRENT PASSWORD
+	// until DISCARD OLD PASSWORD (MySQL 8.0.14+)
+	RotationStrategyDualPassword RotationStrategy = "DualPassword"
+	// RotationStrategyAccountSwap Moves clients to a temporary copy of the account while the password changes
+	RotationStrategyAccountSwap RotationStrategy = "AccountSwap"
+)
+
+// RotationPhase The step a password rotation has reached
+type RotationPhase string
+
+const (
+	RotationPhaseIdle              RotationPhase = ""
+	RotationPhaseRetaining         RotationPhase = "RetainingOldPassword"
+	RotationPhaseMovingToAlternate RotationPhase = "MovingToAlternate"
+	RotationPhaseMovingBack        RotationPhase = "MovingBack"
+)
+
+type RotationStatus struct {
+	// +kubebuilder:validation:Optional
+	Strategy RotationStrategy `json:"strategy,omitempty"`
+	// +kubebuilder:validation:Optional
+	Phase RotationPhase `json:"phase,omitempty"`
+	// When the grace period of the current phase ends
+	// +kubebuilder:validation:Optional
+	// +nullable
+	PhaseEnd *metav1.Time `json:"phaseEnd,omitempty"`
+	// When the last rotation started
+	// +kubebuilder:validation:Optional
+	// +nullable
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// The temporary account of an AccountSwap rotation while it exists
+	// +kubebuilder:validation:Optional
+	AlternateUsername string `json:"alternateUsername,omitempty"`
 }
 
 type DatabasePermission struct {
 	Name string `json:"databaseName"`
-	// Allows specifying a specific permission list here (empty string indicates ALL)
+	// Allows specifying a specific permission list here (empty string indicates ALL, unless tables are given)
 	// +kubebuilder:validation:Optional
 	Grants []string `json:"grants"`
+	// Privileges on individual tables of the database, in addition to those on the whole database
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Tables []TablePermission `json:"tables,omitempty"`
+}
+
+type TablePermission struct {
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:MaxLength:=64
+	Name string `json:"name"`
+	// Privileges on the whole table (e.g. SELECT, INSERT)
+	// +kubebuilder:validation:Optional
+	Grants []string `json:"grants,omitempty"`
+	// Privileges on some columns of the table
+	// +kubebuilder:validation:Optional
+	Columns []ColumnPermission `json:"columns,omitempty"`
+}
+
+type ColumnPermission struct {
+	// +kubebuilder:validation:Enum=SELECT;INSERT;UPDATE;REFERENCES
+	Privilege string `json:"privilege"`
+	// +kubebuilder:validation:MinItems:=1
+	// +kubebuilder:validation:items:MinLength:=1
+	// +kubebuilder:validation:items:MaxLength:=64
+	Columns []string `json:"columns"`
+}
+
+// DatabaseLevel Whether privileges are granted on the whole database, which is ALL without grants unless only
+// tables are given.
+func (in *DatabasePermission) DatabaseLevel() bool {
+	return len(in.Grants) > 0 || len(in.Tables) == 0
+}
+
+// Privileges The privilege list of the GRANT on the table, column privileges naming their columns, e.g.
+// SELECT, UPDATE (`status`, `note`). Fails on privileges the server does not accept on tables or columns.
+func (in *TablePermission) Privileges(server *orm.ServerInfo) (string, error) {
+	grants, err := ParsePrivileges(in.Grants, PrivilegeLevelTable, server)
+	if err != nil {
+		return "", err
+	}
+	privileges := make([]string, 0, len(in.Grants)+len(in.Columns))
+	if len(grants) > 0 {
+		privileges = append(privileges, JoinPrivileges(grants))
+	}
+	for _, column := range in.Columns {
+		privilege, err := ParsePrivilege(column.Privilege, PrivilegeLevelColumn, server)
+		if err != nil {
+			return "", err
+		}
+		columns := make([]string, len(column.Columns))
+		for i, name := range column.Columns {
+			columns[i] = QuoteIdentifier(name)
+		}
+		privileges = append(privileges, string(privilege)+" ("+strings.Join(columns, ", ")+")")
+	}
+	return strings.Join(privileges, ", "), nil
 }
 
 type Identification struct {
@@ -60,11 +253,101 @@ type Identification struct {
 	ClearText bool `json:"clearText"`
 }
 
+// TlsOptions The REQUIRE options of CREATE USER. Subject, issuer and cipher may be combined and take precedence over
+// x509, which in turn takes precedence over required.
 type TlsOptions struct {
 	// Whether REQUIRE SSL or NONE
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:=false
 	Required bool `json:"required"`
+	// REQUIRE X509, connections must present a valid client certificate
+	// +kubebuilder:validation:Optional
+	X509 bool `json:"x509,omitempty"`
+	// REQUIRE SUBJECT, the client certificate must have this subject (e.g. /CN=app/O=example)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=1024
+	Subject string `json:"subject,omitempty"`
+	// REQUIRE ISSUER, the client certificate must be issued by this subject
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=1024
+	Issuer string `json:"issuer,omitempty"`
+	// REQUIRE CIPHER, connections must use this cipher
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=1024
+	Cipher string `json:"cipher,omitempty"`
+}
+
+// Requirement The REQUIRE options the TLS options amount to, SUBJECT, ISSUER and CIPHER joined by AND.
+func (in *TlsOptions) Requirement() string {
+	options := make([]string, 0, 3)
+	if in.Subject != "" {
+		options = append(options, "SUBJECT '"+Escape(in.Subject)+"'")
+	}
+	if in.Issuer != "" {
+		options = append(options, "ISSUER '"+Escape(in.Issuer)+"'")
+	}
+	if in.Cipher != "" {
+		options = append(options, "CIPHER '"+Escape(in.Cipher)+"'")
+	}
+	switch {
+	case len(options) > 0:
+		return strings.Join(options, " AND ")
+	case in.X509:
+		return "X509"
+	case in.Required:
+		return "SSL"
+	}
+	return "NONE"
+}
+
+// ResourceLimits The per account resource options of CREATE USER, where 0 is unlimited.
+type ResourceLimits struct {
+	// MAX_QUERIES_PER_HOUR, statements the account may issue per hour
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxQueriesPerHour *int32 `json:"maxQueriesPerHour,omitempty"`
+	// MAX_UPDATES_PER_HOUR, statements modifying data the account may issue per hour
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxUpdatesPerHour *int32 `json:"maxUpdatesPerHour,omitempty"`
+	// MAX_CONNECTIONS_PER_HOUR, times the account may connect per hour
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConnectionsPerHour *int32 `json:"maxConnectionsPerHour,omitempty"`
+	// MAX_USER_CONNECTIONS, simultaneous connections of the account
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxUserConnections *int32 `json:"maxUserConnections,omitempty"`
+}
+
+// PasswordPolicy The password options of CREATE USER. Options left out follow the server defaults.
+type PasswordPolicy struct {
+	// PASSWORD EXPIRE INTERVAL n DAY, 0 for PASSWORD EXPIRE NEVER (MySQL 5.7.4+, MariaDB 10.4.3+)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	ExpireIntervalDays *int32 `json:"expireIntervalDays,omitempty"`
+	// PASSWORD HISTORY, the number of previous passwords which may not be reused (MySQL 8.0.3+)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	History *int32 `json:"history,omitempty"`
+	// PASSWORD REUSE INTERVAL n DAY, the days before a password may be reused (MySQL 8.0.3+)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	ReuseIntervalDays *int32 `json:"reuseIntervalDays,omitempty"`
+	// PASSWORD REQUIRE CURRENT, or OPTIONAL when false (MySQL 8.0.13+)
+	// +kubebuilder:validation:Optional
+	RequireCurrent *bool `json:"requireCurrent,omitempty"`
+	// FAILED_LOGIN_ATTEMPTS, consecutive failures locking the account, 0 disables (MySQL 8.0.19+)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=32767
+	FailedLoginAttempts *int32 `json:"failedLoginAttempts,omitempty"`
+	// PASSWORD_LOCK_TIME, days the account stays locked after the failed logins, -1 until unlocked (MySQL 8.0.19+)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=-1
+	// +kubebuilder:validation:Maximum=32767
+	LockTimeDays *int32 `json:"lockTimeDays,omitempty"`
 }
 
 // DatabaseUserStatus defines the observed state of DatabaseUser
@@ -82,9 +365,18 @@ type DatabaseUserStatus struct {
 	// Indicates the current username we're working with in the database.
 	// +kubebuilder:validation:MaxLength:=32
 	Username string `json:"username,omitEmpty"`
+	// The usernameTemplate of the AdminConnection when the user was named
+	// +kubebuilder:validation:Optional
+	UsernameTemplate string `json:"usernameTemplate,omitempty"`
+	// The hosts the user@host accounts currently exist on in the database.
+	// +kubebuilder:validation:Optional
+	Hosts []string `json:"hosts,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +nullable
 	DatabaseList []DatabasePermission `json:"databasePermissions,omitEmpty"`
+	// The privileges granted ON *.*
+	// +kubebuilder:validation:Optional
+	GlobalPrivileges []string `json:"globalPrivileges,omitempty"`
 	// Identifies the current permissions of the user as indicated by SHOW GRANTS
 	// +kubebuilder:validation:Optional
 	// +nullable
@@ -98,6 +390,32 @@ type DatabaseUserStatus struct {
 	// +kubebuilder:validation:Optional
 	// +nullable
 	TlsOptions TlsOptions `json:"tlsOptions,omitEmpty"`
+	// The REQUIRE options in effect for the account (e.g. SSL, X509 or SUBJECT '/CN=app' AND ISSUER '/CN=ca')
+	// +kubebuilder:validation:Optional
+	TlsRequirement string `json:"tlsRequirement,omitempty"`
+	// The resource limits applied to the accounts
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ResourceLimits *ResourceLimits `json:"resourceLimits,omitempty"`
+	// The password policy of the account as read back from the server
+	// +kubebuilder:validation:Optional
+	// +nullable
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+	// Whether the account is locked on the server
+	// +kubebuilder:validation:Optional
+	Locked bool `json:"locked,omitempty"`
+	// Timestamp identifying when the account was locked
+	// +kubebuilder:validation:Optional
+	// +nullable
+	LockTime *metav1.Time `json:"lockTime,omitempty"`
+	// Progress of the password rotation
+	// +kubebuilder:validation:Optional
+	// +nullable
+	Rotation *RotationStatus `json:"rotation,omitempty"`
+	// The client certificate last issued
+	// +kubebuilder:validation:Optional
+	// +nullable
+	ClientCertificate *ClientCertificateStatus `json:"clientCertificate,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -125,10 +443,275 @@ func init() {
 	SchemeBuilder.Register(&DatabaseUser{}, &DatabaseUserList{})
 }
 
+// ReferencesDatabase Whether the database list includes the named Database object.
+func (in *DatabaseUserSpec) ReferencesDatabase(name string) bool {
+	for _, permission := range in.DatabaseList {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveHosts The hosts in the spec, sorted and without duplicates, or the default host when none are given.
+func (in *DatabaseUserSpec) EffectiveHosts() []string {
+	return normalizeHosts(in.Hosts)
+}
+
+// CurrentHosts The hosts the accounts exist on. Users created before hosts were tracked only exist on the default host.
+func (in *DatabaseUserStatus) CurrentHosts() []string {
+	return normalizeHosts(in.Hosts)
+}
+
+func normalizeHosts(hosts []string) []string {
+	if len(hosts) == 0 {
+		return []string{DefaultHost}
+	}
+	sorted := append([]string(nil), hosts...)
+	sort.Strings(sorted)
+	normalized := sorted[:1]
+	for _, host := range sorted[1:] {
+		if host != normalized[len(normalized)-1] {
+			normalized = append(normalized, host)
+		}
+	}
+	return normalized
+}
+
+// RotationDue When the next password rotation starts, zero without a rotation schedule.
+func (in *DatabaseUser) RotationDue() time.Time {
+	if in.Spec.Rotation == nil || in.Status.CreationTime.IsZero() {
+		return time.Time{}
+	}
+	last := in.Status.CreationTime.Time
+	if in.Status.Rotation != nil && in.Status.Rotation.LastRotationTime != nil {
+		last = in.Status.Rotation.LastRotationTime.Time
+	}
+	return last.Add(in.Spec.Rotation.Interval.Duration)
+}
+
+// ClientCertificateSecretName The Secret the client certificate is written to.
+func (in *DatabaseUser) ClientCertificateSecretName() string {
+	if in.Spec.ClientCertificate == nil || in.Spec.ClientCertificate.SecretName == "" {
+		return in.Name + "-client-tls"
+	}
+	return in.Spec.ClientCertificate.SecretName
+}
+
+// RenewalTime When the certificate is replaced, once two thirds of its validity have passed.
+func (in *ClientCertificateStatus) RenewalTime() time.Time {
+	if in.NotBefore == nil || in.NotAfter == nil {
+		return time.Time{}
+	}
+	return in.NotBefore.Add(in.NotAfter.Sub(in.NotBefore.Time) * 2 / 3)
+}
+
+// EffectiveTlsOptions The TLS options of the spec, requiring the subject and issuer of the client certificate once
+// one has been issued.
+func (in *DatabaseUser) EffectiveTlsOptions() TlsOptions {
+	options := in.Spec.TlsOptions
+	if in.Spec.ClientCertificate != nil && in.Status.ClientCertificate != nil {
+		options.Subject = in.Status.ClientCertificate.Subject
+		options.Issuer = in.Status.ClientCertificate.Issuer
+	}
+	return options
+}
+
+// resourceLimit One of the ResourceLimits with its option name in CREATE USER
+type resourceLimit struct {
+	option string
+	field  string
+	value  **int32
+}
+
+func (in *ResourceLimits) limits() []resourceLimit {
+	return []resourceLimit{
+		{"MAX_QUERIES_PER_HOUR", "maxQueriesPerHour", &in.MaxQueriesPerHour},
+		{"MAX_UPDATES_PER_HOUR", "maxUpdatesPerHour", &in.MaxUpdatesPerHour},
+		{"MAX_CONNECTIONS_PER_HOUR", "maxConnectionsPerHour", &in.MaxConnectionsPerHour},
+		{"MAX_USER_CONNECTIONS", "maxUserConnections", &in.MaxUserConnections},
+	}
+}
+
+func limitValue(value *int32) int32 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// WithClause The WITH clause of CREATE USER and ALTER USER setting every limit, unset ones to unlimited.
+func (in *ResourceLimits) WithClause() string {
+	clause := " WITH"
+	for _, limit := range in.limits() {
+		clause += fmt.Sprintf(" %s %d", limit.option, limitValue(*limit.value))
+	}
+	return clause
+}
+
+// EquivalentTo Whether both set the same limits, treating unset as unlimited.
+func (in *ResourceLimits) EquivalentTo(other *ResourceLimits) bool {
+	if other == nil {
+		other = &ResourceLimits{}
+	}
+	others := other.limits()
+	for i, limit := range in.limits() {
+		if limitValue(*limit.value) != limitValue(*others[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
+// EffectiveResourceLimits The resource limits for this user after applying the defaults and maximums of the
+// AdminConnection policy.
+func (in *DatabaseUser) EffectiveResourceLimits(adminConnection *AdminConnection) *ResourceLimits {
+
+	effective := &ResourceLimits{}
+	requested := &ResourceLimits{}
+	if in.Spec.ResourceLimits != nil {
+		requested = in.Spec.ResourceLimits
+	}
+	defaults := &ResourceLimits{}
+	maximums := &ResourceLimits{}
+	if policy := adminConnection.Spec.ResourceLimitPolicy; policy != nil {
+		if policy.Defaults != nil {
+			defaults = policy.Defaults
+		}
+		if policy.Maximums != nil {
+			maximums = policy.Maximums
+		}
+	}
+
+	requestedLimits, defaultLimits, maximumLimits := requested.limits(), defaults.limits(), maximums.limits()
+	for i, limit := range effective.limits() {
+		value := *requestedLimits[i].value
+		if value == nil {
+			value = *defaultLimits[i].value
+		}
+		// Unlimited is above any maximum
+		if maximum := limitValue(*maximumLimits[i].value); maximum > 0 &&
+			(limitValue(value) == 0 || limitValue(value) > maximum) {
+			value = *maximumLimits[i].value
+		}
+		if value != nil {
+			copied := *value
+			*limit.value = &copied
+		}
+	}
+	return effective
+}
+
+// Unsupported The options set which the server does not support.
+func (in *PasswordPolicy) Unsupported(server *orm.ServerInfo) []string {
+	unsupported := make([]string, 0)
+	if in.ExpireIntervalDays != nil && !server.SupportsPasswordExpire() {
+		unsupported = append(unsupported, "expireIntervalDays")
+	}
+	if in.History != nil && !server.SupportsPasswordReuse() {
+		unsupported = append(unsupported, "history")
+	}
+	if in.ReuseIntervalDays != nil && !server.SupportsPasswordReuse() {
+		unsupported = append(unsupported, "reuseIntervalDays")
+	}
+	if in.RequireCurrent != nil && !server.SupportsPasswordRequireCurrent() {
+		unsupported = append(unsupported, "requireCurrent")
+	}
+	if in.FailedLoginAttempts != nil && !server.SupportsFailedLoginTracking() {
+		unsupported = append(unsupported, "failedLoginAttempts")
+	}
+	if in.LockTimeDays != nil && !server.SupportsFailedLoginTracking() {
+		unsupported = append(unsupported, "lockTimeDays")
+	}
+	return unsupported
+}
+
+// Clause The password options of CREATE USER and ALTER USER for the server, options left out set back to the
+// server defaults and those the server lacks skipped.
+func (in *PasswordPolicy) Clause(server *orm.ServerInfo) string {
+	clause := ""
+	if server.SupportsPasswordExpire() {
+		if in.ExpireIntervalDays == nil {
+			clause += " PASSWORD EXPIRE DEFAULT"
+		} else if *in.ExpireIntervalDays == 0 {
+			clause += " PASSWORD EXPIRE NEVER"
+		} else {
+			clause += fmt.Sprintf(" PASSWORD EXPIRE INTERVAL %d DAY", *in.ExpireIntervalDays)
+		}
+	}
+	if server.SupportsPasswordReuse() {
+		if in.History == nil {
+			clause += " PASSWORD HISTORY DEFAULT"
+		} else {
+			clause += fmt.Sprintf(" PASSWORD HISTORY %d", *in.History)
+		}
+		if in.ReuseIntervalDays == nil {
+			clause += " PASSWORD REUSE INTERVAL DEFAULT"
+		} else {
+			clause += fmt.Sprintf(" PASSWORD REUSE INTERVAL %d DAY", *in.ReuseIntervalDays)
+		}
+	}
+	if server.SupportsPasswordRequireCurrent() {
+		if in.RequireCurrent == nil {
+			clause += " PASSWORD REQUIRE CURRENT DEFAULT"
+		} else if *in.RequireCurrent {
+			clause += " PASSWORD REQUIRE CURRENT"
+		} else {
+			clause += " PASSWORD REQUIRE CURRENT OPTIONAL"
+		}
+	}
+	if server.SupportsFailedLoginTracking() {
+		clause += fmt.Sprintf(" FAILED_LOGIN_ATTEMPTS %d", limitValue(in.FailedLoginAttempts))
+		if limitValue(in.LockTimeDays) < 0 {
+			clause += " PASSWORD_LOCK_TIME UNBOUNDED"
+		} else {
+			clause += fmt.Sprintf(" PASSWORD_LOCK_TIME %d", limitValue(in.LockTimeDays))
+		}
+	}
+	return clause
+}
+
+// EquivalentTo Whether the options the server supports are the same in both, where the failed login options
+// are off when left out.
+func (in *PasswordPolicy) EquivalentTo(other *PasswordPolicy, server *orm.ServerInfo) bool {
+	if other == nil {
+		other = &PasswordPolicy{}
+	}
+	if server.SupportsPasswordExpire() && !reflect.DeepEqual(in.ExpireIntervalDays, other.ExpireIntervalDays) {
+		return false
+	}
+	if server.SupportsPasswordReuse() && (!reflect.DeepEqual(in.History, other.History) ||
+		!reflect.DeepEqual(in.ReuseIntervalDays, other.ReuseIntervalDays)) {
+		return false
+	}
+	if server.SupportsPasswordRequireCurrent() && !reflect.DeepEqual(in.RequireCurrent, other.RequireCurrent) {
+		return false
+	}
+	if server.SupportsFailedLoginTracking() && (limitValue(in.FailedLoginAttempts) != limitValue(other.FailedLoginAttempts) ||
+		limitValue(in.LockTimeDays) != limitValue(other.LockTimeDays)) {
+		return false
+	}
+	return true
+}
+
 func (r *DatabaseUser) PermissionListEqual() bool {
+	return r.PermissionListEqualTo(r.Spec.DatabaseList)
+}
+
+// PermissionListEqualTo Compares the applied permissions with the list given, which differs from the spec while
+// a Database is withholding privileges.
+func (r *DatabaseUser) PermissionListEqualTo(list []DatabasePermission) bool {
 	// Always has GRANT USAGE as the first one. Only when we have something more complicated than
-	if len(r.Status.Grants) != len(r.Spec.DatabaseList) {
+	// Each of the accounts has its own grants, one for the database and one per table
+	statements := 0
+	for _, permission := range list {
+		if permission.DatabaseLevel() {
+			statements++
+		}
+		statements += len(permission.Tables)
+	}
+	if len(r.Status.Grants) != statements*len(r.Status.CurrentHosts()) {
 		return false
 	}
-	return reflect.DeepEqual(r.Spec.DatabaseList, r.Status.DatabaseList)
+	return reflect.DeepEqual(list, r.Status.DatabaseList)
 }
@@ -1,7 +1,9 @@
 package v1alpha1
 
 import (
+	"github.com/cuppett/mysql-dba-operator/orm"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -131,8 +133,220 @@ var _ = Describe("Database User", func() {
 			Expect(isEqual).To(BeFalse())
 		})
 
+		It("has the grants of every host", func() {
+			database_user.Spec.DatabaseList = []DatabasePermission{
+				{
+					Name:   "test1",
+					Grants: []string{"SELECT"},
+				}}
+
+			database_user.Status.Hosts = []string{"10.128.%", "localhost"}
+			database_user.Status.Grants = []string{
+				"GRANT SELECT ON `test1`.* TO `test`@`10.128.%`",
+				"GRANT SELECT ON `test1`.* TO `test`@`localhost`",
+			}
+			database_user.Status.DatabaseList = database_user.Spec.DatabaseList
+
+			isEqual := database_user.PermissionListEqual()
+			Expect(isEqual).To(BeTrue())
+		})
+
+		It("has the grants of every table", func() {
+			database_user.Spec.DatabaseList = []DatabasePermission{
+				{
+					Name: "test1",
+					Tables: []TablePermission{
+						{Name: "orders", Grants: []string{"SELECT"}},
+						{Name: "notes", Grants: []string{"SELECT"}},
+					},
+				}}
+
+			database_user.Status.Grants = []string{
+				"GRANT SELECT ON `test1`.`orders` TO `test`@`%`",
+				"GRANT SELECT ON `test1`.`notes` TO `test`@`%`",
+			}
+			database_user.Status.DatabaseList = database_user.Spec.DatabaseList
+
+			isEqual := database_user.PermissionListEqual()
+			Expect(isEqual).To(BeTrue())
+		})
+
 		AfterEach(func() {
 			database_user = nil
 		})
 	})
+
+	DescribeTable("Table privileges",
+		func(table TablePermission, expected string) {
+			privileges, err := table.Privileges(orm.ParseServerVersion("8.0.36"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(privileges).To(Equal(expected))
+		},
+		Entry("Table grants", TablePermission{Name: "t", Grants: []string{"select", "insert"}}, "SELECT, INSERT"),
+		Entry("Columns", TablePermission{Name: "t", Columns: []ColumnPermission{
+			{Privilege: "SELECT", Columns: []string{"id", "name"}}}}, "SELECT (`id`, `name`)"),
+		Entry("Both", TablePermission{Name: "t", Grants: []string{"SELECT"}, Columns: []ColumnPermission{
+			{Privilege: "UPDATE", Columns: []string{"odd`name"}}}}, "SELECT, UPDATE (`odd``name`)"),
+	)
+
+	DescribeTable("Privilege names",
+		func(name string, level PrivilegeLevel, version string, expected Privilege, expectedError string) {
+			var server *orm.ServerInfo
+			if version != "" {
+				server = orm.ParseServerVersion(version)
+			}
+			privilege, err := ParsePrivilege(name, level, server)
+			if expectedError != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(privilege).To(Equal(expected))
+		},
+		Entry("Normalised", " create  temporary tables", PrivilegeLevelDatabase, "8.0.36",
+			Privilege("CREATE TEMPORARY TABLES"), ""),
+		Entry("All", "all privileges", PrivilegeLevelTable, "8.0.36", Privilege("ALL PRIVILEGES"), ""),
+		Entry("Injection", "SELECT ON *.* TO 'x'@'%'; DROP DATABASE mysql; --", PrivilegeLevelDatabase, "8.0.36",
+			Privilege(""), "unknown database privilege"),
+		Entry("Grant option", "GRANT OPTION", PrivilegeLevelDatabase, "8.0.36", Privilege(""),
+			"unknown database privilege"),
+		Entry("Routine privileges are not for tables", "EXECUTE", PrivilegeLevelTable, "8.0.36", Privilege(""),
+			"unknown table privilege"),
+		Entry("Global on a database", "PROCESS", PrivilegeLevelDatabase, "8.0.36", Privilege(""),
+			"unknown database privilege"),
+		Entry("Data privilege globally", "insert", PrivilegeLevelGlobal, "8.0.36", Privilege(""),
+			"unknown global privilege"),
+		Entry("MariaDB data privilege globally", "DELETE HISTORY", PrivilegeLevelGlobal, "10.11.6-MariaDB",
+			Privilege(""), "unknown global privilege"),
+		Entry("MySQL dynamic privilege", "backup_admin", PrivilegeLevelGlobal, "8.0.36", Privilege("BACKUP_ADMIN"), ""),
+		Entry("MySQL privilege on MariaDB", "BACKUP_ADMIN", PrivilegeLevelGlobal,
+			"11.0.2-MariaDB-1:11.0.2+maria~ubu2204", Privilege(""), "unknown global privilege \"BACKUP_ADMIN\" for MariaDB"),
+		Entry("MariaDB privilege", "delete history", PrivilegeLevelTable, "10.11.6-MariaDB", Privilege("DELETE HISTORY"), ""),
+		Entry("Either flavor before the version is known", "BINLOG MONITOR", PrivilegeLevelGlobal, "",
+			Privilege("BINLOG MONITOR"), ""),
+	)
+
+	DescribeTable("EffectiveHosts",
+		func(hosts []string, expected []string) {
+			spec := DatabaseUserSpec{Username: "test", Hosts: hosts}
+			Expect(spec.EffectiveHosts()).To(Equal(expected))
+		},
+		Entry("Defaults to any host", nil, []string{"%"}),
+		Entry("Keeps a single host", []string{"10.128.%"}, []string{"10.128.%"}),
+		Entry("Sorts and removes duplicates", []string{"localhost", "10.128.%", "localhost"},
+			[]string{"10.128.%", "localhost"}),
+	)
+
+	DescribeTable("TLS requirement",
+		func(options TlsOptions, expected string) {
+			Expect(options.Requirement()).To(Equal(expected))
+		},
+		Entry("Nothing required", TlsOptions{}, "NONE"),
+		Entry("SSL", TlsOptions{Required: true}, "SSL"),
+		Entry("X509 over SSL", TlsOptions{Required: true, X509: true}, "X509"),
+		Entry("Subject alone", TlsOptions{Subject: "/CN=app"}, "SUBJECT '/CN=app'"),
+		Entry("Subject, issuer and cipher combined", TlsOptions{X509: true, Subject: "/CN=app", Issuer: "/CN=ca",
+			Cipher: "ECDHE-RSA-AES256-GCM-SHA384"},
+			"SUBJECT '/CN=app' AND ISSUER '/CN=ca' AND CIPHER 'ECDHE-RSA-AES256-GCM-SHA384'"),
+		Entry("Escaped", TlsOptions{Subject: "/O=Bob's/CN=app"}, "SUBJECT '/O=Bob\\'s/CN=app'"),
+	)
+
+	Describe("Client certificate", func() {
+		It("Renews once two thirds of the validity have passed", func() {
+			notBefore := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			notAfter := metav1.NewTime(notBefore.Add(90 * 24 * time.Hour))
+			status := &ClientCertificateStatus{NotBefore: &notBefore, NotAfter: &notAfter}
+			Expect(status.RenewalTime()).To(Equal(notBefore.Add(60 * 24 * time.Hour)))
+		})
+
+		It("Requires the subject and issuer once issued", func() {
+			user := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{Name: "app"},
+				Spec: DatabaseUserSpec{
+					Username:          "app",
+					TlsOptions:        TlsOptions{Cipher: "ECDHE-RSA-AES256-GCM-SHA384"},
+					ClientCertificate: &ClientCertificate{},
+				},
+			}
+			Expect(user.ClientCertificateSecretName()).To(Equal("app-client-tls"))
+			Expect(user.EffectiveTlsOptions()).To(Equal(user.Spec.TlsOptions))
+
+			user.Status.ClientCertificate = &ClientCertificateStatus{Subject: "/CN=app", Issuer: "/CN=ca"}
+			options := user.EffectiveTlsOptions()
+			Expect(options.Requirement()).To(Equal(
+				"SUBJECT '/CN=app' AND ISSUER '/CN=ca' AND CIPHER 'ECDHE-RSA-AES256-GCM-SHA384'"))
+		})
+	})
+
+	Describe("Resource limits", func() {
+		limit := func(value int32) *int32 {
+			return &value
+		}
+
+		DescribeTable("Policy and spec merging",
+			func(policy *ResourceLimitPolicy, requested *ResourceLimits, expected *ResourceLimits) {
+				adminConnection := &AdminConnection{Spec: AdminConnectionSpec{ResourceLimitPolicy: policy}}
+				user := &DatabaseUser{Spec: DatabaseUserSpec{Username: "limits", ResourceLimits: requested}}
+				Expect(user.EffectiveResourceLimits(adminConnection)).To(Equal(expected))
+			},
+			Entry("No limits anywhere", nil, nil, &ResourceLimits{}),
+			Entry("Spec only", nil, &ResourceLimits{MaxQueriesPerHour: limit(100)},
+				&ResourceLimits{MaxQueriesPerHour: limit(100)}),
+			Entry("Policy default", &ResourceLimitPolicy{Defaults: &ResourceLimits{MaxUserConnections: limit(10)}},
+				nil, &ResourceLimits{MaxUserConnections: limit(10)}),
+			Entry("Spec overrides default", &ResourceLimitPolicy{Defaults: &ResourceLimits{MaxUserConnections: limit(10)}},
+				&ResourceLimits{MaxUserConnections: limit(20)}, &ResourceLimits{MaxUserConnections: limit(20)}),
+			Entry("Capped at maximum", &ResourceLimitPolicy{Maximums: &ResourceLimits{MaxUserConnections: limit(50)}},
+				&ResourceLimits{MaxUserConnections: limit(100)}, &ResourceLimits{MaxUserConnections: limit(50)}),
+			Entry("Unlimited capped at maximum", &ResourceLimitPolicy{Maximums: &ResourceLimits{MaxUserConnections: limit(50)}},
+				&ResourceLimits{MaxUserConnections: limit(0)}, &ResourceLimits{MaxUserConnections: limit(50)}),
+		)
+
+		It("Sets every limit in the WITH clause", func() {
+			limits := &ResourceLimits{MaxUserConnections: limit(5)}
+			Expect(limits.WithClause()).To(Equal(" WITH MAX_QUERIES_PER_HOUR 0 MAX_UPDATES_PER_HOUR 0 " +
+				"MAX_CONNECTIONS_PER_HOUR 0 MAX_USER_CONNECTIONS 5"))
+		})
+
+		It("Treats unset as unlimited when comparing", func() {
+			limits := &ResourceLimits{MaxUserConnections: limit(5)}
+			Expect(limits.EquivalentTo(&ResourceLimits{MaxQueriesPerHour: limit(0), MaxUserConnections: limit(5)})).To(BeTrue())
+			Expect(limits.EquivalentTo(nil)).To(BeFalse())
+		})
+	})
+
+	Describe("Password policy", func() {
+		days := func(value int32) *int32 {
+			return &value
+		}
+		requireCurrent := true
+		policy := &PasswordPolicy{
+			ExpireIntervalDays:  days(90),
+			History:             days(5),
+			RequireCurrent:      &requireCurrent,
+			FailedLoginAttempts: days(3),
+			LockTimeDays:        days(-1),
+		}
+
+		DescribeTable("Rendering per server",
+			func(version string, clause string, unsupported []string) {
+				server := orm.ParseServerVersion(version)
+				Expect(policy.Clause(server)).To(Equal(clause))
+				Expect(policy.Unsupported(server)).To(Equal(unsupported))
+			},
+			Entry("MySQL 8.0", "8.0.36", " PASSWORD EXPIRE INTERVAL 90 DAY PASSWORD HISTORY 5 "+
+				"PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT FAILED_LOGIN_ATTEMPTS 3 "+
+				"PASSWORD_LOCK_TIME UNBOUNDED", []string{}),
+			Entry("MySQL 5.7", "5.7.44", " PASSWORD EXPIRE INTERVAL 90 DAY",
+				[]string{"history", "requireCurrent", "failedLoginAttempts", "lockTimeDays"}),
+			Entry("MariaDB", "11.0.2-MariaDB-1:11.0.2+maria~ubu2204", " PASSWORD EXPIRE INTERVAL 90 DAY",
+				[]string{"history", "requireCurrent", "failedLoginAttempts", "lockTimeDays"}),
+		)
+
+		It("Compares only the options of the server", func() {
+			server := orm.ParseServerVersion("10.11.6-MariaDB")
+			Expect(policy.EquivalentTo(&PasswordPolicy{ExpireIntervalDays: days(90)}, server)).To(BeTrue())
+			Expect(policy.EquivalentTo(&PasswordPolicy{}, server)).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,304 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+// log is for logging in this package.
+var databaseUserLog = logf.Log.WithName("databaseuser-resource")
+
+func (r *DatabaseUser) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	k8sClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-mysql-apps-cuppett-dev-v1alpha1-databaseuser,mutating=true,failurePolicy=fail,sideEffects=None,groups=mysql.apps.cuppett.dev,resources=databaseusers,verbs=create;update,versions=v1alpha1,name=mdatabaseuser.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &DatabaseUser{}
+
+// Default implements webhook.Defaulter so the resource limits defaulted by the AdminConnection are recorded in
+// the spec of the user.
+func (r *DatabaseUser) Default() {
+	databaseUserLog.Info("default", "namespace", r.Namespace, "name", r.Name)
+
+	if r.Spec.AdminConnection.Name == "" || r.GetDeletionTimestamp() != nil {
+		return
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil || adminConnection.Spec.ResourceLimitPolicy == nil ||
+		adminConnection.Spec.ResourceLimitPolicy.Defaults == nil {
+		return
+	}
+
+	if r.Spec.ResourceLimits == nil {
+		r.Spec.ResourceLimits = &ResourceLimits{}
+	}
+	defaults := adminConnection.Spec.ResourceLimitPolicy.Defaults.limits()
+	for i, limit := range r.Spec.ResourceLimits.limits() {
+		if *limit.value == nil && *defaults[i].value != nil {
+			value := **defaults[i].value
+			*limit.value = &value
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-mysql-apps-cuppett-dev-v1alpha1-databaseuser,mutating=false,failurePolicy=fail,sideEffects=None,groups=mysql.apps.cuppett.dev,resources=databaseusers,verbs=create;update,versions=v1alpha1,name=vdatabaseuser.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &DatabaseUser{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *DatabaseUser) ValidateCreate() (admission.Warnings, error) {
+	databaseUserLog.Info("validate create", "namespace", r.Namespace, "name", r.Name)
+
+	if err := r.ValidateResourceLimits(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateRotation(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateClientCertificate(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePermissions(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePrivileges(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateGlobalPrivileges(); err != nil {
+		return nil, err
+	}
+	return r.ValidatePasswordPolicy()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *DatabaseUser) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	databaseUserLog.Info("validate update", "namespace", r.Namespace, "name", r.Name)
+
+	// Rotations are seen through to the end
+	oldUser := old.(*DatabaseUser)
+	if r.Spec.Rotation == nil && oldUser.Status.Rotation != nil &&
+		oldUser.Status.Rotation.Phase != RotationPhaseIdle {
+		return nil, &validationError{"Rotation not allowed to be removed while a rotation is in progress"}
+	}
+
+	if err := r.ValidateResourceLimits(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateRotation(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateClientCertificate(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePermissions(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePrivileges(); err != nil {
+		return nil, err
+	}
+	if err := r.ValidateGlobalPrivileges(); err != nil {
+		return nil, err
+	}
+	return r.ValidatePasswordPolicy()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *DatabaseUser) ValidateDelete() (admission.Warnings, error) {
+	databaseUserLog.Info("validate delete", "namespace", r.Namespace, "name", r.Name)
+	// Not implemented
+	return nil, nil
+}
+
+// ValidateResourceLimits Rejects requested resource limits above the maximums of the AdminConnection policy.
+func (r *DatabaseUser) ValidateResourceLimits() error {
+
+	if r.Spec.ResourceLimits == nil || r.Spec.AdminConnection.Name == "" {
+		return nil
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		// The reconciler caps the limits once the AdminConnection is available
+		return nil
+	}
+	policy := adminConnection.Spec.ResourceLimitPolicy
+	if policy == nil || policy.Maximums == nil {
+		return nil
+	}
+
+	maximums := policy.Maximums.limits()
+	for i, limit := range r.Spec.ResourceLimits.limits() {
+		maximum := limitValue(*maximums[i].value)
+		if *limit.value == nil || maximum == 0 {
+			continue
+		}
+		if value := **limit.value; value == 0 || value > maximum {
+			return &validationError{fmt.Sprintf("Resource limit %s must be between 1 and %d as permitted by the "+
+				"AdminConnection", limit.field, maximum)}
+		}
+	}
+	return nil
+}
+
+// ValidateRotation Checks the rotation schedule leaves room for the grace periods and has a Secret to write to.
+func (r *DatabaseUser) ValidateRotation() error {
+
+	rotation := r.Spec.Rotation
+	if rotation == nil {
+		return nil
+	}
+	if r.Spec.Identification == nil || r.Spec.Identification.AuthString == nil || !r.Spec.Identification.ClearText {
+		return &validationError{"Rotation requires a clear text authString Secret"}
+	}
+	if rotation.GracePeriod.Duration <= 0 {
+		return &validationError{"Rotation gracePeriod must be positive"}
+	}
+	if rotation.Interval.Duration <= 2*rotation.GracePeriod.Duration {
+		return &validationError{"Rotation interval must be more than twice the gracePeriod"}
+	}
+	if rotation.UsernameKey == r.Spec.Identification.AuthString.SecretKeyRef.Key {
+		return &validationError{"Rotation usernameKey must differ from the authString key"}
+	}
+	return nil
+}
+
+// ValidateClientCertificate Checks a client certificate can be issued and is the only source of the subject and
+// issuer required.
+func (r *DatabaseUser) ValidateClientCertificate() error {
+
+	certificate := r.Spec.ClientCertificate
+	if certificate == nil {
+		return nil
+	}
+	if r.Spec.TlsOptions.Subject != "" || r.Spec.TlsOptions.Issuer != "" {
+		return &validationError{"TLS subject and issuer are set from the client certificate"}
+	}
+	if certificate.Validity.Duration < 0 {
+		return &validationError{"Client certificate validity must be positive"}
+	}
+	if certificate.SecretName != "" && r.Spec.Identification != nil && r.Spec.Identification.AuthString != nil &&
+		certificate.SecretName == r.Spec.Identification.AuthString.SecretKeyRef.Name {
+		return &validationError{"Client certificate secretName must differ from the authString Secret"}
+	}
+
+	if r.Spec.AdminConnection.Name == "" {
+		return nil
+	}
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		return nil
+	}
+	if adminConnection.Spec.ClientCertificateAuthority == nil {
+		return &validationError{"AdminConnection " + adminConnection.Name +
+			" has no clientCertificateAuthority to issue client certificates"}
+	}
+	return nil
+}
+
+// ValidatePermissions Checks every table permission grants something and names each table once.
+func (r *DatabaseUser) ValidatePermissions() error {
+
+	for _, permission := range r.Spec.DatabaseList {
+		tables := make(map[string]bool, len(permission.Tables))
+		for _, table := range permission.Tables {
+			if len(table.Grants) == 0 && len(table.Columns) == 0 {
+				return &validationError{"Table " + table.Name + " of database " + permission.Name +
+					" needs grants or columns"}
+			}
+			if tables[table.Name] {
+				return &validationError{"Table " + table.Name + " of database " + permission.Name +
+					" listed more than once"}
+			}
+			tables[table.Name] = true
+		}
+	}
+	return nil
+}
+
+// ValidatePrivileges Rejects privilege names the server of the AdminConnection does not know, or neither flavor
+// knows while its version is not.
+func (r *DatabaseUser) ValidatePrivileges() error {
+
+	var server *orm.ServerInfo
+	if r.Spec.AdminConnection.Name != "" {
+		adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+		if err == nil && adminConnection != nil && adminConnection.Status.ServerVersion != "" {
+			server = orm.ParseServerVersion(adminConnection.Status.ServerVersion)
+		}
+	}
+	if err := r.CheckPrivileges(server); err != nil {
+		return &validationError{"Invalid privileges, " + err.Error()}
+	}
+	return nil
+}
+
+// ValidateGlobalPrivileges Rejects global privileges the policy of the AdminConnection does not permit in the
+// namespace of the user.
+func (r *DatabaseUser) ValidateGlobalPrivileges() error {
+
+	if len(r.Spec.GlobalPrivileges) == 0 || r.Spec.AdminConnection.Name == "" {
+		return nil
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		// The reconciler leaves them out until the AdminConnection permits them
+		return nil
+	}
+	if disallowed := adminConnection.DisallowedGlobalPrivileges(r.Namespace, r.Spec.GlobalPrivileges); len(disallowed) > 0 {
+		return &validationError{"Global privileges " + strings.Join(disallowed, ", ") + " not permitted in namespace " +
+			r.Namespace + " by AdminConnection " + adminConnection.Name}
+	}
+	return nil
+}
+
+// ValidatePasswordPolicy Rejects password policy options the server of the AdminConnection does not support.
+func (r *DatabaseUser) ValidatePasswordPolicy() (admission.Warnings, error) {
+
+	if r.Spec.PasswordPolicy == nil || r.Spec.AdminConnection.Name == "" {
+		return nil, nil
+	}
+
+	adminConnection, err := GetAdminConnection(context.TODO(), k8sClient, r.Namespace, r.Spec.AdminConnection)
+	if err != nil || adminConnection == nil {
+		return nil, nil
+	}
+	if adminConnection.Status.ServerVersion == "" {
+		return admission.Warnings{"Password policy support of the server not yet known"}, nil
+	}
+
+	server := orm.ParseServerVersion(adminConnection.Status.ServerVersion)
+	if unsupported := r.Spec.PasswordPolicy.Unsupported(server); len(unsupported) > 0 {
+		return nil, &validationError{"Password policy " + strings.Join(unsupported, ", ") +
+			" not supported by " + server.Flavor + " " + server.Version}
+	}
+	return nil, nil
+}
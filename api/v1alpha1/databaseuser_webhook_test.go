@@ -0,0 +1,248 @@
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+var _ = Describe("DatabaseUser Webhook", func() {
+	var adminConnection *AdminConnection
+	var databaseUser *DatabaseUser
+	limit := func(value int32) *int32 {
+		return &value
+	}
+
+	BeforeEach(func() {
+		adminConnection = &AdminConnection{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "limited",
+				Namespace: "default",
+			},
+			Spec: AdminConnectionSpec{
+				Host: "localhost",
+				Port: 3306,
+				ResourceLimitPolicy: &ResourceLimitPolicy{
+					Defaults: &ResourceLimits{MaxUserConnections: limit(10)},
+					Maximums: &ResourceLimits{MaxUserConnections: limit(50)},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, adminConnection)
+		Expect(err).NotTo(HaveOccurred())
+
+		databaseUser = &DatabaseUser{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "limited",
+				Namespace: "default",
+			},
+			Spec: DatabaseUserSpec{
+				AdminConnection: AdminConnectionRef{
+					Name: adminConnection.Name,
+				},
+				Username: "limited",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		err := k8sClient.Delete(ctx, adminConnection)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should record the default resource limits", func() {
+		err := k8sClient.Create(ctx, databaseUser)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(databaseUser.Spec.ResourceLimits).NotTo(BeNil())
+		Expect(*databaseUser.Spec.ResourceLimits.MaxUserConnections).To(Equal(int32(10)))
+		Expect(databaseUser.Spec.ResourceLimits.MaxQueriesPerHour).To(BeNil())
+
+		err = k8sClient.Delete(ctx, databaseUser)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should allow limits within the maximums", func() {
+		databaseUser.Spec.ResourceLimits = &ResourceLimits{MaxUserConnections: limit(50), MaxQueriesPerHour: limit(0)}
+		err := k8sClient.Create(ctx, databaseUser)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Delete(ctx, databaseUser)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not allow limits above the maximums", func() {
+		databaseUser.Spec.ResourceLimits = &ResourceLimits{MaxUserConnections: limit(51)}
+		err := k8sClient.Create(ctx, databaseUser)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("maxUserConnections must be between 1 and 50"))
+	})
+
+	It("should not allow unlimited when there is a maximum", func() {
+		databaseUser.Spec.ResourceLimits = &ResourceLimits{MaxUserConnections: limit(0)}
+		err := k8sClient.Create(ctx, databaseUser)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Password policy", func() {
+		BeforeEach(func() {
+			adminConnection.Status.ServerVersion = "11.0.2-MariaDB-1:11.0.2+maria~ubu2204"
+			err := k8sClient.Status().Update(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should allow options the server supports", func() {
+			databaseUser.Spec.PasswordPolicy = &PasswordPolicy{ExpireIntervalDays: limit(90)}
+			err := k8sClient.Create(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not allow options the server lacks", func() {
+			databaseUser.Spec.PasswordPolicy = &PasswordPolicy{History: limit(5), FailedLoginAttempts: limit(3)}
+			err := k8sClient.Create(ctx, databaseUser)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Password policy history, failedLoginAttempts not supported by MariaDB"))
+		})
+	})
+
+	DescribeTable("Rotation rules",
+		func(rotation *PasswordRotation, identification *Identification, expectedError string) {
+			databaseUser.Spec.Rotation = rotation
+			databaseUser.Spec.Identification = identification
+			err := k8sClient.Create(ctx, databaseUser)
+			if expectedError != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedError))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("Valid schedule",
+			&PasswordRotation{Interval: metav1.Duration{Duration: 720 * time.Hour},
+				GracePeriod: metav1.Duration{Duration: time.Hour}, UsernameKey: "username"},
+			&Identification{ClearText: true, AuthString: &SecretKeySource{SecretKeyRef: v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "limited"}, Key: "password"}}}, ""),
+		Entry("Without a Secret",
+			&PasswordRotation{Interval: metav1.Duration{Duration: 720 * time.Hour},
+				GracePeriod: metav1.Duration{Duration: time.Hour}}, nil,
+			"Rotation requires a clear text authString Secret"),
+		Entry("Grace period too long",
+			&PasswordRotation{Interval: metav1.Duration{Duration: time.Hour},
+				GracePeriod: metav1.Duration{Duration: time.Hour}, UsernameKey: "username"},
+			&Identification{ClearText: true, AuthString: &SecretKeySource{SecretKeyRef: v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "limited"}, Key: "password"}}},
+			"Rotation interval must be more than twice the gracePeriod"),
+	)
+
+	DescribeTable("Client certificate rules",
+		func(authority *v1.LocalObjectReference, tlsOptions TlsOptions, expectedError string) {
+			adminConnection.Spec.ClientCertificateAuthority = authority
+			err := k8sClient.Update(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+
+			databaseUser.Spec.ClientCertificate = &ClientCertificate{KeyType: KeyTypeRSA}
+			databaseUser.Spec.TlsOptions = tlsOptions
+			err = k8sClient.Create(ctx, databaseUser)
+			if expectedError != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedError))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("With a certificate authority", &v1.LocalObjectReference{Name: "limited-ca"},
+			TlsOptions{Cipher: "ECDHE-RSA-AES256-GCM-SHA384"}, ""),
+		Entry("Without a certificate authority", nil, TlsOptions{},
+			"AdminConnection limited has no clientCertificateAuthority"),
+		Entry("With a subject of its own", &v1.LocalObjectReference{Name: "limited-ca"},
+			TlsOptions{Subject: "/CN=other"}, "TLS subject and issuer are set from the client certificate"),
+	)
+
+	DescribeTable("Table permission rules",
+		func(tables []TablePermission, expectedError string) {
+			databaseUser.Spec.DatabaseList = []DatabasePermission{{Name: "reports", Tables: tables}}
+			err := k8sClient.Create(ctx, databaseUser)
+			if expectedError != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedError))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("Grants and columns", []TablePermission{
+			{Name: "orders", Grants: []string{"SELECT"}},
+			{Name: "customers", Columns: []ColumnPermission{{Privilege: "SELECT", Columns: []string{"id", "name"}}}},
+		}, ""),
+		Entry("Nothing granted", []TablePermission{{Name: "orders"}},
+			"Table orders of database reports needs grants or columns"),
+		Entry("Listed twice", []TablePermission{
+			{Name: "orders", Grants: []string{"SELECT"}},
+			{Name: "orders", Grants: []string{"INSERT"}},
+		}, "Table orders of database reports listed more than once"),
+		Entry("Unknown column privilege", []TablePermission{
+			{Name: "orders", Columns: []ColumnPermission{{Privilege: "DELETE", Columns: []string{"id"}}}},
+		}, "Unsupported value"),
+	)
+
+	DescribeTable("Global privilege rules",
+		func(privileges []string, expectedError string) {
+			adminConnection.Spec.GlobalPrivilegePolicy = []GlobalPrivilegeRule{
+				{Namespaces: []string{"default"}, Privileges: []string{"PROCESS", "REPLICATION CLIENT"}},
+			}
+			err := k8sClient.Update(ctx, adminConnection)
+			Expect(err).NotTo(HaveOccurred())
+
+			databaseUser.Spec.GlobalPrivileges = privileges
+			err = k8sClient.Create(ctx, databaseUser)
+			if expectedError != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedError))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("Permitted", []string{"process", "REPLICATION CLIENT"}, ""),
+		Entry("Not permitted", []string{"PROCESS", "SUPER"},
+			"Global privileges SUPER not permitted in namespace default by AdminConnection limited"),
+	)
+
+	DescribeTable("Privilege name rules",
+		func(permissions []DatabasePermission, globalPrivileges []string, expectedError string) {
+			databaseUser.Spec.DatabaseList = permissions
+			databaseUser.Spec.GlobalPrivileges = globalPrivileges
+			err := k8sClient.Create(ctx, databaseUser)
+			if expectedError != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedError))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("Known privileges", []DatabasePermission{{Name: "reports", Grants: []string{"select", "Lock Tables"}}},
+			nil, ""),
+		Entry("Injected SQL", []DatabasePermission{{Name: "reports",
+			Grants: []string{"SELECT ON *.* TO 'limited'@'%'; DROP DATABASE mysql; --"}}}, nil,
+			"Invalid privileges, database reports: unknown database privilege"),
+		Entry("Grant option", []DatabasePermission{{Name: "reports", Grants: []string{"GRANT OPTION"}}}, nil,
+			"unknown database privilege \"GRANT OPTION\""),
+		Entry("Unknown on a table", []DatabasePermission{{Name: "reports",
+			Tables: []TablePermission{{Name: "orders", Grants: []string{"EXECUTE"}}}}}, nil,
+			"database reports table orders: unknown table privilege \"EXECUTE\""),
+		Entry("Unknown global privilege", nil, []string{"PROCESS, SUPER"},
+			"globalPrivileges: unknown global privilege"),
+	)
+})
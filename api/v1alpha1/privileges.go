@@ -0,0 +1,246 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"strings"
+)
+
+// Privilege A privilege name known to the server, upper case with single spaces (e.g. CREATE TEMPORARY TABLES).
+// Only names from the lists here become privileges, so they are safe to write into GRANT.
+type Privilege string
+
+// PrivilegeLevel What a privilege is granted on
+type PrivilegeLevel string
+
+const (
+	PrivilegeLevelGlobal   PrivilegeLevel = "global"
+	PrivilegeLevelDatabase PrivilegeLevel = "database"
+	PrivilegeLevelTable    PrivilegeLevel = "table"
+	PrivilegeLevelColumn   PrivilegeLevel = "column"
+)
+
+// DatabasePrivileges The privileges which ALL expands to at the database level on every supported server
+var DatabasePrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER",
+	"CREATE TEMPORARY TABLES", "LOCK TABLES", "EXECUTE", "CREATE VIEW", "SHOW VIEW", "CREATE ROUTINE",
+	"ALTER ROUTINE", "EVENT", "TRIGGER",
+}
+
+// TablePrivileges The privileges which ALL expands to at the table level
+var TablePrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER", "CREATE VIEW",
+	"SHOW VIEW", "TRIGGER",
+}
+
+// ColumnPrivileges The privileges which may be granted on individual columns
+var ColumnPrivileges = []string{"SELECT", "INSERT", "UPDATE", "REFERENCES"}
+
+// allPrivileges The names of ALL at the database and table level
+var allPrivileges = []string{"ALL", "ALL PRIVILEGES"}
+
+// globalPrivileges The static privileges only granted ON *.* on every supported server. GRANT OPTION is left out
+// everywhere so users cannot pass their privileges on, and the data privileges of DatabasePrivileges are not
+// accepted globally, where they would reach every schema on the server regardless of the quotas of its Databases.
+var globalPrivileges = []string{
+	"CREATE TABLESPACE", "CREATE USER", "FILE", "PROCESS", "RELOAD", "REPLICATION CLIENT", "REPLICATION SLAVE",
+	"SHOW DATABASES", "SHUTDOWN", "SUPER",
+}
+
+// mySQLGlobalPrivileges The global privileges particular to MySQL, including the dynamic privileges of 8.0
+var mySQLGlobalPrivileges = []string{
+	"CREATE ROLE", "DROP ROLE", "APPLICATION_PASSWORD_ADMIN", "AUDIT_ADMIN", "BACKUP_ADMIN", "BINLOG_ADMIN",
+	"BINLOG_ENCRYPTION_ADMIN", "CLONE_ADMIN", "CONNECTION_ADMIN", "ENCRYPTION_KEY_ADMIN", "FLUSH_OPTIMIZER_COSTS",
+	"FLUSH_STATUS", "FLUSH_TABLES", "FLUSH_USER_RESOURCES", "GROUP_REPLICATION_ADMIN", "INNODB_REDO_LOG_ARCHIVE",
+	"PERSIST_RO_VARIABLES_ADMIN", "REPLICATION_APPLIER", "REPLICATION_SLAVE_ADMIN", "RESOURCE_GROUP_ADMIN",
+	"RESOURCE_GROUP_USER", "ROLE_ADMIN", "SERVICE_CONNECTION_ADMIN", "SESSION_VARIABLES_ADMIN", "SET_USER_ID",
+	"SHOW_ROUTINE", "SYSTEM_USER", "SYSTEM_VARIABLES_ADMIN", "TABLE_ENCRYPTION_ADMIN", "XA_RECOVER_ADMIN",
+}
+
+// mariaDBPrivileges The database and table privileges particular to MariaDB
+var mariaDBPrivileges = []string{"DELETE HISTORY"}
+
+// mariaDBGlobalPrivileges The global privileges particular to MariaDB
+var mariaDBGlobalPrivileges = []string{
+	"BINLOG ADMIN", "BINLOG MONITOR", "BINLOG REPLAY", "CONNECTION ADMIN", "FEDERATED ADMIN", "READ_ONLY ADMIN",
+	"REPLICA MONITOR", "REPLICATION MASTER ADMIN", "REPLICATION SLAVE ADMIN", "SET USER", "SLAVE MONITOR",
+}
+
+// KnownPrivileges The privileges the server accepts at the level. Without a server (its version not yet known) those
+// of either flavor are accepted.
+func KnownPrivileges(level PrivilegeLevel, server *orm.ServerInfo) []string {
+	mySQL := server == nil || server.Version == "" || !server.IsMariaDB()
+	mariaDB := server == nil || server.Version == "" || server.IsMariaDB()
+
+	known := make([]string, 0)
+	switch level {
+	case PrivilegeLevelColumn:
+		return append(known, ColumnPrivileges...)
+	case PrivilegeLevelTable:
+		known = append(append(known, TablePrivileges...), allPrivileges...)
+	case PrivilegeLevelDatabase:
+		known = append(append(known, DatabasePrivileges...), allPrivileges...)
+	case PrivilegeLevelGlobal:
+		known = append(known, globalPrivileges...)
+		if mySQL {
+			known = append(known, mySQLGlobalPrivileges...)
+		}
+		if mariaDB {
+			known = append(known, mariaDBGlobalPrivileges...)
+		}
+		return known
+	}
+	if mariaDB && level != PrivilegeLevelColumn {
+		known = append(known, mariaDBPrivileges...)
+	}
+	return known
+}
+
+// ParsePrivilege The privilege named, when the server accepts it at the level.
+func ParsePrivilege(name string, level PrivilegeLevel, server *orm.ServerInfo) (Privilege, error) {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(name), " "))
+	for _, known := range KnownPrivileges(level, server) {
+		if normalized == known {
+			return Privilege(known), nil
+		}
+	}
+	flavor := "the server"
+	if server != nil && server.Version != "" {
+		flavor = server.Flavor
+	}
+	return "", fmt.Errorf("unknown %s privilege %q for %s", level, name, flavor)
+}
+
+// ParsePrivileges The privileges named, stopping at the first the server does not accept at the level.
+func ParsePrivileges(names []string, level PrivilegeLevel, server *orm.ServerInfo) ([]Privilege, error) {
+	privileges := make([]Privilege, 0, len(names))
+	for _, name := range names {
+		privilege, err := ParsePrivilege(name, level, server)
+		if err != nil {
+			return nil, err
+		}
+		privileges = append(privileges, privilege)
+	}
+	return privileges, nil
+}
+
+// JoinPrivileges The privilege list of a GRANT.
+func JoinPrivileges(privileges []Privilege) string {
+	names := make([]string, len(privileges))
+	for i, privilege := range privileges {
+		names[i] = string(privilege)
+	}
+	return strings.Join(names, ", ")
+}
+
+// CheckPrivileges Checks every privilege the user asks for is one the server accepts where it is granted.
+func (in *DatabaseUser) CheckPrivileges(server *orm.ServerInfo) error {
+	for _, permission := range in.Spec.DatabaseList {
+		if _, err := ParsePrivileges(permission.Grants, PrivilegeLevelDatabase, server); err != nil {
+			return fmt.Errorf("database %s: %w", permission.Name, err)
+		}
+		for _, table := range permission.Tables {
+			if _, err := table.Privileges(server); err != nil {
+				return fmt.Errorf("database %s table %s: %w", permission.Name, table.Name, err)
+			}
+		}
+	}
+	if _, err := ParsePrivileges(in.Spec.GlobalPrivileges, PrivilegeLevelGlobal, server); err != nil {
+		return fmt.Errorf("globalPrivileges: %w", err)
+	}
+	return nil
+}
+
+// ReadOnlyPrivileges The privileges left in place when a database is made read only through grants
+var ReadOnlyPrivileges = []string{"SELECT"}
+
+// WritePrivileges The privileges withheld by the RevokeWrite quota action
+var WritePrivileges = []string{"INSERT", "UPDATE"}
+
+// CleanupPrivileges The privileges left in place by the ReadOnly quota action, enough to bring usage back under
+// the limit
+var CleanupPrivileges = []string{"SELECT", "DELETE", "DROP"}
+
+// EffectiveGrants The grants from a DatabasePermission after removing those currently withheld on this database.
+// An empty list (ALL) is expanded so individual privileges can be removed from it.
+func (in *Database) EffectiveGrants(grants []string) []string {
+	if len(in.Status.RevokedPrivileges) == 0 {
+		return grants
+	}
+	if len(grants) == 0 || containsFold(grants, "ALL") || containsFold(grants, "ALL PRIVILEGES") {
+		grants = DatabasePrivileges
+	}
+	effective := make([]string, 0, len(grants))
+	for _, grant := range grants {
+		if !containsFold(in.Status.RevokedPrivileges, grant) {
+			effective = append(effective, grant)
+		}
+	}
+	return effective
+}
+
+// EffectiveTables The table permissions from a DatabasePermission after removing the privileges currently withheld
+// on this database. Tables left without privileges are left out.
+func (in *Database) EffectiveTables(tables []TablePermission) []TablePermission {
+	if len(in.Status.RevokedPrivileges) == 0 {
+		return tables
+	}
+	var effective []TablePermission
+	for _, table := range tables {
+		grants := table.Grants
+		if containsFold(grants, "ALL") || containsFold(grants, "ALL PRIVILEGES") {
+			grants = TablePrivileges
+		}
+		kept := TablePermission{Name: table.Name}
+		for _, grant := range grants {
+			if !containsFold(in.Status.RevokedPrivileges, grant) {
+				kept.Grants = append(kept.Grants, grant)
+			}
+		}
+		for _, column := range table.Columns {
+			if !containsFold(in.Status.RevokedPrivileges, column.Privilege) {
+				kept.Columns = append(kept.Columns, column)
+			}
+		}
+		if len(kept.Grants) > 0 || len(kept.Columns) > 0 {
+			effective = append(effective, kept)
+		}
+	}
+	return effective
+}
+
+// PrivilegesExcept All database privileges other than those given
+func PrivilegesExcept(kept []string) []string {
+	others := make([]string, 0, len(DatabasePrivileges))
+	for _, privilege := range DatabasePrivileges {
+		if !containsFold(kept, privilege) {
+			others = append(others, privilege)
+		}
+	}
+	return others
+}
+
+func containsFold(list []string, value string) bool {
+	for _, entry := range list {
+		if strings.EqualFold(strings.TrimSpace(entry), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
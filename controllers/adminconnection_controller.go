@@ -74,6 +74,11 @@ func (r *AdminConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	instance.Status.SyncTime = metav1.NewTime(time.Now())
 	defer r.Status().Update(ctx, instance)
 
+	if err := instance.ValidateNameTemplates(); err != nil {
+		instance.Status.Message = err.Error()
+		return ctrl.Result{}, nil
+	}
+
 	// Establish the database connection
 	db, err := instance.GetDatabaseConnection(ctx, r.Client, r.Connections)
 	if err != nil {
@@ -93,12 +98,25 @@ func (r *AdminConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	instance.Status.AvailableCharsets, err = r.getCharSets(db)
+	instance.Status.AvailableCharsets, err = mysqlv1alpha1.GetAvailableCharsets(db)
 	if err != nil {
 		instance.Status.Message = "Failed to retrieve available character sets"
 		return ctrl.Result{}, err
 	}
 
+	server, err := orm.GetServerInfo(db)
+	if err != nil {
+		instance.Status.Message = "Failed to retrieve server version"
+		return ctrl.Result{}, err
+	}
+	instance.Status.ServerVersion = server.Version
+	instance.Status.KeyringAvailable = r.keyringAvailable(db)
+	instance.Status.EventScheduler, err = r.getVariable("event_scheduler", db)
+	if err != nil {
+		instance.Status.Message = "Failed to retrieve event scheduler state"
+		return ctrl.Result{}, err
+	}
+
 	instance.Status.Message = "Successfully pinged database"
 	instance.Status.ControlDatabase = orm.DatabaseName
 	return ctrl.Result{}, nil
@@ -120,43 +138,29 @@ func (r *AdminConnectionReconciler) getVariable(name string, db *gorm.DB) (strin
 	return results[0]["Value"].(string), nil
 }
 
-func (r *AdminConnectionReconciler) getCharSets(db *gorm.DB) ([]mysqlv1alpha1.Charset, error) {
+// keyringAvailable Whether a keyring plugin, or on newer servers a keyring component, is active.
+func (r *AdminConnectionReconciler) keyringAvailable(db *gorm.DB) bool {
 
-	var toReturn []mysqlv1alpha1.Charset
+	var count int64
+	tx := db.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME LIKE 'keyring%' " +
+		"AND PLUGIN_STATUS = 'ACTIVE'").Scan(&count)
+	if tx.Error == nil && count > 0 {
+		return true
+	}
 
-	query := "SHOW COLLATION WHERE Charset IS NOT NULL"
+	// Components report themselves here (MySQL 8.0.24+), the table is absent elsewhere
 	var results []map[string]interface{}
-	tx := db.Raw(query).Scan(&results)
+	tx = db.Raw("SELECT * FROM performance_schema.keyring_component_status").Scan(&results)
 	if tx.Error != nil {
-		return toReturn, tx.Error
+		return false
 	}
-
-	var collation, charset string
-	var isDefault bool
-	var sortedResults map[string][]mysqlv1alpha1.Collation
-	sortedResults = make(map[string][]mysqlv1alpha1.Collation)
 	for _, row := range results {
-		collation = row["Collation"].(string)
-		charset = row["Charset"].(string)
-		isDefault = row["Default"].(string) == "Yes"
-
-		if _, ok := sortedResults[charset]; !ok {
-			sortedResults[charset] = make([]mysqlv1alpha1.Collation, 0)
+		if fmt.Sprintf("%v", row["STATUS_KEY"]) == "Component_status" &&
+			fmt.Sprintf("%v", row["STATUS_VALUE"]) == "Active" {
+			return true
 		}
-		sortedResults[charset] = append(sortedResults[charset], mysqlv1alpha1.Collation{
-			Name:    collation,
-			Default: isDefault,
-		})
-	}
-
-	for charset, collations := range sortedResults {
-		toReturn = append(toReturn, mysqlv1alpha1.Charset{
-			Name:       charset,
-			Collations: collations,
-		})
 	}
-
-	return toReturn, nil
+	return false
 }
 
 // SetupWithManager sets up the controller with the Manager.
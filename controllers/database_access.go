@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"sort"
+	"strings"
+)
+
+// databaseAccess Applies the restrictions required by spec.readOnly and the quota action, see accessRestrictions.
+// The DatabaseUser reconciler narrows and later restores grants to match status.revokedPrivileges.
+func (r *DatabaseReconciler) databaseAccess(loop *DatabaseLoopContext) (bool, error) {
+
+	readOnly := loop.instance.Spec.ReadOnly
+
+	var server *orm.ServerInfo
+	if readOnly || loop.instance.Status.ReadOnly {
+		var err error
+		server, err = orm.GetServerInfo(loop.db)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	changed := false
+	serverReadOnly, revoked, alterQuery := accessRestrictions(loop.instance, loop.name, server)
+	if alterQuery != "" {
+		tx := loop.db.Exec(alterQuery)
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to alter database.", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.name, "Query", alterQuery)
+			return false, tx.Error
+		}
+		r.Log.Info("Successfully altered database", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name, "ReadOnly", serverReadOnly)
+		changed = true
+	}
+	loop.instance.Status.ReadOnly = serverReadOnly
+
+	if strings.Join(revoked, ",") != strings.Join(loop.instance.Status.RevokedPrivileges, ",") {
+		changed = true
+	}
+	loop.instance.Status.RevokedPrivileges = revoked
+
+	switch {
+	case readOnly:
+		loop.instance.Status.Mode = mysqlv1alpha1.DatabaseModeReadOnly
+	case len(revoked) > 0:
+		loop.instance.Status.Mode = mysqlv1alpha1.DatabaseModeRestricted
+	default:
+		loop.instance.Status.Mode = mysqlv1alpha1.DatabaseModeReadWrite
+	}
+	return changed, nil
+}
+
+// accessRestrictions Whether the schema should have the READ ONLY option, the privileges withheld from DatabaseUsers
+// (upper case and sorted, nil for none) and the ALTER DATABASE needed to set or clear READ ONLY. spec.readOnly uses
+// the schema option where the server has it, otherwise every privilege but SELECT is withheld. The server is only
+// consulted when read only is wanted or currently set.
+func accessRestrictions(database *mysqlv1alpha1.Database, name string,
+	server *orm.ServerInfo) (bool, []string, string) {
+
+	revoked := quotaRestrictions(database)
+	readOnly := database.Spec.ReadOnly
+
+	serverReadOnly := false
+	alterQuery := ""
+	if readOnly || database.Status.ReadOnly {
+		if readOnly && server.SupportsReadOnlySchema() {
+			serverReadOnly = true
+		} else if readOnly {
+			revoked = append(mysqlv1alpha1.PrivilegesExcept(mysqlv1alpha1.ReadOnlyPrivileges), revoked...)
+		}
+
+		if serverReadOnly != database.Status.ReadOnly && server.SupportsReadOnlySchema() {
+			option := "0"
+			if serverReadOnly {
+				option = "1"
+			}
+			alterQuery = "ALTER DATABASE " + mysqlv1alpha1.QuoteIdentifier(name) + " READ ONLY = " + option
+		}
+	}
+
+	unique := make([]string, 0, len(revoked))
+	for _, privilege := range revoked {
+		privilege = strings.ToUpper(privilege)
+		if !contains(unique, privilege) {
+			unique = append(unique, privilege)
+		}
+	}
+	sort.Strings(unique)
+	if len(unique) == 0 {
+		unique = nil
+	}
+	return serverReadOnly, unique, alterQuery
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"github.com/cuppett/mysql-dba-operator/orm"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+var _ = Describe("Database access", func() {
+
+	readOnlyDatabase := func() *Database {
+		return &Database{
+			Spec: DatabaseSpec{
+				Name:     "frozen",
+				ReadOnly: true,
+			},
+		}
+	}
+
+	It("Sets READ ONLY on MySQL 8.0.22 and later", func() {
+		database := readOnlyDatabase()
+		serverReadOnly, revoked, alterQuery := accessRestrictions(database, "frozen",
+			orm.ParseServerVersion("8.0.22"))
+		Expect(serverReadOnly).To(BeTrue())
+		Expect(revoked).To(BeNil())
+		Expect(alterQuery).To(Equal("ALTER DATABASE `frozen` READ ONLY = 1"))
+
+		// Nothing further once the server has it
+		database.Status.ReadOnly = true
+		_, _, alterQuery = accessRestrictions(database, "frozen", orm.ParseServerVersion("8.0.22"))
+		Expect(alterQuery).To(BeEmpty())
+	})
+
+	It("Clears READ ONLY when no longer wanted", func() {
+		database := readOnlyDatabase()
+		database.Spec.ReadOnly = false
+		database.Status.ReadOnly = true
+		serverReadOnly, revoked, alterQuery := accessRestrictions(database, "frozen",
+			orm.ParseServerVersion("8.0.36"))
+		Expect(serverReadOnly).To(BeFalse())
+		Expect(revoked).To(BeNil())
+		Expect(alterQuery).To(Equal("ALTER DATABASE `frozen` READ ONLY = 0"))
+	})
+
+	DescribeTable("Withholds all but SELECT elsewhere",
+		func(version string) {
+			database := readOnlyDatabase()
+			serverReadOnly, revoked, alterQuery := accessRestrictions(database, "frozen",
+				orm.ParseServerVersion(version))
+			Expect(serverReadOnly).To(BeFalse())
+			Expect(alterQuery).To(BeEmpty())
+			Expect(revoked).To(ContainElements("INSERT", "UPDATE", "DELETE", "DROP", "ALTER"))
+			Expect(revoked).NotTo(ContainElement("SELECT"))
+
+			// The DatabaseUser grants narrowed accordingly
+			database.Status.RevokedPrivileges = revoked
+			Expect(database.EffectiveGrants(nil)).To(Equal([]string{"SELECT"}))
+			Expect(database.EffectiveGrants([]string{"ALL"})).To(Equal([]string{"SELECT"}))
+			Expect(database.EffectiveGrants([]string{"SELECT", "INSERT", "UPDATE"})).To(Equal([]string{"SELECT"}))
+		},
+		Entry("MySQL 8.0.21", "8.0.21"),
+		Entry("MySQL 5.7", "5.7.44"),
+		Entry("MariaDB", "10.11.6-MariaDB"),
+	)
+
+	It("Withholds writes for the RevokeWrite quota action", func() {
+		database := &Database{
+			Spec: DatabaseSpec{Name: "full"},
+			Status: DatabaseStatus{
+				Quota: &QuotaStatus{State: "Exceeded", Action: QuotaActionRevokeWrite},
+			},
+		}
+		serverReadOnly, revoked, alterQuery := accessRestrictions(database, "full", nil)
+		Expect(serverReadOnly).To(BeFalse())
+		Expect(alterQuery).To(BeEmpty())
+		Expect(revoked).To(Equal([]string{"INSERT", "UPDATE"}))
+
+		database.Status.RevokedPrivileges = revoked
+		Expect(database.EffectiveGrants([]string{"SELECT", "INSERT", "DELETE"})).To(Equal([]string{"SELECT", "DELETE"}))
+	})
+
+	DescribeTable("Leaves cleaning up for the ReadOnly quota action",
+		func(version string) {
+			database := &Database{
+				Spec: DatabaseSpec{Name: "full"},
+				Status: DatabaseStatus{
+					Quota: &QuotaStatus{State: "Exceeded", Action: QuotaActionReadOnly},
+				},
+			}
+			serverReadOnly, revoked, alterQuery := accessRestrictions(database, "full",
+				orm.ParseServerVersion(version))
+			Expect(serverReadOnly).To(BeFalse())
+			Expect(alterQuery).To(BeEmpty())
+			Expect(revoked).To(ContainElements("INSERT", "UPDATE", "CREATE", "ALTER"))
+
+			database.Status.RevokedPrivileges = revoked
+			Expect(database.EffectiveGrants(nil)).To(ConsistOf("SELECT", "DELETE", "DROP"))
+
+			// A schema left READ ONLY by an earlier version is cleared
+			database.Status.ReadOnly = true
+			_, _, alterQuery = accessRestrictions(database, "full", orm.ParseServerVersion(version))
+			if orm.ParseServerVersion(version).SupportsReadOnlySchema() {
+				Expect(alterQuery).To(Equal("ALTER DATABASE `full` READ ONLY = 0"))
+			} else {
+				Expect(alterQuery).To(BeEmpty())
+			}
+		},
+		Entry("MySQL 8.0.36", "8.0.36"),
+		Entry("MySQL 5.7", "5.7.44"),
+	)
+})
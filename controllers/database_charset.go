@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// databaseCharsetValid Checks the requested character set and collation against the server and records the
+// InvalidCharset condition. The webhook admits them unchecked in lenient mode when the AdminConnection has not
+// yet synced, so they are confirmed here before being written into any statement.
+func (r *DatabaseReconciler) databaseCharsetValid(loop *DatabaseLoopContext) (bool, error) {
+
+	charset := loop.instance.Spec.CharacterSet
+	collation := loop.instance.Spec.Collate
+	if charset == "" && collation == "" {
+		meta.RemoveStatusCondition(&loop.instance.Status.Conditions, mysqlv1alpha1.DatabaseInvalidCharset)
+		return true, nil
+	}
+
+	query := "SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLLATIONS WHERE 1 = 1"
+	params := make([]interface{}, 0, 2)
+	if charset != "" {
+		query += " AND CHARACTER_SET_NAME = ?"
+		params = append(params, charset)
+	}
+	if collation != "" {
+		query += " AND COLLATION_NAME = ?"
+		params = append(params, collation)
+	}
+	var count int64
+	tx := loop.db.Raw(query, params...).Scan(&count)
+	if tx.Error != nil {
+		r.Log.Error(tx.Error, "Failed to check character set and collation", "Host",
+			loop.adminConnection.Spec.Host, "Name", loop.name)
+		return false, tx.Error
+	}
+
+	valid := count > 0
+	message := "Character set and collation available on the server"
+	if !valid {
+		message = "Character set " + charset + " and collation " + collation + " not valid for this server"
+	}
+	r.setCondition(loop, mysqlv1alpha1.DatabaseInvalidCharset, !valid, "NotAvailable", "Available", message)
+	return valid, nil
+}
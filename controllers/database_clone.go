@@ -0,0 +1,269 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of rows copied per INSERT ... SELECT when cloning tables with a primary key
+	cloneChunkSize = 10000
+)
+
+type cloneTable struct {
+	TableName string `gorm:"column:TABLE_NAME"`
+	TableType string `gorm:"column:TABLE_TYPE"`
+}
+
+// cloneSource Fetches the source Database and confirms it may be copied into this one.
+func (r *DatabaseReconciler) cloneSource(ctx context.Context, loop *DatabaseLoopContext) (*mysqlv1alpha1.Database, error) {
+
+	sourceName := types.NamespacedName{
+		Namespace: loop.instance.Namespace,
+		Name:      loop.instance.Spec.CloneFrom.Name,
+	}
+	if loop.instance.Spec.CloneFrom.Namespace != "" {
+		sourceName.Namespace = loop.instance.Spec.CloneFrom.Namespace
+	}
+
+	source := &mysqlv1alpha1.Database{}
+	err := r.Client.Get(ctx, sourceName, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !source.CloneAllowed(loop.instance.Namespace) {
+		return nil, fmt.Errorf("database %v does not permit cloning into namespace %s", sourceName, loop.instance.Namespace)
+	}
+
+	// Only copying within the same server, which we only know to be true for the same AdminConnection.
+	sourceAdmin, err := mysqlv1alpha1.GetAdminConnection(ctx, r.Client, source.Namespace, source.Spec.AdminConnection)
+	if err != nil {
+		return nil, err
+	}
+	if sourceAdmin == nil || sourceAdmin.UID != loop.adminConnection.UID {
+		return nil, fmt.Errorf("database %v is not on the same AdminConnection", sourceName)
+	}
+
+	if source.Status.Name == "" || source.Status.Name == loop.name ||
+		!loop.adminConnection.DatabaseMine(loop.db, source) {
+		return nil, fmt.Errorf("database %v is not available to clone", sourceName)
+	}
+
+	return source, nil
+}
+
+// databaseClone Copies table definitions, data and views from the source database. Each table copied is recorded
+// in status so an interrupted clone resumes with the remaining tables.
+func (r *DatabaseReconciler) databaseClone(ctx context.Context, loop *DatabaseLoopContext) error {
+
+	source, err := r.cloneSource(ctx, loop)
+	if err != nil {
+		r.Log.Error(err, "Refusing to clone database.", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name)
+		loop.instance.Status.Message = "Failed to clone database: " + err.Error()
+		return err
+	}
+	if loop.instance.Status.Clone == nil {
+		loop.instance.Status.Clone = &mysqlv1alpha1.CloneStatus{}
+	}
+	loop.instance.Status.Clone.Source = source.Namespace + "/" + source.Name
+	loop.instance.Status.Clone.SourceName = source.Status.Name
+
+	var tables []cloneTable
+	tx := loop.db.Raw("SELECT TABLE_NAME, TABLE_TYPE FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? "+
+		"ORDER BY TABLE_NAME", source.Status.Name).Scan(&tables)
+	if tx.Error != nil {
+		loop.instance.Status.Message = "Failed to clone database: " + tx.Error.Error()
+		return tx.Error
+	}
+
+	views := make([]string, 0)
+	for _, table := range tables {
+		if table.TableType == "VIEW" {
+			views = append(views, table.TableName)
+			continue
+		}
+		if contains(loop.instance.Status.Clone.Tables, table.TableName) {
+			continue
+		}
+		err = r.cloneTable(loop, source.Status.Name, table.TableName)
+		if err != nil {
+			r.Log.Error(err, "Failed to clone table.", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.name, "Table", table.TableName)
+			loop.instance.Status.Message = "Failed to clone database table " + table.TableName
+			return err
+		}
+		loop.instance.Status.Clone.Tables = append(loop.instance.Status.Clone.Tables, table.TableName)
+
+		// Recording progress as we go, large copies may not complete in one pass.
+		err = r.Status().Update(ctx, loop.instance)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.cloneViews(loop, source.Status.Name, views)
+	if err != nil {
+		loop.instance.Status.Message = "Failed to clone database views"
+		return err
+	}
+
+	loop.instance.Status.Clone.CompletionTime = metav1.NewTime(time.Now())
+	r.Log.Info("Successfully cloned database", "Host", loop.adminConnection.Spec.Host,
+		"Name", loop.name, "Source", source.Status.Name)
+	return nil
+}
+
+// cloneTable Recreates the table from the source definition and copies the rows in chunks of primary key order.
+func (r *DatabaseReconciler) cloneTable(loop *DatabaseLoopContext, sourceSchema string, table string) error {
+
+	target := mysqlv1alpha1.QuoteIdentifier(loop.name) + "." + mysqlv1alpha1.QuoteIdentifier(table)
+	origin := mysqlv1alpha1.QuoteIdentifier(sourceSchema) + "." + mysqlv1alpha1.QuoteIdentifier(table)
+
+	var definition []map[string]interface{}
+	tx := loop.db.Raw("SHOW CREATE TABLE " + origin).Scan(&definition)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if len(definition) != 1 {
+		return fmt.Errorf("expected 1 row, got %v", len(definition))
+	}
+	createQuery := fmt.Sprintf("%v", definition[0]["Create Table"])
+	createQuery = strings.Replace(createQuery, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)
+
+	var primaryKey []string
+	tx = loop.db.Raw("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = ? "+
+		"AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", sourceSchema, table).
+		Scan(&primaryKey)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// Generated columns are computed by the server and refuse values
+	var columns []string
+	tx = loop.db.Raw("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? "+
+		"AND EXTRA NOT LIKE '%VIRTUAL GENERATED%' AND EXTRA NOT LIKE '%STORED GENERATED%' "+
+		"ORDER BY ORDINAL_POSITION", sourceSchema, table).Scan(&columns)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	columnList := quoteColumns(columns)
+	insert := "INSERT INTO " + target + " (" + columnList + ") SELECT " + columnList + " FROM " + origin
+
+	// Using a single session so the schema, foreign key settings and variables apply to every statement.
+	return loop.db.Connection(func(conn *gorm.DB) error {
+		defer conn.Exec("SET FOREIGN_KEY_CHECKS = 1")
+		defer conn.Exec("USE " + orm.DatabaseName)
+
+		statements := []string{
+			"SET FOREIGN_KEY_CHECKS = 0",
+			"USE " + mysqlv1alpha1.QuoteIdentifier(loop.name),
+			createQuery,
+			// A previous pass may have been interrupted part way through copying
+			"DELETE FROM " + target,
+		}
+		for _, statement := range statements {
+			if tx := conn.Exec(statement); tx.Error != nil {
+				return tx.Error
+			}
+		}
+
+		if len(primaryKey) == 0 {
+			return conn.Exec(insert).Error
+		}
+
+		// Each chunk continues after the last key copied, held in session variables to keep its type
+		key := quoteColumns(primaryKey)
+		variables := make([]string, len(primaryKey))
+		descending := make([]string, len(primaryKey))
+		for i, column := range primaryKey {
+			variables[i] = fmt.Sprintf("@clone_key_%d", i)
+			descending[i] = mysqlv1alpha1.QuoteIdentifier(column) + " DESC"
+		}
+		where := ""
+		for {
+			tx := conn.Exec(fmt.Sprintf("%s%s ORDER BY %s LIMIT %d", insert, where, key, cloneChunkSize))
+			if tx.Error != nil {
+				return tx.Error
+			}
+			if tx.RowsAffected < cloneChunkSize {
+				return nil
+			}
+			tx = conn.Exec(fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT 1 INTO %s", key, target,
+				strings.Join(descending, ", "), strings.Join(variables, ", ")))
+			if tx.Error != nil {
+				return tx.Error
+			}
+			where = fmt.Sprintf(" WHERE (%s) > (%s)", key, strings.Join(variables, ", "))
+		}
+	})
+}
+
+// cloneViews Recreates the views of the source pointing at the copied tables. Views may depend on other views,
+// so each pass creates what it can until nothing is left or no further progress is made.
+func (r *DatabaseReconciler) cloneViews(loop *DatabaseLoopContext, sourceSchema string, views []string) error {
+
+	sourcePrefix := mysqlv1alpha1.QuoteIdentifier(sourceSchema) + "."
+	targetPrefix := mysqlv1alpha1.QuoteIdentifier(loop.name) + "."
+
+	return loop.db.Connection(func(conn *gorm.DB) error {
+		defer conn.Exec("USE " + orm.DatabaseName)
+
+		if tx := conn.Exec("USE " + mysqlv1alpha1.QuoteIdentifier(loop.name)); tx.Error != nil {
+			return tx.Error
+		}
+
+		var lastErr error
+		for len(views) > 0 {
+			remaining := make([]string, 0)
+			for _, view := range views {
+				var definition []map[string]interface{}
+				tx := conn.Raw("SHOW CREATE VIEW " + sourcePrefix + mysqlv1alpha1.QuoteIdentifier(view)).Scan(&definition)
+				if tx.Error != nil {
+					return tx.Error
+				}
+				if len(definition) != 1 {
+					return fmt.Errorf("expected 1 row, got %v", len(definition))
+				}
+				createQuery := fmt.Sprintf("%v", definition[0]["Create View"])
+				createQuery = strings.Replace(createQuery, "CREATE ", "CREATE OR REPLACE ", 1)
+				createQuery = strings.ReplaceAll(createQuery, sourcePrefix, targetPrefix)
+
+				tx = conn.Exec(createQuery)
+				if tx.Error != nil {
+					lastErr = tx.Error
+					remaining = append(remaining, view)
+				}
+			}
+			if len(remaining) == len(views) {
+				return lastErr
+			}
+			views = remaining
+		}
+		return nil
+	})
+}
@@ -25,9 +25,11 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -46,6 +48,9 @@ type DatabaseReconciler struct {
 	Log         logr.Logger
 	Scheme      *runtime.Scheme
 	Connections map[types.UID]*orm.ConnectionDefinition
+	// How often status.size is gathered from the server, every reconcile when zero
+	StatisticsInterval time.Duration
+	Recorder           record.EventRecorder
 }
 
 // DatabaseLoopContext Custom variables used for the reconciliation loops
@@ -53,12 +58,15 @@ type DatabaseLoopContext struct {
 	instance        *mysqlv1alpha1.Database
 	adminConnection *mysqlv1alpha1.AdminConnection
 	db              *gorm.DB
+	// The schema name on the server, see AdminConnection.DatabaseName
+	name string
 }
 
 // +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databases/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databases/finalizers,verbs=update
 // +kubebuilder:rbac:groups=*,resources=secrets,verbs=list;get;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,6 +77,7 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	_ = r.Log.WithValues("Database", req.NamespacedName)
 
 	loop := DatabaseLoopContext{}
+	result := ctrl.Result{}
 
 	// Fetch the Database instance
 	loop.instance = &mysqlv1alpha1.Database{}
@@ -90,6 +99,7 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	var adminErr error
 	loop.adminConnection, adminErr = mysqlv1alpha1.GetAdminConnection(ctx, r.Client, loop.instance.Namespace, loop.instance.Spec.AdminConnection)
 	if adminErr == nil && loop.adminConnection != nil {
+		loop.name = loop.adminConnection.DatabaseName(loop.instance)
 		// Grabbing actual database connection here.
 		loop.db, adminErr = loop.adminConnection.GetDatabaseConnection(ctx, r.Client, r.Connections)
 		if adminErr != nil {
@@ -146,51 +156,106 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		if err != nil {
 			r.Log.Error(err, "Failure adding the finalizer.")
 		}
+	} else if r.renamePending(&loop) {
+		// Completing a change of spec.name before anything else is done with the schema
+		loop.instance.Status.SyncTime = metav1.NewTime(time.Now())
+		err = r.databaseRename(ctx, &loop)
+		if statusErr := r.Status().Update(ctx, loop.instance); statusErr != nil {
+			r.Log.Error(statusErr, "Failure recording status.")
+		}
 	} else {
-		exists, err := r.databaseExists(&loop)
+		var exists, charsetValid bool
+		exists, err = r.databaseExists(&loop)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 
 		loop.instance.Status.Host = loop.adminConnection.Spec.Host
 		loop.instance.Status.Port = loop.adminConnection.Spec.Port
-		loop.instance.Status.Name = loop.instance.Spec.Name
+		if loop.instance.Status.Name == "" {
+			loop.instance.Status.NameTemplate = loop.adminConnection.Spec.DatabaseNameTemplate
+		}
+		loop.instance.Status.Name = loop.name
 		loop.instance.Status.SyncTime = metav1.NewTime(time.Now())
 
-		if !exists {
-			created, err := r.databaseCreate(&loop)
-			if err == nil && created {
+		charsetValid, err = r.databaseCharsetValid(&loop)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !exists && !charsetValid {
+			loop.instance.Status.Message = "Invalid character set or collation for this server"
+		} else if !exists {
+			var created bool
+			created, err = r.databaseCreate(ctx, &loop)
+			if created {
 				loop.instance.Status.CreationTime = metav1.NewTime(time.Now())
+			}
+			if err == nil && created {
 				loop.instance.Status.Message = "Created database"
+			} else if err != nil && !created {
+				loop.instance.Status.Message = "Failed to create database"
+			}
+		} else if !loop.instance.CloneComplete() && loop.adminConnection.DatabaseMine(loop.db, loop.instance) {
+			// Resuming a clone interrupted after the database was created
+			err = r.databaseClone(ctx, &loop)
+			if err == nil {
+				loop.instance.Status.Message = "Cloned database"
+			}
+		} else if r.initializationPending(&loop) && loop.adminConnection.DatabaseMine(loop.db, loop.instance) {
+			// Retrying init scripts which failed after the database was created
+			err = r.databaseInitialize(ctx, &loop)
+			if err == nil {
+				loop.instance.Status.Message = "Initialized database"
 			}
 		} else if loop.adminConnection.DatabaseMine(loop.db, loop.instance) {
-			updated, err := r.databaseUpdate(&loop)
-			if err == nil && updated {
+			// Statistics failures are logged, the quota is judged on the previous size
+			if r.sizeRefreshDue(&loop) {
+				_ = r.databaseSize(&loop)
+			}
+			r.databaseQuota(&loop)
+			var updated bool
+			updated, err = r.databaseUpdate(&loop)
+			if err == nil && !charsetValid {
+				loop.instance.Status.Message = "Invalid character set or collation for this server"
+			} else if err == nil && updated {
 				loop.instance.Status.Message = "Altered database"
 			} else if err == nil {
 				loop.instance.Status.Message = "Database in sync"
 			} else {
 				loop.instance.Status.Message = "Failed to update database"
 			}
+			if r.StatisticsInterval > 0 {
+				result.RequeueAfter = r.StatisticsInterval
+			}
+			if err == nil && loop.instance.Spec.ConvertTables {
+				var wait time.Duration
+				wait, err = r.databaseConvert(ctx, &loop)
+				if wait > 0 && (result.RequeueAfter == 0 || wait < result.RequeueAfter) {
+					result.RequeueAfter = wait
+				}
+			}
 		} else {
 			loop.instance.Status.Message = "No permission to this database."
 		}
 
-		err = r.Status().Update(ctx, loop.instance)
-		if err != nil {
-			r.Log.Error(err, "Failure recording status.")
+		if statusErr := r.Status().Update(ctx, loop.instance); statusErr != nil {
+			r.Log.Error(statusErr, "Failure recording status.")
+			if err == nil {
+				err = statusErr
+			}
 		}
 	}
 
-	return ctrl.Result{}, err
+	return result, err
 }
 
 func (r *DatabaseReconciler) databaseExists(loop *DatabaseLoopContext) (bool, error) {
 
-	schema := orm.DatabaseExists(loop.db, loop.instance.Spec.Name)
+	schema := orm.DatabaseExists(loop.db, loop.name)
 	if schema == nil {
 		r.Log.Info("Database does not exist", "Host", loop.adminConnection.Spec.Host,
-			"Name", loop.instance.Spec.Name)
+			"Name", loop.name)
 		return false, nil
 	}
 
@@ -200,6 +265,8 @@ func (r *DatabaseReconciler) databaseExists(loop *DatabaseLoopContext) (bool, er
 	if loop.instance.Status.CharacterSet == "" || loop.instance.Status.CharacterSet != schema.DefaultCharacterSet {
 		loop.instance.Status.CharacterSet = schema.DefaultCharacterSet
 	}
+	loop.instance.Status.DefaultEncryption = schema.DefaultEncryption
+	loop.instance.Status.SchemaVersion = orm.SchemaVersion(loop.db, string(loop.instance.UID))
 	return true, nil
 }
 
@@ -208,39 +275,67 @@ func (r *DatabaseReconciler) databaseUpdate(loop *DatabaseLoopContext) (bool, er
 	var alterQuery string
 	requireAlter := false
 
-	alterQuery = "ALTER DATABASE `" + loop.instance.Spec.Name + "`"
-	if loop.instance.Spec.CharacterSet != "" && loop.instance.Spec.CharacterSet != loop.instance.Status.CharacterSet {
+	// An invalid character set or collation is left for the user to correct, see databaseCharsetValid
+	charsetValid := !meta.IsStatusConditionTrue(loop.instance.Status.Conditions, mysqlv1alpha1.DatabaseInvalidCharset)
+
+	alterQuery = "ALTER DATABASE `" + loop.name + "`"
+	if charsetValid && loop.instance.Spec.CharacterSet != "" &&
+		loop.instance.Spec.CharacterSet != loop.instance.Status.CharacterSet {
 		requireAlter = true
 		alterQuery += " CHARACTER SET " + loop.instance.Spec.CharacterSet
 		loop.instance.Status.CharacterSet = loop.instance.Spec.CharacterSet
 	}
-	if loop.instance.Spec.Collate != "" && loop.instance.Spec.Collate != loop.instance.Status.Collate {
+	if charsetValid && loop.instance.Spec.Collate != "" && loop.instance.Spec.Collate != loop.instance.Status.Collate {
 		requireAlter = true
 		alterQuery += " COLLATE " + loop.instance.Spec.Collate
 		loop.instance.Status.Collate = loop.instance.Spec.Collate
 	}
+	// Servers without DEFAULT_ENCRYPTION report it empty and are left alone
+	if loop.instance.Spec.Encryption != nil && loop.instance.Status.DefaultEncryption != "" &&
+		*loop.instance.Spec.Encryption != (loop.instance.Status.DefaultEncryption == "YES") {
+		requireAlter = true
+		alterQuery += encryptionOption(*loop.instance.Spec.Encryption)
+	}
+	// Other options cannot be altered on a read only schema, databaseAccess sets it again after
+	if requireAlter && loop.instance.Status.ReadOnly {
+		alterQuery += " READ ONLY = 0"
+		loop.instance.Status.ReadOnly = false
+	}
 
 	if requireAlter {
 		r.Log.Info("Required to alter database", "Host", loop.adminConnection.Spec.Host, "Name",
-			loop.instance.Spec.Name, "Query", alterQuery)
+			loop.name, "Query", alterQuery)
 		tx := loop.db.Exec(alterQuery)
 		if tx.Error != nil {
 			r.Log.Error(tx.Error, "Failed to alter database.", "Host", loop.adminConnection.Spec.Host,
-				"Name", loop.instance.Spec.Name)
+				"Name", loop.name)
 			return false, tx.Error
 		}
 		r.Log.Info("Successfully altered database", "Host", loop.adminConnection.Spec.Host,
-			"Name", loop.instance.Spec.Name)
+			"Name", loop.name)
+	}
+
+	accessChanged, err := r.databaseAccess(loop)
+	if err != nil {
+		return false, err
 	}
-	_, err := r.databaseExists(loop)
-	return requireAlter, err
+	_, err = r.databaseExists(loop)
+	return requireAlter || accessChanged, err
 }
 
-func (r *DatabaseReconciler) databaseCreate(loop *DatabaseLoopContext) (bool, error) {
+// encryptionOption The DEFAULT ENCRYPTION clause for CREATE or ALTER DATABASE
+func encryptionOption(encrypted bool) string {
+	if encrypted {
+		return " DEFAULT ENCRYPTION = 'Y'"
+	}
+	return " DEFAULT ENCRYPTION = 'N'"
+}
+
+func (r *DatabaseReconciler) databaseCreate(ctx context.Context, loop *DatabaseLoopContext) (bool, error) {
 
 	var createQuery string
 
-	createQuery = "CREATE DATABASE `" + loop.instance.Spec.Name + "`"
+	createQuery = "CREATE DATABASE `" + loop.name + "`"
 	if loop.instance.Spec.CharacterSet != "" {
 		createQuery += " CHARACTER SET " + loop.instance.Spec.CharacterSet
 		loop.instance.Status.CharacterSet = loop.instance.Spec.CharacterSet
@@ -249,11 +344,38 @@ func (r *DatabaseReconciler) databaseCreate(loop *DatabaseLoopContext) (bool, er
 		createQuery += " COLLATE " + loop.instance.Spec.Collate
 		loop.instance.Status.Collate = loop.instance.Spec.Collate
 	}
+	if loop.instance.Spec.Encryption != nil && *loop.instance.Spec.Encryption {
+		createQuery += encryptionOption(true)
+	}
+
+	// Recording the clone and init scripts before the schema exists. An empty schema without the clone would be
+	// taken as complete, and init scripts first seen on an existing schema are skipped.
+	if loop.instance.Spec.CloneFrom != nil || len(loop.instance.Spec.InitScripts) > 0 {
+		if loop.instance.Spec.CloneFrom != nil {
+			loop.instance.Status.Clone = &mysqlv1alpha1.CloneStatus{}
+		}
+		if len(loop.instance.Spec.InitScripts) > 0 {
+			meta.SetStatusCondition(&loop.instance.Status.Conditions, metav1.Condition{
+				Type:               mysqlv1alpha1.DatabaseInitialized,
+				Status:             metav1.ConditionFalse,
+				Reason:             initReasonPending,
+				Message:            "Init scripts run once the database is created",
+				ObservedGeneration: loop.instance.Generation,
+			})
+			// Runs recorded against a schema since dropped
+			loop.db.Delete(&orm.InitScriptRun{}, "database_uuid = ?", string(loop.instance.UID))
+		}
+		err := r.Status().Update(ctx, loop.instance)
+		if err != nil {
+			r.Log.Error(err, "Failure recording status.")
+			return false, err
+		}
+	}
 
 	tx := loop.db.Exec(createQuery)
 	if tx.Error != nil {
 		r.Log.Error(tx.Error, "Failed to create database.", "Host", loop.adminConnection.Spec.Host, "Name",
-			loop.instance.Spec.Name, "Query", createQuery)
+			loop.name, "Query", createQuery)
 		return false, tx.Error
 	}
 
@@ -261,30 +383,45 @@ func (r *DatabaseReconciler) databaseCreate(loop *DatabaseLoopContext) (bool, er
 		Uuid:         string(loop.instance.UID),
 		Namespace:    loop.instance.Namespace,
 		Name:         loop.instance.Name,
-		DatabaseName: loop.instance.Spec.Name,
+		DatabaseName: loop.name,
 	}
 
 	tx = tx.Create(&managedDatabase)
 	if tx.Error != nil {
 		r.Log.Error(tx.Error, "Failed to insert managed record.", "Host", loop.adminConnection.Spec.Host, "Name",
-			loop.instance.Spec.Name)
+			loop.name)
 	}
 	tx.Commit()
 
 	exists, err := r.databaseExists(loop)
+	if err == nil && exists && loop.instance.Spec.CloneFrom != nil {
+		err = r.databaseClone(ctx, loop)
+	}
+	if err == nil && exists && r.initializationPending(loop) {
+		err = r.databaseInitialize(ctx, loop)
+	}
 	return exists, err
 }
 
 // This is the finalizer which will DROP the database from the server losing all data.
 func (r *DatabaseReconciler) finalizeDatabase(loop *DatabaseLoopContext) error {
 
-	tx := loop.db.Exec("DROP DATABASE IF EXISTS `" + loop.instance.Spec.Name + "`")
+	tx := loop.db.Exec("DROP DATABASE IF EXISTS `" + loop.name + "`")
 	if tx.Error != nil {
 		r.Log.Error(tx.Error, "Failed to delete the database")
 	}
-	r.Log.Info("Successfully, deleted database", "Host", loop.adminConnection.Spec.Host, "Name", loop.instance.Spec.Name)
+	// A schema left behind by an unfinished rename
+	if loop.instance.Status.Name != "" && loop.instance.Status.Name != loop.name {
+		tx = loop.db.Exec("DROP DATABASE IF EXISTS " + mysqlv1alpha1.QuoteIdentifier(loop.instance.Status.Name))
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to delete the database")
+		}
+	}
+	r.Log.Info("Successfully, deleted database", "Host", loop.adminConnection.Spec.Host, "Name", loop.name)
 
 	loop.db.Delete(&orm.ManagedDatabase{}, "uuid = ?", fmt.Sprintf("%v", loop.instance.UID))
+	loop.db.Delete(&orm.MigrationHistory{}, "database_uuid = ?", fmt.Sprintf("%v", loop.instance.UID))
+	loop.db.Delete(&orm.InitScriptRun{}, "database_uuid = ?", fmt.Sprintf("%v", loop.instance.UID))
 
 	return nil
 }
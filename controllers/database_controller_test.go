@@ -1,6 +1,11 @@
 package controllers
 
 import (
+	"fmt"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"gorm.io/gorm"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -46,6 +51,438 @@ var _ = Describe("Database", func() {
 				return databaseObject.Status.Message
 			}).WithContext(ctx).Should(Equal("Database in sync"))
 		}, NodeTimeout(time.Second*30))
+
+		It("Holds back an invalid character set", func(ctx SpecContext) {
+
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-invalid-charset",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Name:         "test-invalid-charset",
+					CharacterSet: "nonsense",
+				},
+			}
+
+			err := k8sClient.Create(ctx, database)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() bool {
+				databaseObject := &Database{}
+				databaseNamespacedName := types.NamespacedName{
+					Namespace: database.Namespace,
+					Name:      database.Name,
+				}
+				err := k8sClient.Get(ctx, databaseNamespacedName, databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				return meta.IsStatusConditionTrue(databaseObject.Status.Conditions, DatabaseInvalidCharset)
+			}).WithContext(ctx).Should(BeTrue())
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Rename Scenario", func() {
+
+		It("Moves tables, triggers, routines and grants to the new schema", func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseNamespacedName := types.NamespacedName{Namespace: "default", Name: "test-rename"}
+			database := &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      databaseNamespacedName.Name,
+					Namespace: databaseNamespacedName.Namespace,
+				},
+				Spec: DatabaseSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Name: "test-rename",
+				},
+			}
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			Eventually(func() string {
+				err := k8sClient.Get(ctx, databaseNamespacedName, database)
+				Expect(err).ToNot(HaveOccurred())
+				return database.Status.Message
+			}).WithContext(ctx).Should(Equal("Database in sync"))
+
+			Expect(execInSchema(gormDB, "test-rename", []string{
+				"CREATE TABLE orders (id INT PRIMARY KEY, total INT)",
+				"CREATE TRIGGER orders_total BEFORE INSERT ON orders FOR EACH ROW SET NEW.total = IFNULL(NEW.total, 0)",
+				"INSERT INTO orders (id) VALUES (1)",
+				"CREATE VIEW order_totals AS SELECT SUM(total) AS total FROM orders",
+				"CREATE PROCEDURE order_count() SELECT COUNT(*) FROM `test-rename`.orders",
+			})).To(Succeed())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-rename",
+					Namespace: "default",
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username:     "test-rename",
+					DatabaseList: []DatabasePermission{{Name: "test-rename", Grants: []string{"SELECT", "INSERT"}}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, databaseUser)).To(Succeed())
+			Eventually(func() int {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return len(userObject.Status.Grants)
+			}).WithContext(ctx).Should(Equal(1))
+
+			Eventually(func() error {
+				err := k8sClient.Get(ctx, databaseNamespacedName, database)
+				Expect(err).ToNot(HaveOccurred())
+				database.Spec.Name = "test-renamed"
+				return k8sClient.Update(ctx, database)
+			}).WithContext(ctx).Should(Succeed())
+
+			Eventually(func() RenamePhase {
+				err := k8sClient.Get(ctx, databaseNamespacedName, database)
+				Expect(err).ToNot(HaveOccurred())
+				if database.Status.Rename == nil {
+					return ""
+				}
+				return database.Status.Rename.Phase
+			}).WithContext(ctx).Should(Equal(RenamePhaseComplete))
+			Expect(database.Status.Name).To(Equal("test-renamed"))
+			Expect(database.Status.Rename.Tables).To(Equal([]string{"orders"}))
+			Expect(database.Status.Rename.Triggers).To(BeEmpty())
+
+			Expect(orm.DatabaseExists(gormDB, "test-rename")).To(BeNil())
+			var count int64
+			gormDB.Raw("SELECT COUNT(*) FROM `test-renamed`.orders WHERE total = 0").Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+			gormDB.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TRIGGERS WHERE TRIGGER_SCHEMA = ? "+
+				"AND TRIGGER_NAME = ?", "test-renamed", "orders_total").Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+			gormDB.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = ? "+
+				"AND TABLE_NAME = ?", "test-renamed", "order_totals").Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+			var body string
+			gormDB.Raw("SELECT ROUTINE_DEFINITION FROM INFORMATION_SCHEMA.ROUTINES WHERE ROUTINE_SCHEMA = ? "+
+				"AND ROUTINE_NAME = ?", "test-renamed", "order_count").Scan(&body)
+			Expect(body).To(ContainSubstring("`test-renamed`.orders"))
+
+			// The grant moved with the schema
+			var grants []map[string]interface{}
+			gormDB.Raw("SHOW GRANTS FOR " + Account("test-rename", DefaultHost)).Scan(&grants)
+			var statements []string
+			for _, row := range grants {
+				for _, value := range row {
+					statements = append(statements, fmt.Sprintf("%v", value))
+				}
+			}
+			Expect(statements).To(ContainElement(ContainSubstring("ON `test-renamed`.* TO")))
+			Expect(statements).NotTo(ContainElement(ContainSubstring("ON `test-rename`.* TO")))
+		}, NodeTimeout(time.Second*60))
+
+		It("Restores triggers saved before an interruption", func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The table was moved, its trigger was dropped from the old schema and not yet recreated
+			Expect(gormDB.Exec("CREATE DATABASE `test-rename-resume`").Error).To(Succeed())
+			Expect(execInSchema(gormDB, "test-rename-resume", []string{
+				"CREATE TABLE orders (id INT PRIMARY KEY, total INT)",
+			})).To(Succeed())
+			rename := &RenameStatus{
+				From:  "test-rename-interrupted",
+				To:    "test-rename-resume",
+				Phase: RenamePhaseMovingTables,
+				Triggers: []RenameTrigger{{
+					Name: "orders_total",
+					Statement: "CREATE TRIGGER orders_total BEFORE INSERT ON `test-rename-interrupted`.orders " +
+						"FOR EACH ROW SET NEW.total = IFNULL(NEW.total, 0)",
+				}},
+			}
+
+			r := &DatabaseReconciler{Client: k8sClient}
+			loop := &DatabaseLoopContext{db: gormDB, name: "test-rename-resume"}
+			Expect(r.renameRestoreTriggers(loop, rename)).To(Succeed())
+			Expect(rename.Triggers).To(BeNil())
+
+			var count int64
+			gormDB.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TRIGGERS WHERE TRIGGER_SCHEMA = ? "+
+				"AND EVENT_OBJECT_TABLE = ? AND TRIGGER_NAME = ?", "test-rename-resume", "orders", "orders_total").
+				Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+
+			// Restoring again finds the trigger in place
+			rename.Triggers = []RenameTrigger{{Name: "orders_total", Statement: "CREATE TRIGGER orders_total"}}
+			Expect(r.renameRestoreTriggers(loop, rename)).To(Succeed())
+
+			Expect(gormDB.Exec("DROP DATABASE `test-rename-resume`").Error).To(Succeed())
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Clone Scenario", func() {
+
+		It("Copies tables with generated columns in primary key chunks", func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(gormDB.Exec("CREATE DATABASE `test-clone-source`").Error).To(Succeed())
+			Expect(gormDB.Exec("CREATE DATABASE `test-clone-copy`").Error).To(Succeed())
+			Expect(execInSchema(gormDB, "test-clone-source", []string{
+				"CREATE TABLE digits (d INT PRIMARY KEY)",
+				"INSERT INTO digits VALUES (0), (1), (2), (3), (4), (5), (6), (7), (8), (9)",
+				"CREATE TABLE letters (l CHAR(1) PRIMARY KEY)",
+				"INSERT INTO letters VALUES ('x'), ('y'), ('z')",
+				"CREATE TABLE items (a INT, b CHAR(1), total INT, doubled INT AS (total * 2) STORED, " +
+					"PRIMARY KEY (a, b))",
+				"INSERT INTO items (a, b, total) SELECT d1.d + 10 * d2.d + 100 * d3.d + 1000 * d4.d, l, d1.d " +
+					"FROM digits d1, digits d2, digits d3, digits d4, letters",
+				"CREATE TABLE notes (body VARCHAR(8), size INT AS (LENGTH(body)))",
+				"INSERT INTO notes (body) VALUES ('a'), ('bb')",
+			})).To(Succeed())
+
+			r := &DatabaseReconciler{Client: k8sClient}
+			loop := &DatabaseLoopContext{db: gormDB, name: "test-clone-copy"}
+			Expect(r.cloneTable(loop, "test-clone-source", "items")).To(Succeed())
+			Expect(r.cloneTable(loop, "test-clone-source", "notes")).To(Succeed())
+
+			var count int64
+			gormDB.Raw("SELECT COUNT(*) FROM `test-clone-copy`.items").Scan(&count)
+			Expect(count).To(Equal(int64(30000)))
+			gormDB.Raw("SELECT COUNT(*) FROM `test-clone-copy`.items c JOIN `test-clone-source`.items s " +
+				"USING (a, b) WHERE c.total = s.total AND c.doubled = s.doubled").Scan(&count)
+			Expect(count).To(Equal(int64(30000)))
+			gormDB.Raw("SELECT SUM(size) FROM `test-clone-copy`.notes").Scan(&count)
+			Expect(count).To(Equal(int64(3)))
+
+			Expect(gormDB.Exec("DROP DATABASE `test-clone-source`").Error).To(Succeed())
+			Expect(gormDB.Exec("DROP DATABASE `test-clone-copy`").Error).To(Succeed())
+		}, NodeTimeout(time.Second*60))
+	})
+
+	DescribeTable("renameReferences",
+		func(statement string, expected string) {
+			rename := &RenameStatus{From: "shop", To: "store"}
+			Expect(renameReferences(statement, rename)).To(Equal(expected))
+		},
+		Entry("Qualified table", "SELECT * FROM `shop`.`orders`", "SELECT * FROM `store`.`orders`"),
+		Entry("Unqualified table", "SELECT * FROM orders", "SELECT * FROM orders"),
+		Entry("Other schema", "SELECT * FROM `shopping`.orders", "SELECT * FROM `shopping`.orders"),
+		Entry("Grant", "GRANT SELECT ON `shop`.* TO `app`@`%`", "GRANT SELECT ON `store`.* TO `app`@`%`"),
+	)
+
+	DescribeTable("grantOnSchema",
+		func(grant string, expected string) {
+			rename := &RenameStatus{From: "team_a_app", To: "team_b_app"}
+			match := grantOnSchema(rename.From).FindStringSubmatch(grant)
+			if expected == "" {
+				Expect(match).To(BeNil())
+			} else {
+				Expect(match).NotTo(BeNil())
+				Expect(movedGrant(grant, match[1], rename)).To(Equal(expected))
+			}
+		},
+		Entry("Database level", "GRANT SELECT ON `team\\_a\\_app`.* TO `app`@`%`",
+			"GRANT SELECT ON `team\\_b\\_app`.* TO `app`@`%`"),
+		Entry("Database level made before escaping", "GRANT SELECT ON `team_a_app`.* TO `app`@`%`",
+			"GRANT SELECT ON `team\\_b\\_app`.* TO `app`@`%`"),
+		Entry("Table", "GRANT SELECT ON `team_a_app`.`orders` TO `app`@`%`",
+			"GRANT SELECT ON `team_b_app`.`orders` TO `app`@`%`"),
+		Entry("Schema matched by the wildcards", "GRANT SELECT ON `team-a\\_app`.* TO `app`@`%`", ""),
+		Entry("Other schema", "GRANT SELECT ON `team\\_a\\_apps`.* TO `app`@`%`", ""),
+	)
+
+	Describe("Initialization Scenario", Ordered, func() {
+
+		var gormDB *gorm.DB
+
+		initCondition := func(ctx SpecContext, name string) func() *metav1.Condition {
+			return func() *metav1.Condition {
+				databaseObject := &Database{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				return meta.FindStatusCondition(databaseObject.Status.Conditions, DatabaseInitialized)
+			}
+		}
+		tableCount := func(schema string, table string) int64 {
+			var count int64
+			gormDB.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?",
+				schema, table).Scan(&count)
+			return count
+		}
+		initDatabase := func(name string, key string) *Database {
+			return &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Name:     name,
+					InitUser: name,
+					InitScripts: []ScriptSource{
+						{
+							ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "test-init"},
+								Key:                  key,
+							},
+						},
+					},
+				},
+			}
+		}
+		initUser := func(name string) *DatabaseUser {
+			return &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: name,
+					Identification: &Identification{
+						ClearText: true,
+						AuthString: &SecretKeySource{
+							SecretKeyRef: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "test-init"},
+								Key:                  "password",
+							},
+						},
+					},
+					DatabaseList: []DatabasePermission{{Name: name}},
+				},
+			}
+		}
+
+		BeforeAll(func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			var err error
+			gormDB, err = ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			configMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-init",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"seed.sql":     "CREATE TABLE lookups (id INT PRIMARY KEY);\nINSERT INTO lookups VALUES (1);",
+					"escalate.sql": "UPDATE zz_dba_operator.managed_databases SET namespace = 'other';",
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-init",
+					Namespace: "default",
+				},
+				StringData: map[string]string{"password": "Init-Passw0rd"},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		}, NodeTimeout(time.Second*30))
+
+		It("Runs init scripts as the init user once the database is created", func(ctx SpecContext) {
+			database := initDatabase("test-init-seed", "seed.sql")
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			Expect(k8sClient.Create(ctx, initUser("test-init-seed"))).To(Succeed())
+
+			Eventually(func() metav1.ConditionStatus {
+				condition := initCondition(ctx, database.Name)()
+				if condition == nil {
+					return ""
+				}
+				return condition.Status
+			}).WithContext(ctx).Should(Equal(metav1.ConditionTrue))
+			Expect(tableCount("test-init-seed", "lookups")).To(Equal(int64(1)))
+
+			var runs int64
+			gormDB.Model(&orm.InitScriptRun{}).Where("database_uuid = ?", string(database.UID)).Count(&runs)
+			Expect(runs).To(Equal(int64(1)))
+
+			// Runs are forgotten with the database, a recreated one is initialized again
+			Expect(k8sClient.Delete(ctx, database)).To(Succeed())
+			Eventually(func() int64 {
+				gormDB.Model(&orm.InitScriptRun{}).Where("database_uuid = ?", string(database.UID)).Count(&runs)
+				return runs
+			}).WithContext(ctx).Should(Equal(int64(0)))
+		}, NodeTimeout(time.Second*60))
+
+		It("Cannot reach beyond the grants of the init user", func(ctx SpecContext) {
+			database := initDatabase("test-init-escalate", "escalate.sql")
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			Expect(k8sClient.Create(ctx, initUser("test-init-escalate"))).To(Succeed())
+
+			Eventually(func() string {
+				condition := initCondition(ctx, database.Name)()
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}).WithContext(ctx).Should(Equal("ScriptFailed"))
+
+			var managedDatabase orm.ManagedDatabase
+			gormDB.Limit(1).Find(&managedDatabase, "uuid = ?", string(database.UID))
+			Expect(managedDatabase.Namespace).To(Equal("default"))
+		}, NodeTimeout(time.Second*60))
+
+		It("Skips init scripts added to an existing database", func(ctx SpecContext) {
+			database := initDatabase("test-init-existing", "seed.sql")
+			scripts := database.Spec.InitScripts
+			database.Spec.InitScripts = nil
+			database.Spec.InitUser = ""
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			Expect(k8sClient.Create(ctx, initUser("test-init-existing"))).To(Succeed())
+
+			Eventually(func() string {
+				databaseObject := &Database{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: database.Name},
+					databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				return databaseObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Database in sync"))
+
+			Eventually(func() error {
+				databaseObject := &Database{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: database.Name},
+					databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				databaseObject.Spec.InitScripts = scripts
+				databaseObject.Spec.InitUser = "test-init-existing"
+				return k8sClient.Update(ctx, databaseObject)
+			}).WithContext(ctx).Should(Succeed())
+
+			Eventually(func() string {
+				condition := initCondition(ctx, database.Name)()
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}).WithContext(ctx).Should(Equal("Skipped"))
+			Consistently(func() int64 {
+				return tableCount("test-init-existing", "lookups")
+			}).WithContext(ctx).WithTimeout(3 * time.Second).Should(Equal(int64(0)))
+		}, NodeTimeout(time.Second*60))
 	})
 
+	DescribeTable("humanBytes",
+		func(bytes int64, expected string) {
+			Expect(humanBytes(bytes)).To(Equal(expected))
+		},
+		Entry("Bytes", int64(512), "512"),
+		Entry("Kibibytes", int64(1536), "1.5Ki"),
+		Entry("Mebibytes", int64(16*1024*1024), "16.0Mi"),
+		Entry("Gibibytes", int64(3*1024*1024*1024), "3.0Gi"),
+	)
 })
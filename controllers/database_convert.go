@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// pendingConversions Base tables whose collation differs from the database default. The server is the record of
+// what remains, so an interrupted conversion picks up where it stopped.
+func (r *DatabaseReconciler) pendingConversions(loop *DatabaseLoopContext) ([]string, error) {
+	var tables []string
+	tx := loop.db.Raw("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? "+
+		"AND TABLE_TYPE = 'BASE TABLE' AND TABLE_COLLATION <> ? ORDER BY TABLE_NAME",
+		loop.name, loop.instance.Status.Collate).Scan(&tables)
+	return tables, tx.Error
+}
+
+// databaseConvert Converts existing tables to the database character set and collation, one table at a time and
+// only within the maintenance window. Returns how long until the window opens when waiting on it.
+func (r *DatabaseReconciler) databaseConvert(ctx context.Context, loop *DatabaseLoopContext) (time.Duration, error) {
+
+	if loop.instance.Status.Collate == "" || loop.instance.Status.CharacterSet == "" {
+		return 0, nil
+	}
+	pending, err := r.pendingConversions(loop)
+	if err != nil {
+		r.Log.Error(err, "Failed to list tables to convert", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name)
+		return 0, err
+	}
+
+	conversion := loop.instance.Status.Conversion
+	if len(pending) == 0 {
+		if conversion != nil && conversion.CompletionTime.IsZero() {
+			conversion.Pending = 0
+			conversion.CompletionTime = metav1.NewTime(time.Now())
+			loop.instance.Status.Message = fmt.Sprintf("Converted %d tables", len(conversion.Tables))
+		}
+		return 0, nil
+	}
+
+	if conversion == nil || conversion.CharacterSet != loop.instance.Status.CharacterSet ||
+		conversion.Collation != loop.instance.Status.Collate || !conversion.CompletionTime.IsZero() {
+		conversion = &mysqlv1alpha1.ConversionStatus{
+			CharacterSet: loop.instance.Status.CharacterSet,
+			Collation:    loop.instance.Status.Collate,
+			StartTime:    metav1.NewTime(time.Now()),
+		}
+		loop.instance.Status.Conversion = conversion
+	}
+	conversion.Pending = int32(len(pending))
+
+	if loop.instance.Status.ReadOnly {
+		loop.instance.Status.Message = fmt.Sprintf("%d tables to convert once no longer read only", len(pending))
+		return 0, nil
+	}
+
+	window := loop.instance.Spec.MaintenanceWindow
+	for _, table := range pending {
+		if window != nil && !window.InWindow(time.Now()) {
+			loop.instance.Status.Message = fmt.Sprintf("%d tables to convert in the maintenance window",
+				conversion.Pending)
+			next := window.NextStart(time.Now())
+			if next.IsZero() {
+				return 0, nil
+			}
+			return time.Until(next), nil
+		}
+
+		convertQuery := "ALTER TABLE " + mysqlv1alpha1.QuoteIdentifier(loop.name) + "." +
+			mysqlv1alpha1.QuoteIdentifier(table) + " CONVERT TO CHARACTER SET " + conversion.CharacterSet +
+			" COLLATE " + conversion.Collation
+		tx := loop.db.Exec(convertQuery)
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to convert table", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.name, "Table", table)
+			loop.instance.Status.Message = "Failed to convert table " + table
+			return 0, tx.Error
+		}
+		r.Log.Info("Successfully converted table", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name, "Table", table)
+
+		conversion.Tables = append(conversion.Tables, table)
+		conversion.Pending--
+		loop.instance.Status.Message = fmt.Sprintf("Converting tables, %d remaining", conversion.Pending)
+		err = r.Status().Update(ctx, loop.instance)
+		if err != nil {
+			r.Log.Error(err, "Failure recording conversion progress.")
+			return 0, err
+		}
+		// The update replaces the status with the stored copy
+		conversion = loop.instance.Status.Conversion
+	}
+
+	conversion.CompletionTime = metav1.NewTime(time.Now())
+	loop.instance.Status.Message = fmt.Sprintf("Converted %d tables", len(conversion.Tables))
+	return 0, nil
+}
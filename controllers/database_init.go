@@ -0,0 +1,143 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// initReasonPending The schema is being created by the operator, the init scripts are to be run
+	initReasonPending = "Pending"
+	// initReasonSkipped The init scripts were first seen on a schema which already existed and are never run
+	initReasonSkipped = "Skipped"
+)
+
+// initializationPending Whether there are init scripts which have not yet been run successfully. They are only run
+// on a schema the operator created, see databaseCreate, scripts first seen on an existing schema are recorded as
+// skipped.
+func (r *DatabaseReconciler) initializationPending(loop *DatabaseLoopContext) bool {
+	if len(loop.instance.Spec.InitScripts) == 0 {
+		return false
+	}
+	condition := meta.FindStatusCondition(loop.instance.Status.Conditions, mysqlv1alpha1.DatabaseInitialized)
+	if condition == nil {
+		r.Log.Info("Skipping init scripts of an existing database", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name)
+		meta.SetStatusCondition(&loop.instance.Status.Conditions, metav1.Condition{
+			Type:               mysqlv1alpha1.DatabaseInitialized,
+			Status:             metav1.ConditionFalse,
+			Reason:             initReasonSkipped,
+			Message:            "Init scripts are only run when the database is created",
+			ObservedGeneration: loop.instance.Generation,
+		})
+		return false
+	}
+	return condition.Status != metav1.ConditionTrue && condition.Reason != initReasonSkipped
+}
+
+// databaseInitialize Runs the init scripts not yet recorded in the control database, in order, as the init user.
+// A failing script, or a user not yet ready, is reported in the Initialized condition and retried on later passes,
+// the database itself is left in place.
+func (r *DatabaseReconciler) databaseInitialize(ctx context.Context, loop *DatabaseLoopContext) error {
+
+	var runs []orm.InitScriptRun
+	tx := loop.db.Where("database_uuid = ?", string(loop.instance.UID)).Find(&runs)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	completed := make(map[int]bool)
+	for _, run := range runs {
+		completed[run.Position] = true
+	}
+
+	var session *scriptSession
+	for i, source := range loop.instance.Spec.InitScripts {
+		if completed[i] {
+			continue
+		}
+
+		// Scripts come from the namespace, they run as its user rather than the admin connection
+		if session == nil {
+			target := &databaseTarget{database: loop.instance, adminConnection: loop.adminConnection, db: loop.db}
+			var err error
+			session, err = openScriptSession(ctx, r.Client, loop.instance.Namespace, loop.instance.Spec.InitUser,
+				target)
+			if err != nil {
+				r.Log.Info("Init user not available", "Host", loop.adminConnection.Spec.Host,
+					"Name", loop.name, "User", loop.instance.Spec.InitUser, "Reason", err.Error())
+				loop.instance.Status.Message = "Waiting for init user: " + err.Error()
+				meta.SetStatusCondition(&loop.instance.Status.Conditions, metav1.Condition{
+					Type:               mysqlv1alpha1.DatabaseInitialized,
+					Status:             metav1.ConditionFalse,
+					Reason:             "UserNotAvailable",
+					Message:            err.Error(),
+					ObservedGeneration: loop.instance.Generation,
+				})
+				return err
+			}
+			defer session.Close()
+		}
+
+		script, err := mysqlv1alpha1.GetScriptSourceValue(ctx, r.Client, loop.instance.Namespace, &source)
+		if err == nil {
+			err = session.exec(ctx, splitStatements(script))
+		}
+		if err != nil {
+			r.Log.Error(err, "Failed to run init script", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.name, "Script", i)
+			loop.instance.Status.Message = "Failed to initialize database"
+			meta.SetStatusCondition(&loop.instance.Status.Conditions, metav1.Condition{
+				Type:               mysqlv1alpha1.DatabaseInitialized,
+				Status:             metav1.ConditionFalse,
+				Reason:             "ScriptFailed",
+				Message:            fmt.Sprintf("Init script %d failed: %v", i, err),
+				ObservedGeneration: loop.instance.Generation,
+			})
+			return err
+		}
+
+		tx = loop.db.Create(&orm.InitScriptRun{
+			DatabaseUuid: string(loop.instance.UID),
+			Position:     i,
+			Checksum:     checksum(script),
+			AppliedAt:    time.Now(),
+		})
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to record init script", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.name, "Script", i)
+			return tx.Error
+		}
+		r.Log.Info("Successfully ran init script", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name, "Script", i)
+	}
+
+	meta.SetStatusCondition(&loop.instance.Status.Conditions, metav1.Condition{
+		Type:               mysqlv1alpha1.DatabaseInitialized,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ScriptsComplete",
+		Message:            fmt.Sprintf("%d init scripts run", len(loop.instance.Spec.InitScripts)),
+		ObservedGeneration: loop.instance.Generation,
+	})
+	return nil
+}
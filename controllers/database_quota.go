@@ -0,0 +1,106 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// databaseQuota Compares the gathered size against the effective quota, raising conditions and Events as the
+// thresholds are crossed. The quota action is applied by databaseUpdate while the limit is exceeded.
+func (r *DatabaseReconciler) databaseQuota(loop *DatabaseLoopContext) {
+
+	quota := loop.instance.EffectiveQuota(loop.adminConnection)
+	if quota == nil || quota.MaxSize.Value() <= 0 || loop.instance.Status.Size == nil {
+		loop.instance.Status.Quota = nil
+		meta.RemoveStatusCondition(&loop.instance.Status.Conditions, mysqlv1alpha1.DatabaseQuotaWarning)
+		meta.RemoveStatusCondition(&loop.instance.Status.Conditions, mysqlv1alpha1.DatabaseQuotaExceeded)
+		return
+	}
+
+	limit := quota.MaxSize.Value()
+	used := loop.instance.Status.Size.TotalBytes
+	status := &mysqlv1alpha1.QuotaStatus{
+		Limit:       quota.MaxSize.String(),
+		LimitBytes:  limit,
+		UsedPercent: int32(used * 100 / limit),
+		State:       "Ok",
+		Action:      quota.Action,
+	}
+	warning := status.UsedPercent >= *quota.WarningPercent
+	exceeded := used > limit
+	if exceeded {
+		status.State = "Exceeded"
+	} else if warning {
+		status.State = "Warning"
+	}
+	loop.instance.Status.Quota = status
+
+	message := fmt.Sprintf("Using %s of %s quota (%d%%)", loop.instance.Status.Size.Total, status.Limit,
+		status.UsedPercent)
+	r.setCondition(loop, mysqlv1alpha1.DatabaseQuotaWarning, warning, "UsageAboveWarning",
+		"UsageBelowWarning", message)
+	r.setCondition(loop, mysqlv1alpha1.DatabaseQuotaExceeded, exceeded, "UsageAboveLimit",
+		"UsageBelowLimit", message)
+}
+
+// setCondition Records the condition, emitting an Event when it changes.
+func (r *DatabaseReconciler) setCondition(loop *DatabaseLoopContext, conditionType string, active bool,
+	activeReason string, inactiveReason string, message string) {
+
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             inactiveReason,
+		Message:            message,
+		ObservedGeneration: loop.instance.Generation,
+	}
+	if active {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = activeReason
+	}
+
+	wasActive := meta.IsStatusConditionTrue(loop.instance.Status.Conditions, conditionType)
+	meta.SetStatusCondition(&loop.instance.Status.Conditions, condition)
+	if r.Recorder == nil || active == wasActive {
+		return
+	}
+	if active {
+		r.Recorder.Event(loop.instance, v1.EventTypeWarning, activeReason, message)
+	} else {
+		r.Recorder.Event(loop.instance, v1.EventTypeNormal, inactiveReason, message)
+	}
+}
+
+// quotaRestrictions The privileges withheld by the quota action while the database is over its limit. Neither
+// action uses the schema READ ONLY option, which would also prevent cleaning up.
+func quotaRestrictions(database *mysqlv1alpha1.Database) []string {
+	if database.Status.Quota == nil || database.Status.Quota.State != "Exceeded" {
+		return nil
+	}
+	switch database.Status.Quota.Action {
+	case mysqlv1alpha1.QuotaActionRevokeWrite:
+		return mysqlv1alpha1.WritePrivileges
+	case mysqlv1alpha1.QuotaActionReadOnly:
+		return mysqlv1alpha1.PrivilegesExcept(mysqlv1alpha1.CleanupPrivileges)
+	}
+	return nil
+}
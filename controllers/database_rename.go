@@ -0,0 +1,418 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+// renamePending Whether spec.name differs from the schema created, or a rename was interrupted.
+func (r *DatabaseReconciler) renamePending(loop *DatabaseLoopContext) bool {
+	if loop.instance.RenameInProgress() {
+		return true
+	}
+	return loop.instance.Status.Name != "" && !loop.instance.Status.CreationTime.IsZero() &&
+		loop.instance.Status.Name != loop.name
+}
+
+// renameOwned Whether the control database records the schema as belonging to this Database.
+func (r *DatabaseReconciler) renameOwned(loop *DatabaseLoopContext, schema string) bool {
+	var managedDatabase orm.ManagedDatabase
+	loop.db.Limit(1).Find(&managedDatabase, "uuid = ?", string(loop.instance.UID))
+	return managedDatabase.DatabaseName == schema && managedDatabase.Name == loop.instance.Name &&
+		managedDatabase.Namespace == loop.instance.Namespace
+}
+
+// databaseRename Moves the schema to spec.name. MySQL has no RENAME DATABASE, so a new schema is created, the
+// tables moved across and everything else recreated before the old schema is dropped. Each phase is recorded in
+// status as it completes so an interrupted rename resumes where it stopped.
+func (r *DatabaseReconciler) databaseRename(ctx context.Context, loop *DatabaseLoopContext) error {
+
+	rename := loop.instance.Status.Rename
+	if rename == nil || rename.Phase == mysqlv1alpha1.RenamePhaseComplete {
+		from := loop.instance.Status.Name
+		to := loop.name
+		if !r.renameOwned(loop, from) {
+			loop.instance.Status.Message = "No permission to rename this database."
+			return nil
+		}
+		if orm.DatabaseExists(loop.db, to) != nil {
+			loop.instance.Status.Message = "Cannot rename database, " + to + " already exists"
+			return nil
+		}
+		if loop.instance.Status.ReadOnly {
+			loop.instance.Status.Message = "Cannot rename database while it is read only"
+			return nil
+		}
+		rename = &mysqlv1alpha1.RenameStatus{
+			From:      from,
+			To:        to,
+			Phase:     mysqlv1alpha1.RenamePhaseCreatingSchema,
+			StartTime: metav1.NewTime(time.Now()),
+		}
+		loop.instance.Status.Rename = rename
+		r.Log.Info("Renaming database", "Host", loop.adminConnection.Spec.Host, "From", from, "To", to)
+	} else if !r.renameOwned(loop, rename.From) && !r.renameOwned(loop, rename.To) {
+		loop.instance.Status.Message = "No permission to rename this database."
+		return nil
+	}
+
+	for rename.Phase != mysqlv1alpha1.RenamePhaseComplete {
+		var err error
+		var next mysqlv1alpha1.RenamePhase
+		switch rename.Phase {
+		case mysqlv1alpha1.RenamePhaseCreatingSchema:
+			err = r.renameCreateSchema(loop, rename)
+			next = mysqlv1alpha1.RenamePhaseMovingTables
+		case mysqlv1alpha1.RenamePhaseMovingTables:
+			err = r.renameTables(ctx, loop)
+			next = mysqlv1alpha1.RenamePhaseRecreatingObjects
+		case mysqlv1alpha1.RenamePhaseRecreatingObjects:
+			err = r.renameObjects(loop, rename)
+			next = mysqlv1alpha1.RenamePhaseRegranting
+		case mysqlv1alpha1.RenamePhaseRegranting:
+			err = r.renameGrants(ctx, loop, rename)
+			next = mysqlv1alpha1.RenamePhaseDroppingSchema
+		case mysqlv1alpha1.RenamePhaseDroppingSchema:
+			err = r.renameDropSchema(loop, rename)
+			next = mysqlv1alpha1.RenamePhaseComplete
+		default:
+			err = fmt.Errorf("unknown rename phase %s", rename.Phase)
+		}
+		if err != nil {
+			r.Log.Error(err, "Failed to rename database.", "Host", loop.adminConnection.Spec.Host,
+				"From", rename.From, "To", rename.To, "Phase", rename.Phase)
+			loop.instance.Status.Message = "Failed to rename database during " + string(rename.Phase)
+			return err
+		}
+
+		// The rename may have been refreshed by progress updates within the phase
+		rename = loop.instance.Status.Rename
+		rename.Phase = next
+		loop.instance.Status.Message = "Renaming database: " + string(next)
+		if next == mysqlv1alpha1.RenamePhaseComplete {
+			rename.CompletionTime = metav1.NewTime(time.Now())
+			loop.instance.Status.Name = rename.To
+			loop.instance.Status.Message = "Renamed database"
+			r.Log.Info("Successfully renamed database", "Host", loop.adminConnection.Spec.Host,
+				"From", rename.From, "To", rename.To)
+		}
+		err = r.Status().Update(ctx, loop.instance)
+		if err != nil {
+			return err
+		}
+		rename = loop.instance.Status.Rename
+	}
+	return nil
+}
+
+// renameCreateSchema Creates the new schema with the options of the old one and points the control database at it.
+func (r *DatabaseReconciler) renameCreateSchema(loop *DatabaseLoopContext, rename *mysqlv1alpha1.RenameStatus) error {
+
+	schema := orm.DatabaseExists(loop.db, rename.From)
+	if schema == nil {
+		return fmt.Errorf("schema %s no longer exists", rename.From)
+	}
+
+	createQuery := "CREATE DATABASE IF NOT EXISTS " + mysqlv1alpha1.QuoteIdentifier(rename.To) +
+		" CHARACTER SET " + schema.DefaultCharacterSet + " COLLATE " + schema.DefaultCollation
+	if schema.DefaultEncryption == "YES" {
+		createQuery += encryptionOption(true)
+	}
+	tx := loop.db.Exec(createQuery)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	tx = loop.db.Model(&orm.ManagedDatabase{}).Where("uuid = ?", string(loop.instance.UID)).
+		Update("database_name", rename.To)
+	return tx.Error
+}
+
+// renameTables Moves each base table with RENAME TABLE. Triggers cannot move between schemas, so they are recorded,
+// dropped and recreated around each table.
+func (r *DatabaseReconciler) renameTables(ctx context.Context, loop *DatabaseLoopContext) error {
+
+	rename := loop.instance.Status.Rename
+	from := mysqlv1alpha1.QuoteIdentifier(rename.From)
+	to := mysqlv1alpha1.QuoteIdentifier(rename.To)
+
+	// Finishing a table interrupted between dropping and recreating its triggers
+	err := r.renameRestoreTriggers(loop, rename)
+	if err != nil {
+		return err
+	}
+
+	var tables []cloneTable
+	tx := loop.db.Raw("SELECT TABLE_NAME, TABLE_TYPE FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? "+
+		"AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME", rename.From).Scan(&tables)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	for _, table := range tables {
+		var triggers []string
+		tx = loop.db.Raw("SELECT TRIGGER_NAME FROM INFORMATION_SCHEMA.TRIGGERS WHERE EVENT_OBJECT_SCHEMA = ? "+
+			"AND EVENT_OBJECT_TABLE = ? ORDER BY ACTION_ORDER", rename.From, table.TableName).Scan(&triggers)
+		if tx.Error != nil {
+			return tx.Error
+		}
+
+		if len(triggers) > 0 {
+			rename.Triggers = make([]mysqlv1alpha1.RenameTrigger, 0, len(triggers))
+			for _, trigger := range triggers {
+				statement, err := showCreate(loop, "TRIGGER", from+"."+mysqlv1alpha1.QuoteIdentifier(trigger),
+					"SQL Original Statement")
+				if err != nil {
+					return err
+				}
+				rename.Triggers = append(rename.Triggers, mysqlv1alpha1.RenameTrigger{
+					Name:      trigger,
+					Statement: statement,
+				})
+			}
+			// Recording the definitions before anything is dropped
+			err = r.Status().Update(ctx, loop.instance)
+			if err != nil {
+				return err
+			}
+			rename = loop.instance.Status.Rename
+
+			for _, trigger := range triggers {
+				tx = loop.db.Exec("DROP TRIGGER IF EXISTS " + from + "." + mysqlv1alpha1.QuoteIdentifier(trigger))
+				if tx.Error != nil {
+					return tx.Error
+				}
+			}
+		}
+
+		tx = loop.db.Exec("RENAME TABLE " + from + "." + mysqlv1alpha1.QuoteIdentifier(table.TableName) +
+			" TO " + to + "." + mysqlv1alpha1.QuoteIdentifier(table.TableName))
+		if tx.Error != nil {
+			return tx.Error
+		}
+		err = r.renameRestoreTriggers(loop, rename)
+		if err != nil {
+			return err
+		}
+
+		rename.Tables = append(rename.Tables, table.TableName)
+		err = r.Status().Update(ctx, loop.instance)
+		if err != nil {
+			return err
+		}
+		rename = loop.instance.Status.Rename
+	}
+	return nil
+}
+
+// renameRestoreTriggers Creates the recorded triggers in the new schema, skipping any already there.
+func (r *DatabaseReconciler) renameRestoreTriggers(loop *DatabaseLoopContext, rename *mysqlv1alpha1.RenameStatus) error {
+
+	statements := make([]string, 0, len(rename.Triggers))
+	for _, trigger := range rename.Triggers {
+		var count int64
+		tx := loop.db.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TRIGGERS WHERE TRIGGER_SCHEMA = ? "+
+			"AND TRIGGER_NAME = ?", rename.To, trigger.Name).Scan(&count)
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if count == 0 {
+			statements = append(statements, renameReferences(trigger.Statement, rename))
+		}
+	}
+	err := execInSchema(loop.db, rename.To, statements)
+	if err != nil {
+		return err
+	}
+	rename.Triggers = nil
+	return nil
+}
+
+// renameObjects Recreates the views, routines and events of the old schema in the new one.
+func (r *DatabaseReconciler) renameObjects(loop *DatabaseLoopContext, rename *mysqlv1alpha1.RenameStatus) error {
+
+	var views []string
+	tx := loop.db.Raw("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = ? "+
+		"ORDER BY TABLE_NAME", rename.From).Scan(&views)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	err := r.cloneViews(loop, rename.From, views)
+	if err != nil {
+		return err
+	}
+
+	from := mysqlv1alpha1.QuoteIdentifier(rename.From)
+	statements := make([]string, 0)
+
+	var routines []struct {
+		RoutineName string `gorm:"column:ROUTINE_NAME"`
+		RoutineType string `gorm:"column:ROUTINE_TYPE"`
+	}
+	tx = loop.db.Raw("SELECT ROUTINE_NAME, ROUTINE_TYPE FROM INFORMATION_SCHEMA.ROUTINES WHERE ROUTINE_SCHEMA = ? "+
+		"ORDER BY ROUTINE_NAME", rename.From).Scan(&routines)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, routine := range routines {
+		name := mysqlv1alpha1.QuoteIdentifier(routine.RoutineName)
+		column := "Create Procedure"
+		if routine.RoutineType == "FUNCTION" {
+			column = "Create Function"
+		}
+		statement, err := showCreate(loop, routine.RoutineType, from+"."+name, column)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, "DROP "+routine.RoutineType+" IF EXISTS "+name,
+			renameReferences(statement, rename))
+	}
+
+	var events []string
+	tx = loop.db.Raw("SELECT EVENT_NAME FROM INFORMATION_SCHEMA.EVENTS WHERE EVENT_SCHEMA = ? "+
+		"ORDER BY EVENT_NAME", rename.From).Scan(&events)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, event := range events {
+		name := mysqlv1alpha1.QuoteIdentifier(event)
+		statement, err := showCreate(loop, "EVENT", from+"."+name, "Create Event")
+		if err != nil {
+			return err
+		}
+		statements = append(statements, "DROP EVENT IF EXISTS "+name, renameReferences(statement, rename))
+	}
+
+	return execInSchema(loop.db, rename.To, statements)
+}
+
+// renameGrants Grants each DatabaseUser referencing the Database the same privileges on the new schema, removing
+// them from the old one. The DatabaseUser reconciler records the new grants in its status.
+func (r *DatabaseReconciler) renameGrants(ctx context.Context, loop *DatabaseLoopContext,
+	rename *mysqlv1alpha1.RenameStatus) error {
+
+	userList := &mysqlv1alpha1.DatabaseUserList{}
+	err := r.Client.List(ctx, userList, &client.ListOptions{Namespace: loop.instance.Namespace})
+	if err != nil {
+		return err
+	}
+
+	grantOn := grantOnSchema(rename.From)
+	for _, user := range userList.Items {
+		if user.Status.Username == "" || !user.Spec.ReferencesDatabase(loop.instance.Name) {
+			continue
+		}
+		for _, host := range user.Status.CurrentHosts() {
+			err = r.renameAccountGrants(loop, mysqlv1alpha1.Account(user.Status.Username, host), grantOn, rename)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renameAccountGrants Moves the grants of one user@host account from the old schema to the new one.
+func (r *DatabaseReconciler) renameAccountGrants(loop *DatabaseLoopContext, account string, grantOn *regexp.Regexp,
+	rename *mysqlv1alpha1.RenameStatus) error {
+
+	var results []map[string]interface{}
+	tx := loop.db.Raw("SHOW GRANTS FOR " + account).Scan(&results)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, row := range results {
+		for key := range row {
+			grant := fmt.Sprintf("%v", row[key])
+			match := grantOn.FindStringSubmatch(grant)
+			if match == nil {
+				continue
+			}
+			tx = loop.db.Exec(movedGrant(grant, match[1], rename))
+			if tx.Error != nil {
+				return tx.Error
+			}
+			tx = loop.db.Exec("REVOKE ALL PRIVILEGES ON " + match[1] + " FROM " + account)
+			if tx.Error != nil {
+				return tx.Error
+			}
+			r.Log.Info("Moved grant to renamed database", "Host", loop.adminConnection.Spec.Host,
+				"Account", account, "From", rename.From, "To", rename.To)
+		}
+	}
+	return nil
+}
+
+// grantOnSchema Matches SHOW GRANTS output on the schema or one of its tables, capturing the object. Database level
+// grants show the schema with its wildcards escaped, those made before they were escaped without.
+func grantOnSchema(schema string) *regexp.Regexp {
+	return regexp.MustCompile("ON (" + regexp.QuoteMeta(mysqlv1alpha1.QuoteSchemaPattern(schema)+".*") + "|" +
+		regexp.QuoteMeta(mysqlv1alpha1.QuoteIdentifier(schema)+".") + "(?:\\*|`(?:[^`]|``)+`)) TO ")
+}
+
+// movedGrant The grant on the object of the new schema, escaping its wildcards for a database level grant.
+func movedGrant(grant string, object string, rename *mysqlv1alpha1.RenameStatus) string {
+	if strings.HasSuffix(object, ".*") {
+		return strings.Replace(grant, "ON "+object+" TO ", "ON "+mysqlv1alpha1.QuoteSchemaPattern(rename.To)+".* TO ", 1)
+	}
+	return renameReferences(grant, rename)
+}
+
+// renameDropSchema Drops the old schema once no tables remain in it.
+func (r *DatabaseReconciler) renameDropSchema(loop *DatabaseLoopContext, rename *mysqlv1alpha1.RenameStatus) error {
+
+	var count int64
+	tx := loop.db.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? "+
+		"AND TABLE_TYPE = 'BASE TABLE'", rename.From).Scan(&count)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if count > 0 {
+		return fmt.Errorf("%d tables remain in %s", count, rename.From)
+	}
+
+	tx = loop.db.Exec("DROP DATABASE IF EXISTS " + mysqlv1alpha1.QuoteIdentifier(rename.From))
+	return tx.Error
+}
+
+// renameReferences Points schema qualified references in a definition at the new schema.
+func renameReferences(statement string, rename *mysqlv1alpha1.RenameStatus) string {
+	return strings.ReplaceAll(statement, mysqlv1alpha1.QuoteIdentifier(rename.From)+".",
+		mysqlv1alpha1.QuoteIdentifier(rename.To)+".")
+}
+
+// showCreate Reads a definition column from SHOW CREATE for the object.
+func showCreate(loop *DatabaseLoopContext, kind string, object string, column string) (string, error) {
+	var definition []map[string]interface{}
+	tx := loop.db.Raw("SHOW CREATE " + kind + " " + object).Scan(&definition)
+	if tx.Error != nil {
+		return "", tx.Error
+	}
+	if len(definition) != 1 {
+		return "", fmt.Errorf("expected 1 row, got %v", len(definition))
+	}
+	return fmt.Sprintf("%v", definition[0][column]), nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+const (
+	// Number of tables reported in status.size.largestTables
+	largestTablesReported = 5
+)
+
+type tableStatistics struct {
+	TableName   string `gorm:"column:TABLE_NAME"`
+	TableType   string `gorm:"column:TABLE_TYPE"`
+	DataLength  int64  `gorm:"column:DATA_LENGTH"`
+	IndexLength int64  `gorm:"column:INDEX_LENGTH"`
+	DataFree    int64  `gorm:"column:DATA_FREE"`
+	TableRows   int64  `gorm:"column:TABLE_ROWS"`
+}
+
+// sizeRefreshDue Whether the statistics in status are older than the configured interval.
+func (r *DatabaseReconciler) sizeRefreshDue(loop *DatabaseLoopContext) bool {
+	if loop.instance.Status.Size == nil || r.StatisticsInterval <= 0 {
+		return true
+	}
+	return time.Since(loop.instance.Status.Size.RefreshTime.Time) >= r.StatisticsInterval
+}
+
+// databaseSize Gathers space used, object counts and the largest tables from INFORMATION_SCHEMA.
+func (r *DatabaseReconciler) databaseSize(loop *DatabaseLoopContext) error {
+
+	var tables []tableStatistics
+	tx := loop.db.Raw("SELECT TABLE_NAME, TABLE_TYPE, COALESCE(DATA_LENGTH, 0) AS DATA_LENGTH, "+
+		"COALESCE(INDEX_LENGTH, 0) AS INDEX_LENGTH, COALESCE(DATA_FREE, 0) AS DATA_FREE, "+
+		"COALESCE(TABLE_ROWS, 0) AS TABLE_ROWS FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? "+
+		"ORDER BY COALESCE(DATA_LENGTH, 0) + COALESCE(INDEX_LENGTH, 0) DESC, TABLE_NAME",
+		loop.name).Scan(&tables)
+	if tx.Error != nil {
+		r.Log.Error(tx.Error, "Failed to read table statistics", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name)
+		return tx.Error
+	}
+
+	var routines int64
+	tx = loop.db.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.ROUTINES WHERE ROUTINE_SCHEMA = ?",
+		loop.name).Scan(&routines)
+	if tx.Error != nil {
+		r.Log.Error(tx.Error, "Failed to count routines", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.name)
+		return tx.Error
+	}
+
+	size := &mysqlv1alpha1.DatabaseSize{
+		Routines:    int32(routines),
+		RefreshTime: metav1.NewTime(time.Now()),
+	}
+	for _, table := range tables {
+		if table.TableType == "VIEW" {
+			size.Views++
+			continue
+		}
+		size.Tables++
+		size.DataLength += table.DataLength
+		size.IndexLength += table.IndexLength
+		size.DataFree += table.DataFree
+		if len(size.LargestTables) < largestTablesReported {
+			size.LargestTables = append(size.LargestTables, mysqlv1alpha1.TableSize{
+				Name:        table.TableName,
+				DataLength:  table.DataLength,
+				IndexLength: table.IndexLength,
+				Rows:        table.TableRows,
+			})
+		}
+	}
+	size.TotalBytes = size.DataLength + size.IndexLength
+	size.Total = humanBytes(size.TotalBytes)
+
+	loop.instance.Status.Size = size
+	return nil
+}
+
+// humanBytes Formats a byte count with binary unit suffixes, e.g. 1.5Gi
+func humanBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ci", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,370 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"time"
+)
+
+const (
+	eventFinalizer = "mysql.apps.cuppett.dev/event-finalizer"
+)
+
+// DatabaseEventReconciler reconciles a DatabaseEvent object
+type DatabaseEventReconciler struct {
+	client.Client
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Connections map[types.UID]*orm.ConnectionDefinition
+}
+
+// serverEvent The event as reported by INFORMATION_SCHEMA.EVENTS
+type serverEvent struct {
+	Definition   *string    `gorm:"column:EVENT_DEFINITION"`
+	Definer      string     `gorm:"column:DEFINER"`
+	Detail       string     `gorm:"column:DETAIL"`
+	Status       string     `gorm:"column:STATUS"`
+	LastExecuted *time.Time `gorm:"column:LAST_EXECUTED"`
+}
+
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseevents,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseevents/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseevents/finalizers,verbs=update
+
+// Reconcile creates, alters or drops the scheduled event and reports its state from the server.
+func (r *DatabaseEventReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("DatabaseEvent", req.NamespacedName)
+
+	instance := &mysqlv1alpha1.DatabaseEvent{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("DatabaseEvent resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Failed to get DatabaseEvent")
+		return ctrl.Result{}, err
+	}
+
+	target, targetErr := getDatabaseTarget(ctx, r.Client, r.Connections, instance.Namespace, instance.Spec.Database)
+
+	if instance.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(instance, eventFinalizer) {
+			if targetErr == nil && instance.Status.Name != "" {
+				if err := r.dropEvent(target, instance.Status.Name); err != nil {
+					return ctrl.Result{}, err
+				}
+			} else {
+				r.Log.Info("Unable or not permitted to drop event, finalizing without dropping",
+					"Database", instance.Spec.Database, "Name", instance.Status.Name)
+			}
+
+			controllerutil.RemoveFinalizer(instance, eventFinalizer)
+			err := r.Update(ctx, instance)
+			if err != nil {
+				r.Log.Error(err, "Failure removing the finalizer.")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(instance, eventFinalizer) {
+		controllerutil.AddFinalizer(instance, eventFinalizer)
+		err = r.Update(ctx, instance)
+		if err != nil {
+			r.Log.Error(err, "Failure adding the finalizer.", "Name", instance.Name,
+				"Namespace", instance.Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if targetErr != nil {
+		r.Log.Info("Database not available for event", "Database", instance.Spec.Database,
+			"Reason", targetErr.Error())
+		instance.Status.Message = "Database not available: " + targetErr.Error()
+		if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	err = r.syncEvent(ctx, instance, target)
+	if err == nil {
+		r.checkScheduler(instance, target)
+	}
+	instance.Status.SyncTime = metav1.NewTime(time.Now())
+	if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+		r.Log.Error(statusErr, "Failure recording status.")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	// Requeued to refresh LAST_EXECUTED and catch drift
+	return ctrl.Result{RequeueAfter: routineDriftInterval}, err
+}
+
+// syncEvent Creates the event when missing, or alters it when the spec changed, it was renamed or the server copy
+// drifted from the one recorded after the last change.
+func (r *DatabaseEventReconciler) syncEvent(ctx context.Context, instance *mysqlv1alpha1.DatabaseEvent,
+	target *databaseTarget) error {
+
+	definer, err := definerAccount(ctx, r.Client, instance.Namespace, instance.Spec.Definer, target)
+	if err != nil {
+		instance.Status.Message = "Definer not available: " + err.Error()
+		return nil
+	}
+
+	schema := target.database.Status.Name
+	renaming := instance.Status.Name != "" && instance.Status.Name != instance.Spec.Name
+	current := instance.Spec.Name
+	if instance.Status.Name != "" {
+		current = instance.Status.Name
+	}
+
+	server, err := readServerEvent(target.db, schema, current)
+	if err != nil {
+		instance.Status.Message = "Failed to read event definition"
+		return err
+	}
+	if server != nil && instance.Status.Name == "" {
+		instance.Status.Message = "Event " + instance.Spec.Name + " already exists and is not managed by this DatabaseEvent"
+		return nil
+	}
+	if renaming {
+		existing, err := readServerEvent(target.db, schema, instance.Spec.Name)
+		if err != nil {
+			instance.Status.Message = "Failed to read event definition"
+			return err
+		}
+		if existing != nil {
+			instance.Status.Message = "Event " + instance.Spec.Name + " already exists and is not managed by this DatabaseEvent"
+			return nil
+		}
+	}
+
+	statementChecksum := checksum(eventStatement(&instance.Spec, definer, "CREATE", instance.Spec.Name, ""))
+	var statement string
+	if server == nil {
+		if instance.Status.Name != "" && !renaming && instance.Spec.OneTime() && !instance.Spec.Preserve &&
+			statementChecksum == instance.Status.Checksum {
+			// Completed one-time events are dropped by the server, running them again would repeat the work
+			instance.Status.State = ""
+			instance.Status.Message = "Event completed and was removed by the server"
+			return nil
+		}
+		statement = eventStatement(&instance.Spec, definer, "CREATE", instance.Spec.Name, "")
+	} else {
+		stateMatches := (server.Status == "ENABLED") == instance.Spec.Enabled || server.Status == "SLAVESIDE_DISABLED"
+		if !renaming && stateMatches && statementChecksum == instance.Status.Checksum &&
+			server.checksum() == instance.Status.ServerChecksum {
+			instance.Status.State = server.Status
+			instance.Status.LastExecuted = eventTime(server.LastExecuted)
+			instance.Status.Message = "Event in sync"
+			return nil
+		}
+		rename := ""
+		if renaming {
+			rename = instance.Spec.Name
+		}
+		statement = eventStatement(&instance.Spec, definer, "ALTER", current, rename)
+	}
+
+	err = execInSchema(target.db, schema, []string{statement})
+	if err != nil {
+		r.Log.Error(err, "Failed to apply event", "Database", schema, "Name", instance.Spec.Name)
+		instance.Status.Message = "Failed to apply event: " + err.Error()
+		return err
+	}
+	r.Log.Info("Successfully applied event", "Database", schema, "Name", instance.Spec.Name)
+
+	if instance.Status.Name == "" || server == nil {
+		instance.Status.CreationTime = metav1.NewTime(time.Now())
+		instance.Status.Message = "Created event"
+	} else {
+		instance.Status.Message = "Altered event"
+	}
+	instance.Status.Name = instance.Spec.Name
+	instance.Status.Definer = definer
+	instance.Status.Checksum = statementChecksum
+	instance.Status.ServerChecksum = ""
+	server, err = readServerEvent(target.db, schema, instance.Spec.Name)
+	if err == nil && server != nil {
+		instance.Status.ServerChecksum = server.checksum()
+		instance.Status.State = server.Status
+		instance.Status.LastExecuted = eventTime(server.LastExecuted)
+	}
+	return err
+}
+
+// checkScheduler Warns when the server will not run events
+func (r *DatabaseEventReconciler) checkScheduler(instance *mysqlv1alpha1.DatabaseEvent, target *databaseTarget) {
+
+	var scheduler string
+	tx := target.db.Raw("SELECT @@GLOBAL.event_scheduler").Scan(&scheduler)
+	if tx.Error != nil {
+		scheduler = target.adminConnection.Status.EventScheduler
+	}
+	instance.Status.SchedulerRunning = strings.EqualFold(scheduler, "ON")
+	if !instance.Status.SchedulerRunning {
+		r.Log.Info("Event scheduler is not running on the server", "Host", target.adminConnection.Spec.Host,
+			"EventScheduler", scheduler)
+		instance.Status.Message += fmt.Sprintf("; event_scheduler is %s on the server, the event will not run",
+			strings.ToUpper(scheduler))
+	}
+}
+
+// dropEvent Removes the event if it exists
+func (r *DatabaseEventReconciler) dropEvent(target *databaseTarget, name string) error {
+
+	dropQuery := "DROP EVENT IF EXISTS " + mysqlv1alpha1.QuoteIdentifier(target.database.Status.Name) + "." +
+		mysqlv1alpha1.QuoteIdentifier(name)
+	tx := target.db.Exec(dropQuery)
+	if tx.Error != nil {
+		r.Log.Error(tx.Error, "Failed to drop event", "Database", target.database.Status.Name, "Name", name)
+		return tx.Error
+	}
+	r.Log.Info("Successfully dropped event", "Database", target.database.Status.Name, "Name", name)
+	return nil
+}
+
+// eventStatement The CREATE or ALTER statement for the named event, optionally renaming it.
+func eventStatement(spec *mysqlv1alpha1.DatabaseEventSpec, definer string, verb string, name string,
+	renameTo string) string {
+
+	statement := verb + " DEFINER = " + definer + " EVENT " + mysqlv1alpha1.QuoteIdentifier(name) +
+		" ON SCHEDULE " + strings.TrimSpace(spec.Schedule)
+	if spec.Preserve {
+		statement += " ON COMPLETION PRESERVE"
+	} else {
+		statement += " ON COMPLETION NOT PRESERVE"
+	}
+	if renameTo != "" {
+		statement += " RENAME TO " + mysqlv1alpha1.QuoteIdentifier(renameTo)
+	}
+	if spec.Enabled {
+		statement += " ENABLE"
+	} else {
+		statement += " DISABLE"
+	}
+	return statement + " DO " + strings.TrimSpace(spec.Body)
+}
+
+// readServerEvent The event as reported by INFORMATION_SCHEMA, nil when it does not exist
+func readServerEvent(gormDB *gorm.DB, schema string, name string) (*serverEvent, error) {
+
+	var rows []serverEvent
+	tx := gormDB.Raw("SELECT EVENT_DEFINITION, DEFINER, STATUS, LAST_EXECUTED, "+
+		"CONCAT_WS('|', EVENT_TYPE, EXECUTE_AT, INTERVAL_VALUE, INTERVAL_FIELD, STARTS, ENDS, ON_COMPLETION) AS DETAIL "+
+		"FROM INFORMATION_SCHEMA.EVENTS WHERE EVENT_SCHEMA = ? AND EVENT_NAME = ?", schema, name).Scan(&rows)
+	if tx.Error != nil || len(rows) == 0 {
+		return nil, tx.Error
+	}
+	return &rows[0], nil
+}
+
+// checksum SHA-256 over the definition, definer and schedule. STATUS is left out as the server changes it when
+// an event expires.
+func (in *serverEvent) checksum() string {
+	definition := ""
+	if in.Definition != nil {
+		definition = *in.Definition
+	}
+	return checksum(definition + "\n" + in.Definer + "\n" + in.Detail)
+}
+
+func eventTime(t *time.Time) metav1.Time {
+	if t == nil {
+		return metav1.Time{}
+	}
+	return metav1.NewTime(*t)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseEventReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.DatabaseEvent{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&mysqlv1alpha1.Database{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForDatabase(ctx, a.(*mysqlv1alpha1.Database))
+			},
+		)).
+		Watches(&mysqlv1alpha1.DatabaseUser{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForUser(ctx, a.(*mysqlv1alpha1.DatabaseUser))
+			},
+		)).
+		Complete(r)
+}
+
+func (r *DatabaseEventReconciler) findObjectsForDatabase(ctx context.Context, database *mysqlv1alpha1.Database) []reconcile.Request {
+
+	eventList := &mysqlv1alpha1.DatabaseEventList{}
+	err := r.Client.List(ctx, eventList, &client.ListOptions{Namespace: database.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, event := range eventList.Items {
+		if event.Spec.Database == database.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&event),
+			})
+		}
+	}
+
+	return requests
+}
+
+func (r *DatabaseEventReconciler) findObjectsForUser(ctx context.Context, user *mysqlv1alpha1.DatabaseUser) []reconcile.Request {
+
+	eventList := &mysqlv1alpha1.DatabaseEventList{}
+	err := r.Client.List(ctx, eventList, &client.ListOptions{Namespace: user.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, event := range eventList.Items {
+		if event.Spec.Definer == user.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&event),
+			})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+var _ = Describe("DatabaseEvent", func() {
+
+	definer := "'app'@'%'"
+	spec := func() *DatabaseEventSpec {
+		return &DatabaseEventSpec{Name: "purge_sessions", Schedule: "EVERY 1 DAY", Enabled: true,
+			Body: "DELETE FROM sessions WHERE expires_at < NOW()"}
+	}
+
+	It("Creates an event", func() {
+		Expect(eventStatement(spec(), definer, "CREATE", "purge_sessions", "")).To(Equal(
+			"CREATE DEFINER = 'app'@'%' EVENT `purge_sessions` ON SCHEDULE EVERY 1 DAY ON COMPLETION NOT PRESERVE " +
+				"ENABLE DO DELETE FROM sessions WHERE expires_at < NOW()"))
+	})
+
+	It("Alters and renames a disabled event", func() {
+		wanted := spec()
+		wanted.Enabled = false
+		wanted.Preserve = true
+		Expect(eventStatement(wanted, definer, "ALTER", "old_purge", "purge_sessions")).To(Equal(
+			"ALTER DEFINER = 'app'@'%' EVENT `old_purge` ON SCHEDULE EVERY 1 DAY ON COMPLETION PRESERVE " +
+				"RENAME TO `purge_sessions` DISABLE DO DELETE FROM sessions WHERE expires_at < NOW()"))
+	})
+
+	It("Recognises one-time schedules", func() {
+		wanted := spec()
+		Expect(wanted.OneTime()).To(BeFalse())
+		wanted.Schedule = "at CURRENT_TIMESTAMP + INTERVAL 1 HOUR"
+		Expect(wanted.OneTime()).To(BeTrue())
+	})
+})
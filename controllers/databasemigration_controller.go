@@ -0,0 +1,221 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// DatabaseMigrationReconciler reconciles a DatabaseMigration object
+type DatabaseMigrationReconciler struct {
+	client.Client
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Connections map[types.UID]*orm.ConnectionDefinition
+}
+
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasemigrations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasemigrations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasemigrations/finalizers,verbs=update
+// +kubebuilder:rbac:groups=*,resources=configmaps,verbs=list;get;watch
+
+// Reconcile applies each listed migration not yet recorded in the history table of the control database.
+func (r *DatabaseMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("DatabaseMigration", req.NamespacedName)
+
+	instance := &mysqlv1alpha1.DatabaseMigration{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("DatabaseMigration resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Failed to get DatabaseMigration")
+		return ctrl.Result{}, err
+	}
+
+	target, err := getDatabaseTarget(ctx, r.Client, r.Connections, instance.Namespace, instance.Spec.Database)
+	if err != nil {
+		r.Log.Info("Database not available for migration", "Database", instance.Spec.Database, "Reason", err.Error())
+		instance.Status.Message = "Database not available: " + err.Error()
+		if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	err = r.migrate(ctx, instance, target)
+	instance.Status.SyncTime = metav1.NewTime(time.Now())
+	if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+		r.Log.Error(statusErr, "Failure recording status.")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return ctrl.Result{}, err
+}
+
+// migrate Applies the migrations in order, stopping at the first failure. Nothing is applied when a migration
+// already in the history has changed since it was applied.
+func (r *DatabaseMigrationReconciler) migrate(ctx context.Context, instance *mysqlv1alpha1.DatabaseMigration,
+	target *databaseTarget) error {
+
+	var history []orm.MigrationHistory
+	tx := target.db.Where("database_uuid = ?", string(target.database.UID)).Find(&history)
+	if tx.Error != nil {
+		instance.Status.Message = "Failed to read migration history"
+		return tx.Error
+	}
+	applied := make(map[string]orm.MigrationHistory)
+	for _, entry := range history {
+		applied[entry.Version] = entry
+	}
+
+	// Loading and checking every script before applying anything.
+	scripts := make([]string, len(instance.Spec.Migrations))
+	seen := make(map[string]bool)
+	for i, migration := range instance.Spec.Migrations {
+		if seen[migration.Version] {
+			instance.Status.Message = "Duplicate migration version " + migration.Version
+			return nil
+		}
+		seen[migration.Version] = true
+
+		script, err := mysqlv1alpha1.GetScriptSourceValue(ctx, r.Client, instance.Namespace, &migration.Script)
+		if err != nil {
+			instance.Status.Message = "Failed to read migration " + migration.Version + ": " + err.Error()
+			return err
+		}
+		scripts[i] = script
+
+		if entry, ok := applied[migration.Version]; ok && entry.Checksum != checksum(script) {
+			r.Log.Info("Refusing migrations, applied script has changed", "Database", target.database.Status.Name,
+				"Version", migration.Version)
+			instance.Status.Message = "Migration " + migration.Version + " has changed since it was applied"
+			return nil
+		}
+	}
+
+	var session *scriptSession
+	instance.Status.Applied = make([]mysqlv1alpha1.AppliedMigration, 0)
+	for i, migration := range instance.Spec.Migrations {
+		entry, ok := applied[migration.Version]
+		if !ok {
+			// Scripts come from the namespace, they run as its user rather than the admin connection
+			if session == nil {
+				var err error
+				session, err = openScriptSession(ctx, r.Client, instance.Namespace, instance.Spec.User, target)
+				if err != nil {
+					r.Log.Info("User not available for migration", "User", instance.Spec.User, "Reason", err.Error())
+					instance.Status.Message = "User not available: " + err.Error()
+					return err
+				}
+				defer session.Close()
+			}
+
+			err := session.exec(ctx, splitStatements(scripts[i]))
+			if err != nil {
+				r.Log.Error(err, "Failed to apply migration", "Database", target.database.Status.Name,
+					"Version", migration.Version)
+				instance.Status.Message = "Failed to apply migration " + migration.Version + ": " + err.Error()
+				return err
+			}
+
+			entry = orm.MigrationHistory{
+				DatabaseUuid:  string(target.database.UID),
+				Version:       migration.Version,
+				MigrationUuid: string(instance.UID),
+				Checksum:      checksum(scripts[i]),
+				AppliedAt:     time.Now(),
+			}
+			tx := target.db.Create(&entry)
+			if tx.Error != nil {
+				r.Log.Error(tx.Error, "Failed to record migration", "Database", target.database.Status.Name,
+					"Version", migration.Version)
+				instance.Status.Message = "Failed to record migration " + migration.Version
+				return tx.Error
+			}
+			r.Log.Info("Successfully applied migration", "Database", target.database.Status.Name,
+				"Version", migration.Version)
+		}
+
+		instance.Status.Applied = append(instance.Status.Applied, mysqlv1alpha1.AppliedMigration{
+			Version:     entry.Version,
+			Checksum:    entry.Checksum,
+			AppliedTime: metav1.NewTime(entry.AppliedAt),
+		})
+		instance.Status.CurrentVersion = entry.Version
+	}
+
+	instance.Status.Message = fmt.Sprintf("%d migrations applied", len(instance.Status.Applied))
+
+	// Reflecting the new version on the Database right away rather than waiting on its next reconcile.
+	version := orm.SchemaVersion(target.db, string(target.database.UID))
+	if target.database.Status.SchemaVersion != version {
+		target.database.Status.SchemaVersion = version
+		return r.Status().Update(ctx, target.database)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.DatabaseMigration{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&mysqlv1alpha1.Database{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForDatabase(ctx, a.(*mysqlv1alpha1.Database))
+			},
+		)).
+		Complete(r)
+}
+
+func (r *DatabaseMigrationReconciler) findObjectsForDatabase(ctx context.Context, database *mysqlv1alpha1.Database) []reconcile.Request {
+
+	// List all DatabaseMigration objects in the same namespace
+	migrationList := &mysqlv1alpha1.DatabaseMigrationList{}
+	err := r.Client.List(ctx, migrationList, &client.ListOptions{Namespace: database.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, migration := range migrationList.Items {
+		if migration.Spec.Database == database.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&migration),
+			})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"gorm.io/gorm"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+var _ = Describe("DatabaseMigration", func() {
+
+	Describe("Migration Scenario", Ordered, func() {
+
+		var database *Database
+		var gormDB *gorm.DB
+		migrationNamespacedName := types.NamespacedName{
+			Name:      "test-migration",
+			Namespace: "default",
+		}
+
+		scriptRef := func(key string) ScriptSource {
+			return ScriptSource{
+				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "test-migration"},
+					Key:                  key,
+				},
+			}
+		}
+
+		migrationMessage := func(ctx SpecContext, name string) func() string {
+			return func() string {
+				migration := &DatabaseMigration{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, migration)
+				Expect(err).ToNot(HaveOccurred())
+				return migration.Status.Message
+			}
+		}
+
+		tableRows := func(table string) int64 {
+			var count int64
+			gormDB.Raw("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?",
+				"test-migration-db", table).Scan(&count)
+			return count
+		}
+
+		BeforeAll(func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			var err error
+			gormDB, err = ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			configMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"001.sql":      "CREATE TABLE accounts (id INT PRIMARY KEY);",
+					"002.sql":      "INSERT INTO accounts VALUES (1);\nINSERT INTO accounts VALUES (2);",
+					"003.sql":      "CREATE TABLE notes (id INT PRIMARY KEY);",
+					"escalate.sql": "UPDATE zz_dba_operator.managed_databases SET namespace = 'other';",
+				},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+			password := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-user",
+					Namespace: "default",
+				},
+				StringData: map[string]string{"password": "Migrat0r-Passw0rd"},
+			}
+			Expect(k8sClient.Create(ctx, password)).To(Succeed())
+
+			database = &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-db",
+					Namespace: "default",
+				},
+				Spec: DatabaseSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Name: "test-migration-db",
+				},
+			}
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			Eventually(func() string {
+				databaseObject := &Database{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: database.Name},
+					databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				database = databaseObject
+				return databaseObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Database in sync"))
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-user",
+					Namespace: "default",
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-migration-user",
+					Identification: &Identification{
+						ClearText: true,
+						AuthString: &SecretKeySource{
+							SecretKeyRef: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "test-migration-user"},
+								Key:                  "password",
+							},
+						},
+					},
+					DatabaseList: []DatabasePermission{{Name: "test-migration-db"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, databaseUser)).To(Succeed())
+		}, NodeTimeout(time.Second*30))
+
+		It("Applies migrations as the user and records them", func(ctx SpecContext) {
+			migration := &DatabaseMigration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      migrationNamespacedName.Name,
+					Namespace: migrationNamespacedName.Namespace,
+				},
+				Spec: DatabaseMigrationSpec{
+					Database: "test-migration-db",
+					User:     "test-migration-user",
+					Migrations: []Migration{
+						{Version: "001", Script: scriptRef("001.sql")},
+						{Version: "002", Script: scriptRef("002.sql")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, migration)).To(Succeed())
+
+			Eventually(migrationMessage(ctx, migration.Name)).WithContext(ctx).Should(Equal("2 migrations applied"))
+
+			var history []orm.MigrationHistory
+			gormDB.Where("database_uuid = ?", string(database.UID)).Order("version").Find(&history)
+			Expect(history).To(HaveLen(2))
+			Expect(history[0].Version).To(Equal("001"))
+			Expect(history[0].Checksum).To(Equal(checksum("CREATE TABLE accounts (id INT PRIMARY KEY);")))
+			Expect(history[1].Version).To(Equal("002"))
+
+			var rows int64
+			gormDB.Raw("SELECT COUNT(*) FROM `test-migration-db`.accounts").Scan(&rows)
+			Expect(rows).To(Equal(int64(2)))
+
+			Eventually(func() string {
+				databaseObject := &Database{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: database.Name},
+					databaseObject)
+				Expect(err).ToNot(HaveOccurred())
+				return databaseObject.Status.SchemaVersion
+			}).WithContext(ctx).Should(Equal("002"))
+		}, NodeTimeout(time.Second*30))
+
+		It("Refuses further migrations once an applied script changes", func(ctx SpecContext) {
+			configMap := &v1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, migrationNamespacedName, configMap)).To(Succeed())
+			configMap.Data["001.sql"] = "CREATE TABLE accounts (id BIGINT PRIMARY KEY);"
+			Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+
+			Eventually(func() error {
+				migration := &DatabaseMigration{}
+				err := k8sClient.Get(ctx, migrationNamespacedName, migration)
+				Expect(err).ToNot(HaveOccurred())
+				migration.Spec.Migrations = append(migration.Spec.Migrations,
+					Migration{Version: "003", Script: scriptRef("003.sql")})
+				return k8sClient.Update(ctx, migration)
+			}).WithContext(ctx).Should(Succeed())
+
+			Eventually(migrationMessage(ctx, migrationNamespacedName.Name)).WithContext(ctx).
+				Should(Equal("Migration 001 has changed since it was applied"))
+			Expect(tableRows("notes")).To(Equal(int64(0)))
+
+			var count int64
+			gormDB.Model(&orm.MigrationHistory{}).Where("database_uuid = ?", string(database.UID)).Count(&count)
+			Expect(count).To(Equal(int64(2)))
+		}, NodeTimeout(time.Second*30))
+
+		It("Cannot reach beyond the grants of the user", func(ctx SpecContext) {
+			migration := &DatabaseMigration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-escalate",
+					Namespace: "default",
+				},
+				Spec: DatabaseMigrationSpec{
+					Database:   "test-migration-db",
+					User:       "test-migration-user",
+					Migrations: []Migration{{Version: "900", Script: scriptRef("escalate.sql")}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, migration)).To(Succeed())
+
+			Eventually(migrationMessage(ctx, migration.Name)).WithContext(ctx).
+				Should(HavePrefix("Failed to apply migration 900: "))
+
+			var managedDatabase orm.ManagedDatabase
+			gormDB.Limit(1).Find(&managedDatabase, "uuid = ?", string(database.UID))
+			Expect(managedDatabase.Namespace).To(Equal("default"))
+
+			var count int64
+			gormDB.Model(&orm.MigrationHistory{}).Where("database_uuid = ? AND version = ?",
+				string(database.UID), "900").Count(&count)
+			Expect(count).To(Equal(int64(0)))
+		}, NodeTimeout(time.Second*30))
+
+		It("Requires a user with a password", func(ctx SpecContext) {
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-nopass",
+					Namespace: "default",
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username:     "test-migration-nopass",
+					DatabaseList: []DatabasePermission{{Name: "test-migration-db"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, databaseUser)).To(Succeed())
+
+			migration := &DatabaseMigration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-migration-nopass",
+					Namespace: "default",
+				},
+				Spec: DatabaseMigrationSpec{
+					Database:   "test-migration-db",
+					User:       "test-migration-nopass",
+					Migrations: []Migration{{Version: "901", Script: scriptRef("003.sql")}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, migration)).To(Succeed())
+
+			Eventually(migrationMessage(ctx, migration.Name)).WithContext(ctx).
+				Should(Equal("User not available: user test-migration-nopass has no clear text password to log in with"))
+			Expect(tableRows("notes")).To(Equal(int64(0)))
+		}, NodeTimeout(time.Second*30))
+	})
+})
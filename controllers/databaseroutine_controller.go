@@ -0,0 +1,378 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"time"
+)
+
+const (
+	routineFinalizer = "mysql.apps.cuppett.dev/routine-finalizer"
+	// How often routines are compared with the server for drift
+	routineDriftInterval = 5 * time.Minute
+)
+
+// DatabaseRoutineReconciler reconciles a DatabaseRoutine object
+type DatabaseRoutineReconciler struct {
+	client.Client
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Connections map[types.UID]*orm.ConnectionDefinition
+}
+
+// serverRoutine The parts of a view, routine or trigger reported by INFORMATION_SCHEMA
+type serverRoutine struct {
+	Definition *string `gorm:"column:DEFINITION"`
+	Definer    string  `gorm:"column:DEFINER"`
+	Detail     string  `gorm:"column:DETAIL"`
+}
+
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseroutines,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseroutines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseroutines/finalizers,verbs=update
+
+// Reconcile creates or replaces the view, routine or trigger when the spec changes or the server copy drifts, and
+// drops it when the DatabaseRoutine is deleted.
+func (r *DatabaseRoutineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("DatabaseRoutine", req.NamespacedName)
+
+	instance := &mysqlv1alpha1.DatabaseRoutine{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("DatabaseRoutine resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Failed to get DatabaseRoutine")
+		return ctrl.Result{}, err
+	}
+
+	target, targetErr := getDatabaseTarget(ctx, r.Client, r.Connections, instance.Namespace, instance.Spec.Database)
+
+	if instance.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(instance, routineFinalizer) {
+			if targetErr == nil && instance.Status.Name != "" {
+				if err := r.dropRoutine(target, instance.Status.Type, instance.Status.Name); err != nil {
+					return ctrl.Result{}, err
+				}
+			} else {
+				r.Log.Info("Unable or not permitted to drop routine, finalizing without dropping",
+					"Database", instance.Spec.Database, "Name", instance.Status.Name)
+			}
+
+			controllerutil.RemoveFinalizer(instance, routineFinalizer)
+			err := r.Update(ctx, instance)
+			if err != nil {
+				r.Log.Error(err, "Failure removing the finalizer.")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(instance, routineFinalizer) {
+		controllerutil.AddFinalizer(instance, routineFinalizer)
+		err = r.Update(ctx, instance)
+		if err != nil {
+			r.Log.Error(err, "Failure adding the finalizer.", "Name", instance.Name,
+				"Namespace", instance.Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if targetErr != nil {
+		r.Log.Info("Database not available for routine", "Database", instance.Spec.Database,
+			"Reason", targetErr.Error())
+		instance.Status.Message = "Database not available: " + targetErr.Error()
+		if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	err = r.syncRoutine(ctx, instance, target)
+	instance.Status.SyncTime = metav1.NewTime(time.Now())
+	if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+		r.Log.Error(statusErr, "Failure recording status.")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return ctrl.Result{RequeueAfter: routineDriftInterval}, err
+}
+
+// syncRoutine Creates or replaces the object when the statement changed, it is missing or the server definition
+// no longer matches the one recorded after the last creation.
+func (r *DatabaseRoutineReconciler) syncRoutine(ctx context.Context, instance *mysqlv1alpha1.DatabaseRoutine,
+	target *databaseTarget) error {
+
+	if err := validateRoutineSpec(&instance.Spec); err != nil {
+		instance.Status.Message = "Invalid routine: " + err.Error()
+		return nil
+	}
+
+	definer, err := definerAccount(ctx, r.Client, instance.Namespace, instance.Spec.Definer, target)
+	if err != nil {
+		instance.Status.Message = "Definer not available: " + err.Error()
+		return nil
+	}
+
+	// Renamed or retyped, the previous object is removed first
+	if instance.Status.Name != "" &&
+		(instance.Status.Name != instance.Spec.Name || instance.Status.Type != instance.Spec.Type) {
+		if err = r.dropRoutine(target, instance.Status.Type, instance.Status.Name); err != nil {
+			instance.Status.Message = "Failed to drop previous " + string(instance.Status.Type)
+			return err
+		}
+		instance.Status.Name = ""
+		instance.Status.Type = ""
+		instance.Status.Checksum = ""
+		instance.Status.ServerChecksum = ""
+	}
+
+	server, err := readServerRoutine(target.db, target.database.Status.Name, instance.Spec.Type, instance.Spec.Name)
+	if err != nil {
+		instance.Status.Message = "Failed to read routine definition"
+		return err
+	}
+	if server != nil && instance.Status.Name == "" {
+		instance.Status.Message = fmt.Sprintf("%s %s already exists and is not managed by this DatabaseRoutine",
+			instance.Spec.Type, instance.Spec.Name)
+		return nil
+	}
+
+	statements := routineStatements(&instance.Spec, definer)
+	statementChecksum := checksum(strings.Join(statements, ";\n"))
+	drifted := server != nil && server.checksum() != instance.Status.ServerChecksum
+	if server != nil && !drifted && statementChecksum == instance.Status.Checksum {
+		instance.Status.Message = "Routine in sync"
+		return nil
+	}
+	if drifted {
+		r.Log.Info("Routine differs from the last applied definition, replacing", "Database",
+			target.database.Status.Name, "Type", instance.Spec.Type, "Name", instance.Spec.Name)
+	}
+
+	err = execInSchema(target.db, target.database.Status.Name, statements)
+	if err != nil {
+		r.Log.Error(err, "Failed to create routine", "Database", target.database.Status.Name,
+			"Type", instance.Spec.Type, "Name", instance.Spec.Name)
+		instance.Status.Message = "Failed to create routine: " + err.Error()
+		return err
+	}
+	r.Log.Info("Successfully created routine", "Database", target.database.Status.Name,
+		"Type", instance.Spec.Type, "Name", instance.Spec.Name)
+
+	instance.Status.Type = instance.Spec.Type
+	instance.Status.Name = instance.Spec.Name
+	instance.Status.Definer = definer
+	instance.Status.Checksum = statementChecksum
+	instance.Status.CreationTime = metav1.NewTime(time.Now())
+	instance.Status.ServerChecksum = ""
+	server, err = readServerRoutine(target.db, target.database.Status.Name, instance.Spec.Type, instance.Spec.Name)
+	if err == nil && server != nil {
+		instance.Status.ServerChecksum = server.checksum()
+	}
+	if drifted {
+		instance.Status.Message = "Replaced routine after drift"
+	} else {
+		instance.Status.Message = "Created routine"
+	}
+	return err
+}
+
+// dropRoutine Removes the object if it exists
+func (r *DatabaseRoutineReconciler) dropRoutine(target *databaseTarget, routineType mysqlv1alpha1.RoutineType,
+	name string) error {
+
+	dropQuery := "DROP " + string(routineType) + " IF EXISTS " +
+		mysqlv1alpha1.QuoteIdentifier(target.database.Status.Name) + "." + mysqlv1alpha1.QuoteIdentifier(name)
+	tx := target.db.Exec(dropQuery)
+	if tx.Error != nil {
+		r.Log.Error(tx.Error, "Failed to drop routine", "Database", target.database.Status.Name,
+			"Type", routineType, "Name", name)
+		return tx.Error
+	}
+	r.Log.Info("Successfully dropped routine", "Database", target.database.Status.Name,
+		"Type", routineType, "Name", name)
+	return nil
+}
+
+// validateRoutineSpec Checks the fields used match the type of routine
+func validateRoutineSpec(spec *mysqlv1alpha1.DatabaseRoutineSpec) error {
+	isTrigger := spec.Type == mysqlv1alpha1.RoutineTrigger
+	isRoutine := spec.Type == mysqlv1alpha1.RoutineProcedure || spec.Type == mysqlv1alpha1.RoutineFunction
+
+	if (spec.Returns != "") != (spec.Type == mysqlv1alpha1.RoutineFunction) {
+		return fmt.Errorf("returns is required for a FUNCTION and not permitted otherwise")
+	}
+	if !isRoutine && (spec.Parameters != "" || len(spec.Characteristics) > 0) {
+		return fmt.Errorf("parameters and characteristics are only permitted for a PROCEDURE or FUNCTION")
+	}
+	if isTrigger && (spec.Table == "" || spec.Timing == "" || spec.Event == "") {
+		return fmt.Errorf("table, timing and event are required for a TRIGGER")
+	}
+	if !isTrigger && (spec.Table != "" || spec.Timing != "" || spec.Event != "") {
+		return fmt.Errorf("table, timing and event are only permitted for a TRIGGER")
+	}
+	return nil
+}
+
+// routineStatements The statements creating or replacing the object. Views are replaced in place, other types have
+// no OR REPLACE in MySQL and are dropped and created on the same session.
+func routineStatements(spec *mysqlv1alpha1.DatabaseRoutineSpec, definer string) []string {
+
+	name := mysqlv1alpha1.QuoteIdentifier(spec.Name)
+	security := spec.SqlSecurity
+	if security == "" {
+		security = mysqlv1alpha1.SqlSecurityDefiner
+	}
+	body := strings.TrimSpace(spec.Body)
+
+	switch spec.Type {
+	case mysqlv1alpha1.RoutineView:
+		return []string{"CREATE OR REPLACE DEFINER = " + definer + " SQL SECURITY " + string(security) +
+			" VIEW " + name + " AS " + body}
+	case mysqlv1alpha1.RoutineTrigger:
+		return []string{
+			"DROP TRIGGER IF EXISTS " + name,
+			"CREATE DEFINER = " + definer + " TRIGGER " + name + " " + spec.Timing + " " + spec.Event + " ON " +
+				mysqlv1alpha1.QuoteIdentifier(spec.Table) + " FOR EACH ROW " + body,
+		}
+	}
+
+	create := "CREATE DEFINER = " + definer + " " + string(spec.Type) + " " + name + "(" + spec.Parameters + ")"
+	if spec.Type == mysqlv1alpha1.RoutineFunction {
+		create += " RETURNS " + spec.Returns
+	}
+	for _, characteristic := range spec.Characteristics {
+		create += " " + characteristic
+	}
+	create += " SQL SECURITY " + string(security) + " " + body
+	return []string{"DROP " + string(spec.Type) + " IF EXISTS " + name, create}
+}
+
+// readServerRoutine The object as reported by INFORMATION_SCHEMA, nil when it does not exist
+func readServerRoutine(gormDB *gorm.DB, schema string, routineType mysqlv1alpha1.RoutineType,
+	name string) (*serverRoutine, error) {
+
+	var rows []serverRoutine
+	var tx *gorm.DB
+	switch routineType {
+	case mysqlv1alpha1.RoutineView:
+		tx = gormDB.Raw("SELECT VIEW_DEFINITION AS DEFINITION, DEFINER, SECURITY_TYPE AS DETAIL "+
+			"FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", schema, name).Scan(&rows)
+	case mysqlv1alpha1.RoutineTrigger:
+		tx = gormDB.Raw("SELECT ACTION_STATEMENT AS DEFINITION, DEFINER, "+
+			"CONCAT(ACTION_TIMING, ' ', EVENT_MANIPULATION, ' ', EVENT_OBJECT_TABLE) AS DETAIL "+
+			"FROM INFORMATION_SCHEMA.TRIGGERS WHERE TRIGGER_SCHEMA = ? AND TRIGGER_NAME = ?",
+			schema, name).Scan(&rows)
+	default:
+		tx = gormDB.Raw("SELECT ROUTINE_DEFINITION AS DEFINITION, DEFINER, "+
+			"CONCAT(SECURITY_TYPE, ' ', IFNULL(DTD_IDENTIFIER, '')) AS DETAIL "+
+			"FROM INFORMATION_SCHEMA.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_NAME = ? AND ROUTINE_TYPE = ?",
+			schema, name, string(routineType)).Scan(&rows)
+	}
+	if tx.Error != nil || len(rows) == 0 {
+		return nil, tx.Error
+	}
+	return &rows[0], nil
+}
+
+// checksum SHA-256 over the definition, definer and type specific details
+func (in *serverRoutine) checksum() string {
+	definition := ""
+	if in.Definition != nil {
+		definition = *in.Definition
+	}
+	return checksum(definition + "\n" + in.Definer + "\n" + in.Detail)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseRoutineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.DatabaseRoutine{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&mysqlv1alpha1.Database{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForDatabase(ctx, a.(*mysqlv1alpha1.Database))
+			},
+		)).
+		Watches(&mysqlv1alpha1.DatabaseUser{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForUser(ctx, a.(*mysqlv1alpha1.DatabaseUser))
+			},
+		)).
+		Complete(r)
+}
+
+func (r *DatabaseRoutineReconciler) findObjectsForDatabase(ctx context.Context, database *mysqlv1alpha1.Database) []reconcile.Request {
+
+	routineList := &mysqlv1alpha1.DatabaseRoutineList{}
+	err := r.Client.List(ctx, routineList, &client.ListOptions{Namespace: database.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, routine := range routineList.Items {
+		if routine.Spec.Database == database.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&routine),
+			})
+		}
+	}
+
+	return requests
+}
+
+func (r *DatabaseRoutineReconciler) findObjectsForUser(ctx context.Context, user *mysqlv1alpha1.DatabaseUser) []reconcile.Request {
+
+	routineList := &mysqlv1alpha1.DatabaseRoutineList{}
+	err := r.Client.List(ctx, routineList, &client.ListOptions{Namespace: user.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, routine := range routineList.Items {
+		if routine.Spec.Definer == user.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&routine),
+			})
+		}
+	}
+
+	return requests
+}
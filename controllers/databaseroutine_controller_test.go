@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+var _ = Describe("DatabaseRoutine", func() {
+
+	definer := "'reporting'@'%'"
+
+	It("Replaces a view in place", func() {
+		spec := &DatabaseRoutineSpec{Name: "order_totals", Type: RoutineView,
+			Body: "SELECT COUNT(*) FROM orders\n"}
+		Expect(validateRoutineSpec(spec)).To(Succeed())
+		Expect(routineStatements(spec, definer)).To(Equal([]string{
+			"CREATE OR REPLACE DEFINER = 'reporting'@'%' SQL SECURITY DEFINER VIEW `order_totals` AS " +
+				"SELECT COUNT(*) FROM orders",
+		}))
+	})
+
+	It("Drops and creates a function", func() {
+		spec := &DatabaseRoutineSpec{Name: "order_count", Type: RoutineFunction, SqlSecurity: SqlSecurityInvoker,
+			Parameters: "account BIGINT", Returns: "INT", Characteristics: []string{"READS SQL DATA"},
+			Body: "RETURN (SELECT COUNT(*) FROM orders WHERE account_id = account)"}
+		Expect(validateRoutineSpec(spec)).To(Succeed())
+		Expect(routineStatements(spec, definer)).To(Equal([]string{
+			"DROP FUNCTION IF EXISTS `order_count`",
+			"CREATE DEFINER = 'reporting'@'%' FUNCTION `order_count`(account BIGINT) RETURNS INT " +
+				"READS SQL DATA SQL SECURITY INVOKER RETURN (SELECT COUNT(*) FROM orders WHERE account_id = account)",
+		}))
+	})
+
+	It("Drops and creates a trigger", func() {
+		spec := &DatabaseRoutineSpec{Name: "orders_touch", Type: RoutineTrigger, Table: "orders",
+			Timing: "BEFORE", Event: "UPDATE", Body: "SET NEW.updated_at = NOW()"}
+		Expect(validateRoutineSpec(spec)).To(Succeed())
+		Expect(routineStatements(spec, definer)).To(Equal([]string{
+			"DROP TRIGGER IF EXISTS `orders_touch`",
+			"CREATE DEFINER = 'reporting'@'%' TRIGGER `orders_touch` BEFORE UPDATE ON `orders` " +
+				"FOR EACH ROW SET NEW.updated_at = NOW()",
+		}))
+	})
+
+	It("Rejects fields not used by the type", func() {
+		Expect(validateRoutineSpec(&DatabaseRoutineSpec{Type: RoutineProcedure, Returns: "INT"})).ToNot(Succeed())
+		Expect(validateRoutineSpec(&DatabaseRoutineSpec{Type: RoutineFunction})).ToNot(Succeed())
+		Expect(validateRoutineSpec(&DatabaseRoutineSpec{Type: RoutineView, Table: "orders"})).ToNot(Succeed())
+		Expect(validateRoutineSpec(&DatabaseRoutineSpec{Type: RoutineTrigger, Table: "orders"})).ToNot(Succeed())
+	})
+})
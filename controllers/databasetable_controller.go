@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// Most recent statements kept in status.applied
+const maxAppliedStatements = 50
+
+// DatabaseTableReconciler reconciles a DatabaseTable object
+type DatabaseTableReconciler struct {
+	client.Client
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Connections map[types.UID]*orm.ConnectionDefinition
+}
+
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasetables,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasetables/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databasetables/finalizers,verbs=update
+
+// Reconcile compares the table on the server with the spec and applies the DDL needed to match it.
+func (r *DatabaseTableReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("DatabaseTable", req.NamespacedName)
+
+	instance := &mysqlv1alpha1.DatabaseTable{}
+	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("DatabaseTable resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		r.Log.Error(err, "Failed to get DatabaseTable")
+		return ctrl.Result{}, err
+	}
+
+	target, err := getDatabaseTarget(ctx, r.Client, r.Connections, instance.Namespace, instance.Spec.Database)
+	if err != nil {
+		r.Log.Info("Database not available for table", "Database", instance.Spec.Database, "Reason", err.Error())
+		instance.Status.Message = "Database not available: " + err.Error()
+		if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	err = r.syncTable(instance, target)
+	instance.Status.SyncTime = metav1.NewTime(time.Now())
+	if statusErr := r.Status().Update(ctx, instance); statusErr != nil {
+		r.Log.Error(statusErr, "Failure recording status.")
+		if err == nil {
+			err = statusErr
+		}
+	}
+	return ctrl.Result{}, err
+}
+
+// syncTable Applies the statements one at a time, recording each as it succeeds.
+func (r *DatabaseTableReconciler) syncTable(instance *mysqlv1alpha1.DatabaseTable, target *databaseTarget) error {
+
+	if err := validateTableSpec(&instance.Spec); err != nil {
+		instance.Status.Message = "Invalid table: " + err.Error()
+		return nil
+	}
+
+	actual, err := readTableDefinition(target.db, target.database.Status.Name, instance.Spec.Name)
+	if err != nil {
+		instance.Status.Message = "Failed to read table definition"
+		return err
+	}
+
+	statements, destructive := tableStatements(&instance.Spec, actual)
+	instance.Status.Pending = nil
+	if instance.Spec.AllowDestructiveChanges {
+		statements = append(statements, destructive...)
+	} else if len(destructive) > 0 {
+		instance.Status.Pending = destructive
+	}
+
+	for _, statement := range statements {
+		err = execInSchema(target.db, target.database.Status.Name, []string{statement})
+		if err != nil {
+			r.Log.Error(err, "Failed to apply table DDL", "Database", target.database.Status.Name,
+				"Table", instance.Spec.Name, "Statement", statement)
+			instance.Status.Message = "Failed to apply DDL: " + err.Error()
+			return err
+		}
+		r.Log.Info("Successfully applied table DDL", "Database", target.database.Status.Name,
+			"Table", instance.Spec.Name, "Statement", statement)
+		instance.Status.Applied = append(instance.Status.Applied, mysqlv1alpha1.AppliedStatement{
+			Statement:   statement,
+			AppliedTime: metav1.NewTime(time.Now()),
+		})
+	}
+	if len(instance.Status.Applied) > maxAppliedStatements {
+		instance.Status.Applied = instance.Status.Applied[len(instance.Status.Applied)-maxAppliedStatements:]
+	}
+
+	if len(instance.Status.Pending) > 0 {
+		instance.Status.Message = fmt.Sprintf("%d destructive changes require allowDestructiveChanges",
+			len(instance.Status.Pending))
+	} else if len(statements) > 0 {
+		instance.Status.Message = fmt.Sprintf("Applied %d statements", len(statements))
+	} else {
+		instance.Status.Message = "Table in sync"
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatabaseTableReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mysqlv1alpha1.DatabaseTable{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&mysqlv1alpha1.Database{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, a client.Object) []reconcile.Request {
+				return r.findObjectsForDatabase(ctx, a.(*mysqlv1alpha1.Database))
+			},
+		)).
+		Complete(r)
+}
+
+func (r *DatabaseTableReconciler) findObjectsForDatabase(ctx context.Context, database *mysqlv1alpha1.Database) []reconcile.Request {
+
+	// List all DatabaseTable objects in the same namespace
+	tableList := &mysqlv1alpha1.DatabaseTableList{}
+	err := r.Client.List(ctx, tableList, &client.ListOptions{Namespace: database.GetNamespace()})
+	if err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, table := range tableList.Items {
+		if table.Spec.Database == database.Name {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&table),
+			})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+var _ = Describe("DatabaseTable", func() {
+
+	spec := func() *DatabaseTableSpec {
+		status := "new"
+		return &DatabaseTableSpec{
+			Name: "orders",
+			Columns: []TableColumn{
+				{Name: "id", Type: "BIGINT UNSIGNED", AutoIncrement: true},
+				{Name: "status", Type: "VARCHAR(16)", Default: &status},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes:    []TableIndex{{Name: "idx_status", Columns: []string{"status"}}},
+		}
+	}
+	actual := func() *tableDefinition {
+		status := "new"
+		return &tableDefinition{
+			columns: map[string]actualColumn{
+				"id": {ColumnName: "id", ColumnType: "bigint(20) unsigned", IsNullable: "NO",
+					Extra: "auto_increment"},
+				"status": {ColumnName: "status", ColumnType: "varchar(16)", IsNullable: "NO",
+					ColumnDefault: &status},
+			},
+			primaryKey:  []string{"id"},
+			indexes:     map[string]TableIndex{"idx_status": {Name: "idx_status", Columns: []string{"status"}}},
+			foreignKeys: map[string]TableForeignKey{},
+		}
+	}
+
+	It("Creates a missing table", func() {
+		statements, destructive := tableStatements(spec(), nil)
+		Expect(destructive).To(BeEmpty())
+		Expect(statements).To(Equal([]string{"CREATE TABLE `orders` (\n" +
+			"  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
+			"  `status` VARCHAR(16) NOT NULL DEFAULT 'new',\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  INDEX `idx_status` (`status`)\n)"}))
+	})
+
+	It("Finds nothing to do for a matching table", func() {
+		statements, destructive := tableStatements(spec(), actual())
+		Expect(statements).To(BeEmpty())
+		Expect(destructive).To(BeEmpty())
+	})
+
+	It("Adds columns and indexes", func() {
+		wanted := spec()
+		wanted.Columns = append(wanted.Columns, TableColumn{Name: "notes", Type: "TEXT", Nullable: true})
+		wanted.Indexes[0].Unique = true
+		statements, destructive := tableStatements(wanted, actual())
+		Expect(destructive).To(BeEmpty())
+		Expect(statements).To(Equal([]string{
+			"ALTER TABLE `orders` DROP INDEX `idx_status`",
+			"ALTER TABLE `orders` ADD COLUMN `notes` TEXT NULL",
+			"ALTER TABLE `orders` ADD UNIQUE INDEX `idx_status` (`status`)",
+		}))
+	})
+
+	It("Holds back changes which may lose data", func() {
+		wanted := spec()
+		wanted.Columns = wanted.Columns[:1]
+		wanted.Columns[0].Type = "INT UNSIGNED"
+		wanted.Indexes = nil
+		statements, destructive := tableStatements(wanted, actual())
+		Expect(statements).To(Equal([]string{"ALTER TABLE `orders` DROP INDEX `idx_status`"}))
+		Expect(destructive).To(Equal([]string{
+			"ALTER TABLE `orders` MODIFY COLUMN `id` INT UNSIGNED NOT NULL AUTO_INCREMENT",
+			"ALTER TABLE `orders` DROP COLUMN `status`",
+		}))
+	})
+
+	DescribeTable("Writes defaults as literals",
+		func(value string, expected string) {
+			column := TableColumn{Name: "c", Type: "VARCHAR(16)", Nullable: true, Default: &value}
+			Expect(columnDefinition(column, nil)).To(Equal("`c` VARCHAR(16) NULL DEFAULT " + expected))
+		},
+		Entry("String", "new", "'new'"),
+		Entry("Number", "-1.5", "-1.5"),
+		Entry("NULL", "NULL", "NULL"),
+		Entry("CURRENT_TIMESTAMP", "current_timestamp(3)", "current_timestamp(3)"),
+		Entry("Quoted", "it's", "'it\\'s'"),
+		Entry("Expression", "(SELECT 1)", "'(SELECT 1)'"),
+		Entry("Injection", "'' SELECT authentication_string FROM mysql.user",
+			"'\\'\\' SELECT authentication_string FROM mysql.user'"),
+	)
+
+	DescribeTable("Column types",
+		func(columnType string, valid bool) {
+			wanted := spec()
+			wanted.Columns[1].Type = columnType
+			if valid {
+				Expect(validateTableSpec(wanted)).To(Succeed())
+			} else {
+				Expect(validateTableSpec(wanted)).To(HaveOccurred())
+			}
+		},
+		Entry("Plain", "TEXT", true),
+		Entry("Length", "varchar(255)", true),
+		Entry("Precision", "DECIMAL(10, 2) UNSIGNED ZEROFILL", true),
+		Entry("Double precision", "DOUBLE PRECISION", true),
+		Entry("Enum", "ENUM('new','done')", true),
+		Entry("Character set", "VARCHAR(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin", true),
+		Entry("Injection", "INT) SELECT authentication_string AS x FROM mysql.user /*", false),
+		Entry("Quote in enum", "ENUM('a'') SELECT 1 /*')", false),
+		Entry("Generated", "INT AS (1)", false),
+		Entry("Attribute", "INT REFERENCES accounts", false),
+		Entry("Statement", "INT; DROP TABLE x", false),
+		Entry("Unknown type", "SERIAL", false),
+		Entry("Length the server changes", "TEXT(100)", false),
+		Entry("Missing length", "VARCHAR", false),
+		Entry("Binary attribute", "VARCHAR(16) BINARY", false),
+		Entry("Unsigned date", "DATE UNSIGNED", false),
+		Entry("Character set on a number", "INT CHARACTER SET utf8mb4", false),
+		Entry("Double precision only", "DOUBLE(10)", false),
+		Entry("Enum of numbers", "ENUM(1, 2)", false),
+	)
+
+	DescribeTable("Matches the type the server reports",
+		func(columnType string, reported string, charset string, collation string) {
+			wanted := &DatabaseTableSpec{
+				Name:    "orders",
+				Columns: []TableColumn{{Name: "c", Type: columnType, Nullable: true}},
+			}
+			Expect(validateTableSpec(wanted)).To(Succeed())
+			existing := actualColumn{ColumnName: "c", ColumnType: reported, IsNullable: "YES"}
+			if charset != "" {
+				existing.CharacterSetName = &charset
+				existing.CollationName = &collation
+			}
+			statements, destructive := tableStatements(wanted, &tableDefinition{
+				columns:     map[string]actualColumn{"c": existing},
+				indexes:     map[string]TableIndex{},
+				foreignKeys: map[string]TableForeignKey{},
+			})
+			Expect(statements).To(BeEmpty())
+			Expect(destructive).To(BeEmpty())
+		},
+		Entry("Integer width", "INTEGER UNSIGNED", "int(10) unsigned", "", ""),
+		Entry("Zerofill", "INT ZEROFILL", "int(10) unsigned zerofill", "", ""),
+		Entry("Boolean", "BOOLEAN", "tinyint(1)", "", ""),
+		Entry("Decimal spacing", "DECIMAL(10, 2)", "decimal(10,2)", "", ""),
+		Entry("Decimal default", "DECIMAL", "decimal(10,0)", "", ""),
+		Entry("Decimal precision", "DECIMAL(5)", "decimal(5,0)", "", ""),
+		Entry("Numeric", "NUMERIC(8,3)", "decimal(8,3)", "", ""),
+		Entry("Double precision", "DOUBLE PRECISION", "double", "", ""),
+		Entry("Real", "REAL", "double", "", ""),
+		Entry("Float precision", "FLOAT(30)", "double", "", ""),
+		Entry("Float", "FLOAT(7, 4)", "float(7,4)", "", ""),
+		Entry("Bit", "BIT", "bit(1)", "", ""),
+		Entry("Char", "CHARACTER", "char(1)", "utf8mb4", "utf8mb4_0900_ai_ci"),
+		Entry("Character varying", "CHARACTER VARYING(16)", "varchar(16)", "utf8mb4", "utf8mb4_0900_ai_ci"),
+		Entry("Character set and collation", "VARCHAR(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin",
+			"varchar(16)", "utf8mb4", "utf8mb4_bin"),
+		Entry("utf8", "VARCHAR(16) CHARSET utf8", "varchar(16)", "utf8mb3", "utf8mb3_general_ci"),
+		Entry("Enum spacing", "ENUM('new', 'done')", "enum('new','done')", "utf8mb4", "utf8mb4_0900_ai_ci"),
+		Entry("Timestamp", "TIMESTAMP(0)", "timestamp", "", ""),
+		Entry("Fractional seconds", "DATETIME(3)", "datetime(3)", "", ""),
+		Entry("Year", "YEAR", "year(4)", "", ""),
+		Entry("JSON on MariaDB", "JSON", "longtext", "utf8mb4", "utf8mb4_bin"),
+	)
+
+	It("Holds back a change of collation", func() {
+		wanted := &DatabaseTableSpec{
+			Name:    "orders",
+			Columns: []TableColumn{{Name: "c", Type: "VARCHAR(16) COLLATE utf8mb4_bin", Nullable: true}},
+		}
+		charset, collation := "utf8mb4", "utf8mb4_0900_ai_ci"
+		statements, destructive := tableStatements(wanted, &tableDefinition{
+			columns: map[string]actualColumn{"c": {ColumnName: "c", ColumnType: "varchar(16)", IsNullable: "YES",
+				CharacterSetName: &charset, CollationName: &collation}},
+			indexes:     map[string]TableIndex{},
+			foreignKeys: map[string]TableForeignKey{},
+		})
+		Expect(statements).To(BeEmpty())
+		Expect(destructive).To(Equal([]string{
+			"ALTER TABLE `orders` MODIFY COLUMN `c` VARCHAR(16) COLLATE utf8mb4_bin NULL"}))
+	})
+
+	It("Rejects an index on an undefined column", func() {
+		wanted := spec()
+		wanted.Indexes[0].Columns = []string{"missing"}
+		Expect(validateTableSpec(wanted)).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,548 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"gorm.io/gorm"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tableDefinition The parts of an existing table compared against a DatabaseTable
+type tableDefinition struct {
+	columns     map[string]actualColumn
+	primaryKey  []string
+	indexes     map[string]mysqlv1alpha1.TableIndex
+	foreignKeys map[string]mysqlv1alpha1.TableForeignKey
+}
+
+type actualColumn struct {
+	ColumnName       string  `gorm:"column:COLUMN_NAME"`
+	ColumnType       string  `gorm:"column:COLUMN_TYPE"`
+	IsNullable       string  `gorm:"column:IS_NULLABLE"`
+	ColumnDefault    *string `gorm:"column:COLUMN_DEFAULT"`
+	Extra            string  `gorm:"column:EXTRA"`
+	CharacterSetName *string `gorm:"column:CHARACTER_SET_NAME"`
+	CollationName    *string `gorm:"column:COLLATION_NAME"`
+}
+
+// columnType A data type in the canonical form the server reports in COLUMN_TYPE, the character set and
+// collation are reported separately.
+type columnType struct {
+	name      string
+	params    []string
+	unsigned  bool
+	zerofill  bool
+	charset   string
+	collation string
+}
+
+// columnTypeRegEx The data types accepted, matching the pattern on the CRD for tables stored before it.
+var columnTypeRegEx = regexp.MustCompile(`(?i)^(tinyint|smallint|mediumint|int|integer|bigint|bool|boolean|decimal|dec|numeric|fixed|float|double|double precision|real|bit|date|datetime|timestamp|time|year|char|character|varchar|character varying|binary|varbinary|tinytext|text|mediumtext|longtext|tinyblob|blob|mediumblob|longblob|enum|set|json) ?(\((?: ?(?:[0-9]+|'[^'\\]*') ?,)* ?(?:[0-9]+|'[^'\\]*') ?\))?((?: (?:unsigned|signed|zerofill|(?:character set|charset|collate) [a-z0-9_]+))*)$`)
+var typeParamRegEx = regexp.MustCompile(`[0-9]+|'[^'\\]*'`)
+var typeAttributeRegEx = regexp.MustCompile(`(?i)unsigned|signed|zerofill|(?:character set|charset|collate) [a-z0-9_]+`)
+var numberRegEx = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+var defaultExpressionRegEx = regexp.MustCompile(`(?i)^(NULL|CURRENT_TIMESTAMP(\([0-6]?\))?)$`)
+
+// typeAliases The names the server reports for the aliases it accepts.
+var typeAliases = map[string]string{
+	"integer":           "int",
+	"bool":              "tinyint",
+	"boolean":           "tinyint",
+	"dec":               "decimal",
+	"numeric":           "decimal",
+	"fixed":             "decimal",
+	"double precision":  "double",
+	"real":              "double",
+	"character":         "char",
+	"character varying": "varchar",
+}
+
+// parseColumnType Breaks a data type into the form the server reports back, refusing types it would not accept
+// or report differently. Integer display widths are dropped as MySQL 8 does, the defaults of the rest filled in.
+func parseColumnType(value string) (*columnType, error) {
+
+	match := columnTypeRegEx.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return nil, fmt.Errorf("unsupported type %s", value)
+	}
+	parsed := &columnType{name: strings.ToLower(match[1]), params: typeParamRegEx.FindAllString(match[2], -1)}
+	if alias, ok := typeAliases[parsed.name]; ok {
+		parsed.name = alias
+	}
+	for _, attribute := range typeAttributeRegEx.FindAllString(match[3], -1) {
+		attribute = strings.ToLower(attribute)
+		switch {
+		case attribute == "unsigned":
+			parsed.unsigned = true
+		case attribute == "zerofill":
+			parsed.unsigned = true
+			parsed.zerofill = true
+		case strings.HasPrefix(attribute, "collate "):
+			parsed.collation = normalizeCharset(strings.TrimPrefix(attribute, "collate "))
+		case attribute != "signed":
+			parsed.charset = normalizeCharset(attribute[strings.LastIndex(attribute, " ")+1:])
+		}
+	}
+
+	quoted := len(parsed.params) > 0 && strings.HasPrefix(parsed.params[0], "'")
+	for _, param := range parsed.params {
+		if strings.HasPrefix(param, "'") != quoted {
+			return nil, fmt.Errorf("type %s mixes values and lengths", value)
+		}
+	}
+	minParams, maxParams, numeric, textual := 0, 0, false, false
+	switch parsed.name {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "float":
+		maxParams, numeric = 2, true
+		if parsed.name != "decimal" && parsed.name != "float" {
+			maxParams = 1
+		}
+	case "double":
+		maxParams, numeric = 2, true
+		if len(parsed.params) == 1 {
+			return nil, fmt.Errorf("type %s needs both precision and scale", value)
+		}
+	case "bit", "char", "binary":
+		maxParams, textual = 1, parsed.name == "char"
+	case "varchar", "varbinary":
+		minParams, maxParams, textual = 1, 1, parsed.name == "varchar"
+	case "datetime", "timestamp", "time", "year":
+		maxParams = 1
+	case "tinytext", "text", "mediumtext", "longtext":
+		textual = true
+	case "enum", "set":
+		minParams, maxParams, textual = 1, 1<<16, true
+	}
+	if len(parsed.params) < minParams || len(parsed.params) > maxParams || quoted != (parsed.name == "enum" ||
+		parsed.name == "set") {
+		return nil, fmt.Errorf("type %s has unsupported lengths or values", value)
+	}
+	if parsed.unsigned && !numeric {
+		return nil, fmt.Errorf("type %s cannot be unsigned", value)
+	}
+	if (parsed.charset != "" || parsed.collation != "") && !textual {
+		return nil, fmt.Errorf("type %s cannot have a character set", value)
+	}
+
+	switch parsed.name {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "year":
+		parsed.params = nil
+	case "decimal":
+		parsed.params = append(parsed.params, []string{"10", "0"}[len(parsed.params):]...)
+	case "float":
+		// A single precision picks between FLOAT and DOUBLE
+		if len(parsed.params) == 1 {
+			if precision, _ := strconv.Atoi(parsed.params[0]); precision > 24 {
+				parsed.name = "double"
+			}
+			parsed.params = nil
+		}
+	case "datetime", "timestamp", "time":
+		if len(parsed.params) == 1 && strings.Trim(parsed.params[0], "0") == "" {
+			parsed.params = nil
+		}
+	case "bit", "char", "binary":
+		if len(parsed.params) == 0 {
+			parsed.params = []string{"1"}
+		}
+	}
+	return parsed, nil
+}
+
+// String The type as reported in COLUMN_TYPE.
+func (in *columnType) String() string {
+	value := in.name
+	if len(in.params) > 0 {
+		value += "(" + strings.Join(in.params, ",") + ")"
+	}
+	if in.unsigned {
+		value += " unsigned"
+	}
+	if in.zerofill {
+		value += " zerofill"
+	}
+	return value
+}
+
+// normalizeCharset utf8 is reported as utf8mb3 by later servers.
+func normalizeCharset(name string) string {
+	if name == "utf8" || strings.HasPrefix(name, "utf8_") {
+		return "utf8mb3" + strings.TrimPrefix(name, "utf8")
+	}
+	return name
+}
+
+// columnTypeChanged Whether the existing column differs from the type, character set or collation asked for.
+func columnTypeChanged(column mysqlv1alpha1.TableColumn, existing actualColumn) bool {
+	wanted, err := parseColumnType(column.Type)
+	if err != nil {
+		return true
+	}
+	actual, err := parseColumnType(existing.ColumnType)
+	if err != nil {
+		return true
+	}
+	charset, collation := "", ""
+	if existing.CharacterSetName != nil {
+		charset = normalizeCharset(strings.ToLower(*existing.CharacterSetName))
+	}
+	if existing.CollationName != nil {
+		collation = normalizeCharset(strings.ToLower(*existing.CollationName))
+	}
+	// MariaDB reports JSON as the LONGTEXT it is stored in
+	if wanted.name == "json" && actual.name == "longtext" && collation == "utf8mb4_bin" {
+		actual.name = wanted.name
+	}
+	return wanted.String() != actual.String() ||
+		(wanted.charset != "" && wanted.charset != charset) ||
+		(wanted.collation != "" && wanted.collation != collation)
+}
+
+// normalizeDefault Compares defaults ignoring quoting, which differs between MySQL and MariaDB.
+func normalizeDefault(value *string) string {
+	if value == nil || strings.EqualFold(*value, "NULL") {
+		return ""
+	}
+	normalized := strings.TrimSpace(*value)
+	if len(normalized) >= 2 && strings.HasPrefix(normalized, "'") && strings.HasSuffix(normalized, "'") {
+		normalized = normalized[1 : len(normalized)-1]
+	}
+	if strings.EqualFold(normalized, "current_timestamp()") {
+		normalized = "CURRENT_TIMESTAMP"
+	}
+	return strings.ToUpper(normalized)
+}
+
+// normalizeAction RESTRICT is the default and reported as either RESTRICT or NO ACTION.
+func normalizeAction(action mysqlv1alpha1.ReferentialAction) string {
+	if action == "" || action == "NO ACTION" {
+		return "RESTRICT"
+	}
+	return strings.ToUpper(string(action))
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = mysqlv1alpha1.QuoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// defaultValue The default as written in DDL, only numbers and the expressions permitted go in unquoted.
+func defaultValue(value string) string {
+	if numberRegEx.MatchString(value) || defaultExpressionRegEx.MatchString(value) {
+		return value
+	}
+	return "'" + mysqlv1alpha1.Escape(value) + "'"
+}
+
+// columnDefinition The column as written in CREATE or ALTER TABLE.
+func columnDefinition(column mysqlv1alpha1.TableColumn, primaryKey []string) string {
+	definition := mysqlv1alpha1.QuoteIdentifier(column.Name) + " " + column.Type
+	if column.Nullable && !contains(primaryKey, column.Name) {
+		definition += " NULL"
+	} else {
+		definition += " NOT NULL"
+	}
+	if column.Default != nil {
+		definition += " DEFAULT " + defaultValue(*column.Default)
+	}
+	if column.AutoIncrement {
+		definition += " AUTO_INCREMENT"
+	}
+	return definition
+}
+
+func indexDefinition(index mysqlv1alpha1.TableIndex) string {
+	definition := "INDEX "
+	if index.Unique {
+		definition = "UNIQUE INDEX "
+	}
+	return definition + mysqlv1alpha1.QuoteIdentifier(index.Name) + " (" + quoteColumns(index.Columns) + ")"
+}
+
+func foreignKeyDefinition(foreignKey mysqlv1alpha1.TableForeignKey) string {
+	definition := "CONSTRAINT " + mysqlv1alpha1.QuoteIdentifier(foreignKey.Name) + " FOREIGN KEY (" +
+		quoteColumns(foreignKey.Columns) + ") REFERENCES " + mysqlv1alpha1.QuoteIdentifier(foreignKey.ReferencedTable) +
+		" (" + quoteColumns(foreignKey.ReferencedColumns) + ")"
+	if foreignKey.OnDelete != "" {
+		definition += " ON DELETE " + string(foreignKey.OnDelete)
+	}
+	if foreignKey.OnUpdate != "" {
+		definition += " ON UPDATE " + string(foreignKey.OnUpdate)
+	}
+	return definition
+}
+
+// validateTableSpec Checks the column types and that the names referenced within the spec are consistent.
+func validateTableSpec(spec *mysqlv1alpha1.DatabaseTableSpec) error {
+	columns := make([]string, 0, len(spec.Columns))
+	for _, column := range spec.Columns {
+		if contains(columns, column.Name) {
+			return fmt.Errorf("column %s listed more than once", column.Name)
+		}
+		if _, err := parseColumnType(column.Type); err != nil {
+			return fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		columns = append(columns, column.Name)
+	}
+	for _, column := range spec.PrimaryKey {
+		if !contains(columns, column) {
+			return fmt.Errorf("primary key column %s is not defined", column)
+		}
+	}
+	for _, index := range spec.Indexes {
+		for _, column := range index.Columns {
+			if !contains(columns, column) {
+				return fmt.Errorf("index %s column %s is not defined", index.Name, column)
+			}
+		}
+	}
+	for _, foreignKey := range spec.ForeignKeys {
+		if len(foreignKey.Columns) != len(foreignKey.ReferencedColumns) {
+			return fmt.Errorf("foreign key %s must reference as many columns as it has", foreignKey.Name)
+		}
+		for _, column := range foreignKey.Columns {
+			if !contains(columns, column) {
+				return fmt.Errorf("foreign key %s column %s is not defined", foreignKey.Name, column)
+			}
+		}
+	}
+	return nil
+}
+
+// tableStatements The DDL bringing the table in line with the spec, separating the statements which may lose
+// data. A nil actual definition creates the table.
+func tableStatements(spec *mysqlv1alpha1.DatabaseTableSpec, actual *tableDefinition) ([]string, []string) {
+
+	table := mysqlv1alpha1.QuoteIdentifier(spec.Name)
+	if actual == nil {
+		parts := make([]string, 0)
+		for _, column := range spec.Columns {
+			parts = append(parts, columnDefinition(column, spec.PrimaryKey))
+		}
+		if len(spec.PrimaryKey) > 0 {
+			parts = append(parts, "PRIMARY KEY ("+quoteColumns(spec.PrimaryKey)+")")
+		}
+		for _, index := range spec.Indexes {
+			parts = append(parts, indexDefinition(index))
+		}
+		for _, foreignKey := range spec.ForeignKeys {
+			parts = append(parts, foreignKeyDefinition(foreignKey))
+		}
+		return []string{"CREATE TABLE " + table + " (\n  " + strings.Join(parts, ",\n  ") + "\n)"}, nil
+	}
+
+	alter := "ALTER TABLE " + table + " "
+	statements := make([]string, 0)
+	destructive := make([]string, 0)
+
+	// Foreign keys are dropped first so the columns and indexes beneath them can change
+	wantedKeys := make(map[string]mysqlv1alpha1.TableForeignKey)
+	for _, foreignKey := range spec.ForeignKeys {
+		wantedKeys[foreignKey.Name] = foreignKey
+	}
+	addKeys := make([]mysqlv1alpha1.TableForeignKey, 0)
+	for _, name := range sortedKeys(actual.foreignKeys) {
+		wanted, ok := wantedKeys[name]
+		if !ok || !foreignKeyEqual(wanted, actual.foreignKeys[name]) {
+			statements = append(statements, alter+"DROP FOREIGN KEY "+mysqlv1alpha1.QuoteIdentifier(name))
+		}
+	}
+	for _, foreignKey := range spec.ForeignKeys {
+		existing, ok := actual.foreignKeys[foreignKey.Name]
+		if !ok || !foreignKeyEqual(foreignKey, existing) {
+			addKeys = append(addKeys, foreignKey)
+		}
+	}
+
+	wantedIndexes := make(map[string]mysqlv1alpha1.TableIndex)
+	for _, index := range spec.Indexes {
+		wantedIndexes[index.Name] = index
+	}
+	for _, name := range sortedKeys(actual.indexes) {
+		// The server adds an index for each foreign key lacking one
+		if _, ok := wantedKeys[name]; ok {
+			continue
+		}
+		if _, ok := actual.foreignKeys[name]; ok {
+			continue
+		}
+		wanted, ok := wantedIndexes[name]
+		if !ok || !indexEqual(wanted, actual.indexes[name]) {
+			statements = append(statements, alter+"DROP INDEX "+mysqlv1alpha1.QuoteIdentifier(name))
+		}
+	}
+
+	for _, column := range spec.Columns {
+		existing, ok := actual.columns[column.Name]
+		if !ok {
+			statements = append(statements, alter+"ADD COLUMN "+columnDefinition(column, spec.PrimaryKey))
+			continue
+		}
+		typeChanged := columnTypeChanged(column, existing)
+		nullable := column.Nullable && !contains(spec.PrimaryKey, column.Name)
+		if typeChanged || nullable != (existing.IsNullable == "YES") ||
+			normalizeDefault(column.Default) != normalizeDefault(existing.ColumnDefault) ||
+			column.AutoIncrement != strings.Contains(strings.ToLower(existing.Extra), "auto_increment") {
+			statement := alter + "MODIFY COLUMN " + columnDefinition(column, spec.PrimaryKey)
+			if typeChanged {
+				destructive = append(destructive, statement)
+			} else {
+				statements = append(statements, statement)
+			}
+		}
+	}
+
+	if strings.Join(spec.PrimaryKey, ",") != strings.Join(actual.primaryKey, ",") {
+		statement := alter
+		if len(actual.primaryKey) > 0 {
+			statement += "DROP PRIMARY KEY"
+			if len(spec.PrimaryKey) > 0 {
+				statement += ", "
+			}
+		}
+		if len(spec.PrimaryKey) > 0 {
+			statement += "ADD PRIMARY KEY (" + quoteColumns(spec.PrimaryKey) + ")"
+		}
+		statements = append(statements, statement)
+	}
+
+	for _, index := range spec.Indexes {
+		existing, ok := actual.indexes[index.Name]
+		if !ok || !indexEqual(index, existing) {
+			statements = append(statements, alter+"ADD "+indexDefinition(index))
+		}
+	}
+	for _, foreignKey := range addKeys {
+		statements = append(statements, alter+"ADD "+foreignKeyDefinition(foreignKey))
+	}
+
+	for _, name := range sortedKeys(actual.columns) {
+		found := false
+		for _, column := range spec.Columns {
+			found = found || column.Name == name
+		}
+		if !found {
+			destructive = append(destructive, alter+"DROP COLUMN "+mysqlv1alpha1.QuoteIdentifier(name))
+		}
+	}
+	return statements, destructive
+}
+
+func indexEqual(a mysqlv1alpha1.TableIndex, b mysqlv1alpha1.TableIndex) bool {
+	return a.Unique == b.Unique && strings.Join(a.Columns, ",") == strings.Join(b.Columns, ",")
+}
+
+func foreignKeyEqual(a mysqlv1alpha1.TableForeignKey, b mysqlv1alpha1.TableForeignKey) bool {
+	return strings.Join(a.Columns, ",") == strings.Join(b.Columns, ",") &&
+		a.ReferencedTable == b.ReferencedTable &&
+		strings.Join(a.ReferencedColumns, ",") == strings.Join(b.ReferencedColumns, ",") &&
+		normalizeAction(a.OnDelete) == normalizeAction(b.OnDelete) &&
+		normalizeAction(a.OnUpdate) == normalizeAction(b.OnUpdate)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readTableDefinition Loads the table from INFORMATION_SCHEMA, nil when it does not exist.
+func readTableDefinition(gormDB *gorm.DB, schema string, table string) (*tableDefinition, error) {
+
+	var columns []actualColumn
+	tx := gormDB.Raw("SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, CHARACTER_SET_NAME, "+
+		"COLLATION_NAME "+
+		"FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		schema, table).Scan(&columns)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	definition := &tableDefinition{
+		columns:     make(map[string]actualColumn),
+		indexes:     make(map[string]mysqlv1alpha1.TableIndex),
+		foreignKeys: make(map[string]mysqlv1alpha1.TableForeignKey),
+	}
+	for _, column := range columns {
+		definition.columns[column.ColumnName] = column
+	}
+
+	var statistics []struct {
+		IndexName  string `gorm:"column:INDEX_NAME"`
+		NonUnique  int    `gorm:"column:NON_UNIQUE"`
+		ColumnName string `gorm:"column:COLUMN_NAME"`
+	}
+	tx = gormDB.Raw("SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM INFORMATION_SCHEMA.STATISTICS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX", schema, table).Scan(&statistics)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, row := range statistics {
+		if row.IndexName == "PRIMARY" {
+			definition.primaryKey = append(definition.primaryKey, row.ColumnName)
+			continue
+		}
+		index := definition.indexes[row.IndexName]
+		index.Name = row.IndexName
+		index.Unique = row.NonUnique == 0
+		index.Columns = append(index.Columns, row.ColumnName)
+		definition.indexes[row.IndexName] = index
+	}
+
+	var keyColumns []struct {
+		ConstraintName       string `gorm:"column:CONSTRAINT_NAME"`
+		ColumnName           string `gorm:"column:COLUMN_NAME"`
+		ReferencedTableName  string `gorm:"column:REFERENCED_TABLE_NAME"`
+		ReferencedColumnName string `gorm:"column:REFERENCED_COLUMN_NAME"`
+		UpdateRule           string `gorm:"column:UPDATE_RULE"`
+		DeleteRule           string `gorm:"column:DELETE_RULE"`
+	}
+	tx = gormDB.Raw("SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, "+
+		"r.UPDATE_RULE, r.DELETE_RULE FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE k "+
+		"JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS r ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA "+
+		"AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME "+
+		"WHERE k.TABLE_SCHEMA = ? AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL "+
+		"ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION", schema, table).Scan(&keyColumns)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, row := range keyColumns {
+		foreignKey := definition.foreignKeys[row.ConstraintName]
+		foreignKey.Name = row.ConstraintName
+		foreignKey.ReferencedTable = row.ReferencedTableName
+		foreignKey.Columns = append(foreignKey.Columns, row.ColumnName)
+		foreignKey.ReferencedColumns = append(foreignKey.ReferencedColumns, row.ReferencedColumnName)
+		foreignKey.OnUpdate = mysqlv1alpha1.ReferentialAction(row.UpdateRule)
+		foreignKey.OnDelete = mysqlv1alpha1.ReferentialAction(row.DeleteRule)
+		definition.foreignKeys[row.ConstraintName] = foreignKey
+	}
+	return definition, nil
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+const (
+	caCertificateKey           = "ca.crt"
+	defaultCertificateValidity = 90 * 24 * time.Hour
+	// How often a certificate the authority could not issue is tried again
+	certificateRetryInterval = time.Hour
+)
+
+// attributeNames The short names OpenSSL gives the attributes of a distinguished name, as found in x509_issuer and
+// x509_subject of mysql.user.
+var attributeNames = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.5":                    "serialNumber",
+	"2.5.4.6":                    "C",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.9":                    "street",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.17":                   "postalCode",
+	"1.2.840.113549.1.9.1":       "emailAddress",
+	"0.9.2342.19200300.100.1.1":  "UID",
+	"0.9.2342.19200300.100.1.25": "DC",
+}
+
+// userCertificate Issues the client certificate of the user, again once renewal is due or the account, the
+// certificate authority or the Secret changed. Returns whether a certificate was issued.
+func (r *DatabaseUserReconciler) userCertificate(ctx context.Context, loop *UserLoopContext) (bool, error) {
+
+	if loop.instance.Spec.ClientCertificate == nil {
+		loop.instance.Status.ClientCertificate = nil
+		return false, nil
+	}
+
+	authority := loop.adminConnection.Spec.ClientCertificateAuthority
+	if authority == nil {
+		return false, fmt.Errorf("AdminConnection %s has no clientCertificateAuthority to issue client certificates",
+			loop.adminConnection.Name)
+	}
+	caSecret := &v1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: loop.adminConnection.Namespace, Name: authority.Name},
+		caSecret)
+	if err != nil {
+		return false, err
+	}
+	caCertificate, caKey, err := parseCertificateAuthority(caSecret)
+	if err != nil {
+		return false, fmt.Errorf("certificate authority %s/%s: %w", caSecret.Namespace, caSecret.Name, err)
+	}
+
+	secretName := loop.instance.ClientCertificateSecretName()
+	secret := &v1.Secret{}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: loop.instance.Namespace, Name: secretName}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(secret, loop.instance) {
+		return false, fmt.Errorf("secret %s for the client certificate is owned by something else", secretName)
+	}
+
+	current := loop.instance.Status.ClientCertificate
+	unchanged := exists && current != nil && current.SecretName == secretName &&
+		current.Subject == "/CN="+loop.instance.Status.Username &&
+		current.Issuer == distinguishedName(caCertificate.RawSubject) &&
+		len(secret.Data[v1.TLSCertKey]) > 0 && bytes.Equal(secret.Data[caCertificateKey], caSecret.Data[v1.TLSCertKey])
+	if unchanged && time.Now().Before(current.RenewalTime()) {
+		return false, nil
+	}
+
+	// Keeping the current certificate when the authority cannot issue a better one
+	notBefore, notAfter, err := certificateValidity(caCertificate, loop.instance.Spec.ClientCertificate, time.Now())
+	if err == nil && unchanged && current.NotAfter != nil && !notAfter.After(current.NotAfter.Time) {
+		err = fmt.Errorf("certificate authority expires at %s, no later than the current certificate",
+			caCertificate.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		r.Log.Info("Client certificate not issued", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.instance.Status.Username, "Reason", err.Error())
+		loop.instance.Status.Message = "Client certificate not issued, " + err.Error()
+		return false, nil
+	}
+
+	certificate, certificatePEM, keyPEM, err := signClientCertificate(caCertificate, caKey,
+		loop.instance.Status.Username, loop.instance.Spec.ClientCertificate, notBefore, notAfter)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: loop.instance.Namespace,
+			},
+			Type: v1.SecretTypeTLS,
+		}
+	}
+	secret.Data = map[string][]byte{
+		v1.TLSCertKey:       certificatePEM,
+		v1.TLSPrivateKeyKey: keyPEM,
+		caCertificateKey:    caSecret.Data[v1.TLSCertKey],
+	}
+	err = controllerutil.SetControllerReference(loop.instance, secret, r.Scheme)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		err = r.Update(ctx, secret)
+	} else {
+		err = r.Create(ctx, secret)
+	}
+	if err != nil {
+		r.Log.Error(err, "Failure writing the client certificate.", "Name", secretName,
+			"Namespace", loop.instance.Namespace)
+		return false, err
+	}
+
+	issuedNotBefore := metav1.NewTime(certificate.NotBefore)
+	issuedNotAfter := metav1.NewTime(certificate.NotAfter)
+	loop.instance.Status.ClientCertificate = &mysqlv1alpha1.ClientCertificateStatus{
+		SecretName:   secretName,
+		Subject:      distinguishedName(certificate.RawSubject),
+		Issuer:       distinguishedName(certificate.RawIssuer),
+		SerialNumber: certificate.SerialNumber.Text(16),
+		NotBefore:    &issuedNotBefore,
+		NotAfter:     &issuedNotAfter,
+	}
+	loop.instance.Status.Message = "Client certificate issued"
+	r.Log.Info("Issued client certificate", "Host", loop.adminConnection.Spec.Host,
+		"Name", loop.instance.Status.Username, "Secret", secretName, "NotAfter", certificate.NotAfter)
+	return true, nil
+}
+
+// parseCertificateAuthority The certificate and private key of a CA key pair Secret.
+func parseCertificateAuthority(secret *v1.Secret) (*x509.Certificate, crypto.Signer, error) {
+
+	keyPair, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !certificate.IsCA {
+		return nil, nil, fmt.Errorf("certificate is not a certificate authority")
+	}
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("private key cannot sign")
+	}
+	return certificate, key, nil
+}
+
+// certificateValidity The validity of a certificate issued now, ending no later than the certificate authority. An
+// authority which has expired, or is past two thirds of its own validity and due to be replaced, is refused.
+func certificateValidity(ca *x509.Certificate, spec *mysqlv1alpha1.ClientCertificate,
+	now time.Time) (time.Time, time.Time, error) {
+
+	expiry := ca.NotAfter.UTC().Format(time.RFC3339)
+	if !now.Before(ca.NotAfter) {
+		return time.Time{}, time.Time{}, fmt.Errorf("certificate authority expired at %s", expiry)
+	}
+	if !now.Before(ca.NotBefore.Add(ca.NotAfter.Sub(ca.NotBefore) * 2 / 3)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("certificate authority expires at %s and is due to be replaced",
+			expiry)
+	}
+
+	validity := spec.Validity.Duration
+	if validity <= 0 {
+		validity = defaultCertificateValidity
+	}
+	// Back dated a little for servers with a slow clock
+	notBefore := now.Add(-time.Minute).Truncate(time.Second)
+	notAfter := notBefore.Add(validity)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+	return notBefore, notAfter, nil
+}
+
+// signClientCertificate A new key and client certificate for the user name, PEM encoded, valid between the times
+// given.
+func signClientCertificate(ca *x509.Certificate, caKey crypto.Signer, username string,
+	spec *mysqlv1alpha1.ClientCertificate, notBefore time.Time, notAfter time.Time) (*x509.Certificate, []byte,
+	[]byte, error) {
+
+	var key crypto.Signer
+	var err error
+	keyUsage := x509.KeyUsageDigitalSignature
+	if spec.KeyType == mysqlv1alpha1.KeyTypeRSA {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	} else {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: username},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     keyUsage,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return certificate, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// distinguishedName A DER encoded name the way the server prints it, e.g. /C=US/O=Example/CN=myuser.
+func distinguishedName(raw []byte) string {
+
+	var sequence pkix.RDNSequence
+	if _, err := asn1.Unmarshal(raw, &sequence); err != nil {
+		return ""
+	}
+	var name strings.Builder
+	for _, rdn := range sequence {
+		for _, attribute := range rdn {
+			short, ok := attributeNames[attribute.Type.String()]
+			if !ok {
+				short = attribute.Type.String()
+			}
+			name.WriteString("/" + short + "=" + fmt.Sprint(attribute.Value))
+		}
+	}
+	return name.String()
+}
+
+// certificateRequeue How long until the client certificate is renewed, 0 without one. Once overdue the
+// authority could not renew it and it is tried again later.
+func certificateRequeue(user *mysqlv1alpha1.DatabaseUser) time.Duration {
+
+	if user.Spec.ClientCertificate == nil || user.Status.ClientCertificate == nil {
+		return 0
+	}
+	wait := time.Until(user.Status.ClientCertificate.RenewalTime())
+	if wait <= 0 {
+		return certificateRetryInterval
+	}
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
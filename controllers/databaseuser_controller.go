@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/cuppett/mysql-dba-operator/orm"
 	"gorm.io/gorm"
@@ -25,10 +26,11 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"reflect"
+	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
 	"strings"
 	"time"
 
@@ -44,6 +46,9 @@ const (
 	userFinalizer = "mysql.apps.cuppett.dev/user-finalizer"
 )
 
+var schemaGrantRegEx = regexp.MustCompile("ON `((?:[^`]|``)+)`\\.\\* TO ")
+var unescapedWildcardRegEx = regexp.MustCompile(`(^|[^\\])[_%]`)
+
 // DatabaseUserReconciler reconciles a DatabaseUser object
 type DatabaseUserReconciler struct {
 	client.Client
@@ -58,6 +63,17 @@ type UserLoopContext struct {
 	adminConnection *mysqlv1alpha1.AdminConnection
 	secret          *v1.Secret
 	db              *gorm.DB
+	// The user name on the server, see AdminConnection.Username
+	username string
+	// The hosts the accounts should exist on, see DatabaseUserSpec.EffectiveHosts
+	hosts []string
+	// The account as found in mysql.user
+	account *orm.MySqlUser
+	// Whether the pending statement locks or unlocks the account
+	locking   bool
+	unlocking bool
+	// Whether the pending statement keeps the previous password as secondary
+	retaining bool
 }
 
 // +kubebuilder:rbac:groups=mysql.apps.cuppett.dev,resources=databaseusers,verbs=get;list;watch;create;update;patch;delete
@@ -91,11 +107,13 @@ func (r *DatabaseUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		r.Log.Error(err, "Failed to get Database")
 		return ctrl.Result{}, err
 	}
+	loop.hosts = loop.instance.Spec.EffectiveHosts()
 
 	// Getting admin connection
 	var adminErr error
 	loop.adminConnection, adminErr = mysqlv1alpha1.GetAdminConnection(ctx, r.Client, loop.instance.Namespace, loop.instance.Spec.AdminConnection)
 	if adminErr == nil && loop.adminConnection != nil {
+		loop.username = loop.adminConnection.Username(loop.instance)
 		// Grabbing actual database connection here.
 		loop.db, adminErr = loop.adminConnection.GetDatabaseConnection(ctx, r.Client, r.Connections)
 		if adminErr != nil {
@@ -163,7 +181,8 @@ func (r *DatabaseUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	if loop.instance.Status.Username == "" && loop.adminConnection.UserMine(loop.db, loop.instance) {
 		// Ensuring old/new username is always set.
-		loop.instance.Status.Username = loop.instance.Spec.Username
+		loop.instance.Status.Username = loop.username
+		loop.instance.Status.UsernameTemplate = loop.adminConnection.Spec.UsernameTemplate
 		err = r.Status().Update(ctx, loop.instance)
 	} else if !r.secretOwnershipOk(&loop) {
 		err = controllerutil.SetControllerReference(loop.instance, loop.secret, r.Scheme)
@@ -185,6 +204,13 @@ func (r *DatabaseUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			r.Log.Error(err, "Failure adding the finalizer.", "Name",
 				loop.instance.Name, "Namespace", loop.instance.Namespace)
 		}
+	} else if privilegeErr := loop.instance.CheckPrivileges(
+		orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion)); privilegeErr != nil {
+		// Nothing is granted until the privileges are corrected
+		r.Log.Error(privilegeErr, "Invalid privileges requested.", "Name", loop.instance.Name,
+			"Namespace", loop.instance.Namespace)
+		loop.instance.Status.Message = "Invalid privileges, " + privilegeErr.Error()
+		err = r.Status().Update(ctx, loop.instance)
 	} else if loop.instance.Status.Username != "" {
 		exists, err := r.userExists(&loop)
 
@@ -211,7 +237,14 @@ func (r *DatabaseUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		loop.instance.Status.Message = "Invalid username specified."
 		err = r.Status().Update(ctx, loop.instance)
 	}
-	return ctrl.Result{}, err
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	requeue := rotationRequeue(loop.instance)
+	if renewal := certificateRequeue(loop.instance); renewal > 0 && (requeue == 0 || renewal < requeue) {
+		requeue = renewal
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
 }
 
 func (r *DatabaseUserReconciler) createSecret(ctx context.Context, client client.Client, namespace string,
@@ -250,7 +283,12 @@ func (r *DatabaseUserReconciler) secretOwnershipOk(loop *UserLoopContext) bool {
 
 func (r *DatabaseUserReconciler) userExists(loop *UserLoopContext) (bool, error) {
 
-	user := orm.UserExists(loop.db, loop.instance.Status.Username)
+	var user *orm.MySqlUser
+	for _, host := range loop.instance.Status.CurrentHosts() {
+		if user = orm.UserExists(loop.db, loop.instance.Status.Username, host); user != nil {
+			break
+		}
+	}
 
 	if user == nil {
 		r.Log.Info("User does not exist or failed retrieving", "Host", loop.adminConnection.Spec.Host,
@@ -258,6 +296,18 @@ func (r *DatabaseUserReconciler) userExists(loop *UserLoopContext) (bool, error)
 		return false, nil
 	}
 
+	loop.account = user
+	loop.instance.Status.PasswordPolicy = accountPasswordPolicy(user)
+	loop.instance.Status.TlsOptions = accountTlsOptions(user)
+	loop.instance.Status.TlsRequirement = loop.instance.Status.TlsOptions.Requirement()
+	loop.instance.Status.Locked = user.AccountLocked == "Y"
+	if !loop.instance.Status.Locked {
+		loop.instance.Status.LockTime = nil
+	} else if loop.instance.Status.LockTime == nil {
+		// Locked outside the operator, we can only tell it was before now
+		now := metav1.Now()
+		loop.instance.Status.LockTime = &now
+	}
 	if loop.instance.Status.Identification == nil {
 		loop.instance.Status.Identification = &mysqlv1alpha1.Identification{AuthPlugin: user.Plugin}
 	} else {
@@ -268,98 +318,186 @@ func (r *DatabaseUserReconciler) userExists(loop *UserLoopContext) (bool, error)
 
 func (r *DatabaseUserReconciler) userCreate(ctx context.Context, loop *UserLoopContext) error {
 
-	createQuery := "CREATE USER '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'"
-	params := make([]interface{}, 0)
-
-	userDetails, err := r.userDetailString(ctx, loop, true)
+	// Issuing the client certificate first so the accounts are created requiring it
+	_, err := r.userCertificate(ctx, loop)
 	if err != nil {
 		return err
 	}
-	if userDetails != "" {
-		createQuery += userDetails
+	userDetails, err := r.userDetailString(ctx, loop, true)
+	if err != nil {
+		return err
 	}
-
-	err = r.runStmt(loop, createQuery, params...)
+	err = r.createAccounts(loop, loop.hosts, userDetails)
 	if err != nil {
 		return err
 	}
 	r.Log.Info("Successfully created user", "Host", loop.adminConnection.Spec.Host,
-		"Name", loop.instance.Status.Username)
+		"Name", loop.instance.Status.Username, "Hosts", loop.hosts)
+	r.lockStatusUpdate(loop)
 
+	loop.instance.Status.Hosts = loop.hosts
 	loop.instance.Status.Grants = make([]string, 0)
 	_, err = r.grant(ctx, loop)
 
+	r.saveManagedUser(loop)
+
+	return err
+}
+
+// createAccounts Creates the user@host account for each of the hosts, each with the same identification.
+func (r *DatabaseUserReconciler) createAccounts(loop *UserLoopContext, hosts []string, userDetails string) error {
+	for _, host := range hosts {
+		err := r.runStmt(loop, "CREATE USER "+mysqlv1alpha1.Account(loop.instance.Status.Username, host)+userDetails)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveManagedUser Records the user name and hosts of the accounts this DatabaseUser manages.
+func (r *DatabaseUserReconciler) saveManagedUser(loop *UserLoopContext) {
+
 	managedUser := orm.ManagedUser{
 		Uuid:      string(loop.instance.UID),
 		Namespace: loop.instance.Namespace,
 		Name:      loop.instance.Name,
-		Username:  loop.instance.Spec.Username,
+		Username:  loop.instance.Status.Username,
 	}
+	managedUser.SetHosts(loop.instance.Status.CurrentHosts())
 
 	tx := loop.db.Save(&managedUser)
 	if tx.Error != nil {
-		r.Log.Error(tx.Error, "Failed to insert managed user record.", "Host", loop.adminConnection.Spec.Host, "Name",
-			loop.instance.Spec.Username)
+		r.Log.Error(tx.Error, "Failed to save managed user record.", "Host", loop.adminConnection.Spec.Host, "Name",
+			loop.instance.Status.Username)
 	}
 	tx.Commit()
+}
 
-	return err
+// userHosts Drops the accounts on hosts no longer wanted and creates those on new ones.
+func (r *DatabaseUserReconciler) userHosts(ctx context.Context, loop *UserLoopContext) (bool, error) {
+
+	current := loop.instance.Status.CurrentHosts()
+	removed := make([]string, 0)
+	for _, host := range current {
+		if !contains(loop.hosts, host) {
+			removed = append(removed, host)
+		}
+	}
+	added := make([]string, 0)
+	for _, host := range loop.hosts {
+		if !contains(current, host) {
+			added = append(added, host)
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return false, nil
+	}
+
+	if len(added) > 0 {
+		userDetails, err := r.userDetailString(ctx, loop, true)
+		if err != nil {
+			return false, err
+		}
+		err = r.createAccounts(loop, added, userDetails)
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(removed) > 0 {
+		err := r.runStmt(loop, "DROP USER IF EXISTS "+mysqlv1alpha1.AccountList(loop.instance.Status.Username, removed))
+		if err != nil {
+			return false, err
+		}
+	}
+	r.Log.Info("Successfully changed user hosts", "Host", loop.adminConnection.Spec.Host,
+		"Name", loop.instance.Status.Username, "Added", added, "Removed", removed)
+	loop.instance.Status.Hosts = loop.hosts
+	loop.instance.Status.Message = "User hosts changed"
+	r.saveManagedUser(loop)
+
+	// New accounts start without privileges, granting again is harmless to the others.
+	loop.instance.Status.Grants = make([]string, 0)
+	_, err := r.grant(ctx, loop)
+	return true, err
 }
 
 func (r *DatabaseUserReconciler) userUpdate(ctx context.Context, loop *UserLoopContext) (bool, error) {
 
 	// Tolerate a user rename
-	if loop.instance.Spec.Username != loop.instance.Status.Username {
-		renameQuery := "RENAME USER '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "' TO '" +
-			mysqlv1alpha1.Escape(loop.instance.Spec.Username) + "'"
-		err := r.runStmt(loop, renameQuery)
+	if loop.username != loop.instance.Status.Username {
+		renames := make([]string, 0)
+		for _, host := range loop.instance.Status.CurrentHosts() {
+			renames = append(renames, mysqlv1alpha1.Account(loop.instance.Status.Username, host)+" TO "+
+				mysqlv1alpha1.Account(loop.username, host))
+		}
+		err := r.runStmt(loop, "RENAME USER "+strings.Join(renames, ", "))
 		if err != nil {
 			return false, err
 		}
 		r.Log.Info("Successfully renamed user", "Host", loop.adminConnection.Spec.Host,
-			"Old", loop.instance.Status.Username, "New", loop.instance.Spec.Username)
-		loop.instance.Status.Username = loop.instance.Spec.Username
+			"Old", loop.instance.Status.Username, "New", loop.username)
+		loop.instance.Status.Username = loop.username
 		loop.instance.Status.Message = "User renamed"
-
-		managedUser := orm.ManagedUser{
-			Uuid:      string(loop.instance.UID),
-			Namespace: loop.instance.Namespace,
-			Name:      loop.instance.Name,
-			Username:  loop.instance.Spec.Username,
-		}
-
-		tx := loop.db.Save(&managedUser)
-		if tx.Error != nil {
-			r.Log.Error(tx.Error, "Failed to update managed user record.", "Host", loop.adminConnection.Spec.Host, "Name",
-				loop.instance.Spec.Username)
-		}
-		tx.Commit()
+		r.saveManagedUser(loop)
 
 		return true, nil
 	}
 
+	// Issuing or renewing the client certificate ahead of requiring its subject and issuer
+	issued, err := r.userCertificate(ctx, loop)
+	if err != nil {
+		return false, err
+	}
+
 	// Determining if we need to update the user wrt their authentication
 	userDetails, err := r.userDetailString(ctx, loop, false)
 	if err != nil {
 		return false, err
 	}
 	if userDetails != "" {
-		alterQuery := "ALTER USER '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'" + userDetails
-		params := make([]interface{}, 0)
-		err = r.runStmt(loop, alterQuery, params...)
-		if err != nil {
-			return false, err
+		for _, host := range loop.instance.Status.CurrentHosts() {
+			alterQuery := "ALTER USER " + mysqlv1alpha1.Account(loop.instance.Status.Username, host) + userDetails
+			err = r.runStmt(loop, alterQuery)
+			if err != nil {
+				return false, err
+			}
 		}
 		r.Log.Info("Successfully updated user", "Host", loop.adminConnection.Spec.Host,
 			"Name", loop.instance.Status.Username)
 		loop.instance.Status.Message = "User altered"
+		r.lockStatusUpdate(loop)
+		if loop.retaining {
+			startGracePeriod(loop, mysqlv1alpha1.RotationStrategyDualPassword, mysqlv1alpha1.RotationPhaseRetaining)
+		}
+		return true, nil
+	}
+	if issued {
 		return true, nil
 	}
 
+	// Rotating the password once any change to it has been applied
+	rotated, err := r.userRotate(ctx, loop)
+	if rotated || err != nil {
+		return rotated, err
+	}
+
+	// Adding and removing hosts once the existing accounts are up-to-date
+	hostsChanged, err := r.userHosts(ctx, loop)
+	if hostsChanged || err != nil {
+		return hostsChanged, err
+	}
+
 	// Determining if we have a permissions thing and need to do something there.
+	permissions, err := r.effectivePermissions(ctx, loop)
+	if err != nil {
+		return false, err
+	}
 	permsDiff, err := r.grantStatusUpdate(loop, false)
 	// Always has GRANT USAGE as the first one. Only when we have something more complicated than
-	if err == nil && (permsDiff || !loop.instance.PermissionListEqual()) {
+	if err == nil && (permsDiff || !loop.instance.PermissionListEqualTo(permissions) ||
+		strings.Join(r.effectiveGlobalPrivileges(loop), ",") != strings.Join(loop.instance.Status.GlobalPrivileges, ",") ||
+		wildcardGrant(loop.instance.Status.Grants)) {
 		permsDiff = true
 		r.Log.Info("Permissions difference.", "Host", loop.adminConnection.Spec.Host,
 			"Name", loop.instance.Status.Username)
@@ -431,29 +569,163 @@ func (r *DatabaseUserReconciler) userDetailString(ctx context.Context, loop *Use
 					queryFragment += " '" + authString + "'"
 				}
 			}
-		}
 
-		if !reflect.DeepEqual(loop.instance.Spec.TlsOptions, loop.instance.Status.TlsOptions) {
-			if loop.instance.Spec.TlsOptions.Required {
-				queryFragment += " REQUIRE SSL"
-			} else {
-				queryFragment += " REQUIRE NONE"
+			// Sessions using the previous password keep working through the grace period of a rotation
+			if passwordUpdated && !pluginsDiff && !createUser && authString != "" &&
+				loop.instance.Spec.Identification.ClearText && loop.instance.Spec.Rotation != nil &&
+				orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion).SupportsDualPassword() {
+				queryFragment += " RETAIN CURRENT PASSWORD"
+				loop.retaining = true
 			}
 		}
 
 		// If this update pass is successful, our identification details will match.
 		loop.instance.Status.IdentificationResourceVersion = loop.secret.ResourceVersion
 		loop.instance.Status.Identification = loop.instance.Spec.Identification
-		loop.instance.Status.TlsOptions = loop.instance.Spec.TlsOptions
+	}
+
+	// Setting the TLS requirement when created, changed or drifted
+	tlsOptions := loop.instance.EffectiveTlsOptions()
+	requirement := tlsOptions.Requirement()
+	if createUser || (loop.account != nil && requirement != loop.instance.Status.TlsOptions.Requirement()) {
+		queryFragment += " REQUIRE " + requirement
+		loop.instance.Status.TlsOptions = tlsOptions
+		loop.instance.Status.TlsRequirement = requirement
+	}
+
+	// Setting the resource limits when created, changed or drifted
+	limits := loop.instance.EffectiveResourceLimits(loop.adminConnection)
+	if createUser || (loop.account != nil && !limits.EquivalentTo(accountResourceLimits(loop.account))) {
+		queryFragment += limits.WithClause()
+	}
+	loop.instance.Status.ResourceLimits = limits
+
+	// Setting the password policy when given at creation, changed or drifted
+	server := orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion)
+	policy := loop.instance.Spec.PasswordPolicy
+	if policy == nil {
+		policy = &mysqlv1alpha1.PasswordPolicy{}
+	}
+	if (createUser && loop.instance.Spec.PasswordPolicy != nil) ||
+		(loop.account != nil && !policy.EquivalentTo(loop.instance.Status.PasswordPolicy, server)) {
+		queryFragment += policy.Clause(server)
+	}
+
+	// Locking or unlocking when it differs from the account
+	if loop.instance.Spec.Locked && (createUser || (loop.account != nil && !loop.instance.Status.Locked)) {
+		queryFragment += " ACCOUNT LOCK"
+		loop.locking = true
+	} else if !loop.instance.Spec.Locked && !createUser && loop.account != nil && loop.instance.Status.Locked {
+		queryFragment += " ACCOUNT UNLOCK"
+		loop.unlocking = true
 	}
 
 	return queryFragment, nil
 }
 
+// lockStatusUpdate Records a lock or unlock carried out, disconnecting the sessions of a newly locked user when asked.
+func (r *DatabaseUserReconciler) lockStatusUpdate(loop *UserLoopContext) {
+
+	if loop.unlocking {
+		loop.instance.Status.Locked = false
+		loop.instance.Status.LockTime = nil
+		loop.instance.Status.Message = "User unlocked"
+		return
+	}
+	if !loop.locking {
+		return
+	}
+	now := metav1.Now()
+	loop.instance.Status.Locked = true
+	loop.instance.Status.LockTime = &now
+	if loop.account != nil {
+		loop.instance.Status.Message = "User locked"
+	}
+	if !loop.instance.Spec.KillSessionsOnLock {
+		return
+	}
+
+	for _, id := range orm.UserSessions(loop.db, loop.instance.Status.Username) {
+		// Sessions may end on their own in the meantime
+		tx := loop.db.Exec(fmt.Sprintf("KILL CONNECTION %d", id))
+		if tx.Error != nil {
+			r.Log.Info("Failed to kill session of locked user", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.instance.Status.Username, "Id", id, "Error", tx.Error.Error())
+		}
+	}
+}
+
+// accountResourceLimits The resource limits of the account as found in mysql.user
+func accountResourceLimits(account *orm.MySqlUser) *mysqlv1alpha1.ResourceLimits {
+	limit := func(value int64) *int32 {
+		limited := int32(value)
+		return &limited
+	}
+	return &mysqlv1alpha1.ResourceLimits{
+		MaxQueriesPerHour:     limit(account.MaxQuestions),
+		MaxUpdatesPerHour:     limit(account.MaxUpdates),
+		MaxConnectionsPerHour: limit(account.MaxConnections),
+		MaxUserConnections:    limit(account.MaxUserConnections),
+	}
+}
+
+// accountTlsOptions The REQUIRE options of the account as found in mysql.user
+func accountTlsOptions(account *orm.MySqlUser) mysqlv1alpha1.TlsOptions {
+	switch account.SslType {
+	case "ANY":
+		return mysqlv1alpha1.TlsOptions{Required: true}
+	case "X509":
+		return mysqlv1alpha1.TlsOptions{Required: true, X509: true}
+	case "SPECIFIED":
+		return mysqlv1alpha1.TlsOptions{
+			Required: true,
+			X509:     account.X509Subject != "" || account.X509Issuer != "",
+			Subject:  account.X509Subject,
+			Issuer:   account.X509Issuer,
+			Cipher:   account.SslCipher,
+		}
+	}
+	return mysqlv1alpha1.TlsOptions{}
+}
+
+// accountPasswordPolicy The password policy of the account as found in mysql.user
+func accountPasswordPolicy(account *orm.MySqlUser) *mysqlv1alpha1.PasswordPolicy {
+	days := func(value *int64) *int32 {
+		if value == nil {
+			return nil
+		}
+		days := int32(*value)
+		return &days
+	}
+	policy := &mysqlv1alpha1.PasswordPolicy{
+		ExpireIntervalDays: days(account.PasswordLifetime),
+		History:            days(account.PasswordReuseHistory),
+		ReuseIntervalDays:  days(account.PasswordReuseTime),
+	}
+	if account.PasswordRequireCurrent != nil {
+		requireCurrent := *account.PasswordRequireCurrent == "Y"
+		policy.RequireCurrent = &requireCurrent
+	}
+	if account.UserAttributes != nil {
+		var attributes struct {
+			PasswordLocking *struct {
+				FailedLoginAttempts  int32 `json:"failed_login_attempts"`
+				PasswordLockTimeDays int32 `json:"password_lock_time_days"`
+			} `json:"Password_locking"`
+		}
+		if json.Unmarshal([]byte(*account.UserAttributes), &attributes) == nil && attributes.PasswordLocking != nil {
+			policy.FailedLoginAttempts = &attributes.PasswordLocking.FailedLoginAttempts
+			policy.LockTimeDays = &attributes.PasswordLocking.PasswordLockTimeDays
+		}
+	}
+	return policy
+}
+
 func (r *DatabaseUserReconciler) revoke(loop *UserLoopContext) (bool, error) {
 
 	var err error
-	revokeQuery := "REVOKE ALL PRIVILEGES, GRANT OPTION FROM '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'"
+	revokeQuery := "REVOKE ALL PRIVILEGES, GRANT OPTION FROM " +
+		mysqlv1alpha1.AccountList(loop.instance.Status.Username, loop.instance.Status.CurrentHosts())
 
 	err = r.runStmt(loop, revokeQuery)
 	if err != nil {
@@ -463,9 +735,65 @@ func (r *DatabaseUserReconciler) revoke(loop *UserLoopContext) (bool, error) {
 		return false, err
 	}
 	loop.instance.Status.DatabaseList = make([]mysqlv1alpha1.DatabasePermission, 0)
+	loop.instance.Status.GlobalPrivileges = nil
 	return r.grantStatusUpdate(loop, true)
 }
 
+// effectivePermissions The spec permissions less any privileges the Databases are withholding (e.g. over quota).
+// Databases withholding every requested privilege are left out entirely.
+func (r *DatabaseUserReconciler) effectivePermissions(ctx context.Context,
+	loop *UserLoopContext) ([]mysqlv1alpha1.DatabasePermission, error) {
+
+	var databaseName types.NamespacedName
+	databaseName.Namespace = loop.instance.Namespace
+
+	permissions := make([]mysqlv1alpha1.DatabasePermission, 0, len(loop.instance.Spec.DatabaseList))
+	for _, permission := range loop.instance.Spec.DatabaseList {
+		databaseName.Name = permission.Name
+		database := &mysqlv1alpha1.Database{}
+		err := r.Client.Get(ctx, databaseName, database)
+		if err != nil {
+			r.Log.Error(err, "Failure fetching database object.", "Database", databaseName)
+			return nil, err
+		}
+		effective := mysqlv1alpha1.DatabasePermission{Name: permission.Name, Grants: permission.Grants,
+			Tables: database.EffectiveTables(permission.Tables)}
+		if permission.DatabaseLevel() {
+			effective.Grants = database.EffectiveGrants(permission.Grants)
+			if effective.Grants != nil && len(effective.Grants) == 0 && len(effective.Tables) == 0 {
+				continue
+			}
+		} else if len(effective.Tables) == 0 {
+			continue
+		}
+		permissions = append(permissions, effective)
+	}
+	return permissions, nil
+}
+
+// effectiveGlobalPrivileges The global privileges of the spec the AdminConnection permits in the namespace of the
+// user, upper case and sorted. Those it does not permit are never granted.
+func (r *DatabaseUserReconciler) effectiveGlobalPrivileges(loop *UserLoopContext) []string {
+
+	server := orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion)
+	var privileges []string
+	for _, name := range loop.instance.Spec.GlobalPrivileges {
+		privilege, err := mysqlv1alpha1.ParsePrivilege(name, mysqlv1alpha1.PrivilegeLevelGlobal, server)
+		if err != nil {
+			r.Log.Error(err, "Global privilege not known", "Host", loop.adminConnection.Spec.Host,
+				"Name", loop.instance.Status.Username)
+		} else if len(loop.adminConnection.DisallowedGlobalPrivileges(loop.instance.Namespace,
+			[]string{string(privilege)})) > 0 {
+			r.Log.Info("Global privilege not permitted by the AdminConnection", "Host",
+				loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Privilege", privilege)
+		} else if !contains(privileges, string(privilege)) {
+			privileges = append(privileges, string(privilege))
+		}
+	}
+	sort.Strings(privileges)
+	return privileges
+}
+
 func (r *DatabaseUserReconciler) grant(ctx context.Context, loop *UserLoopContext) (bool, error) {
 
 	var err error
@@ -475,7 +803,12 @@ func (r *DatabaseUserReconciler) grant(ctx context.Context, loop *UserLoopContex
 	databaseName.Namespace = loop.instance.Namespace
 	database := &mysqlv1alpha1.Database{}
 
-	for _, permission := range loop.instance.Spec.DatabaseList {
+	permissions, err := r.effectivePermissions(ctx, loop)
+	if err != nil {
+		return false, err
+	}
+	server := orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion)
+	for _, permission := range permissions {
 		databaseName.Name = permission.Name
 		err = r.Client.Get(ctx, databaseName, database)
 		if err != nil {
@@ -485,28 +818,58 @@ func (r *DatabaseUserReconciler) grant(ctx context.Context, loop *UserLoopContex
 
 		// Only grant permissions to databases in the same namespace and also under management of the operator.
 		if loop.adminConnection.DatabaseMine(loop.db, database) && database.Status.Name != "" {
-			grantQuery = "GRANT "
-			if len(permission.Grants) == 0 {
-				grantQuery += "ALL"
-			} else {
-				for i, individualPermission := range permission.Grants {
-					if i > 0 {
-						grantQuery += ", "
-					}
-					grantQuery += strings.ToUpper(individualPermission)
+			accounts := mysqlv1alpha1.AccountList(loop.instance.Status.Username, loop.instance.Status.CurrentHosts())
+			grantQueries := make([]string, 0, 1+len(permission.Tables))
+			if permission.DatabaseLevel() {
+				privileges, err := mysqlv1alpha1.ParsePrivileges(permission.Grants, mysqlv1alpha1.PrivilegeLevelDatabase,
+					server)
+				if err != nil {
+					return false, fmt.Errorf("database %s: %w", permission.Name, err)
 				}
+				grantQuery = "GRANT ALL"
+				if len(privileges) > 0 {
+					grantQuery = "GRANT " + mysqlv1alpha1.JoinPrivileges(privileges)
+				}
+				grantQueries = append(grantQueries, grantQuery+" ON "+
+					mysqlv1alpha1.QuoteSchemaPattern(database.Status.Name)+".* TO "+accounts)
 			}
-			grantQuery += " ON `" + database.Status.Name + "`.* TO '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'"
-			err = r.runStmt(loop, grantQuery)
-			if err != nil {
-				r.Log.Error(err, "Failed to grant user permissions", "Host",
-					loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Query",
-					grantQuery)
+			// Table and column privileges, which need the tables to exist
+			for _, table := range permission.Tables {
+				privileges, err := table.Privileges(server)
+				if err != nil {
+					return false, fmt.Errorf("database %s table %s: %w", permission.Name, table.Name, err)
+				}
+				grantQueries = append(grantQueries, "GRANT "+privileges+" ON "+
+					mysqlv1alpha1.QuoteIdentifier(database.Status.Name)+"."+mysqlv1alpha1.QuoteIdentifier(table.Name)+
+					" TO "+accounts)
+			}
+			for _, grantQuery = range grantQueries {
+				err = r.runStmt(loop, grantQuery)
+				if err != nil {
+					r.Log.Error(err, "Failed to grant user permissions", "Host",
+						loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Query",
+						grantQuery)
+				}
 			}
 		}
 	}
 
-	loop.instance.Status.DatabaseList = loop.instance.Spec.DatabaseList
+	// Global privileges, on the first line of SHOW GRANTS in place of USAGE
+	globalPrivileges := r.effectiveGlobalPrivileges(loop)
+	if len(globalPrivileges) > 0 {
+		grantQuery = "GRANT " + strings.Join(globalPrivileges, ", ") + " ON *.* TO " +
+			mysqlv1alpha1.AccountList(loop.instance.Status.Username, loop.instance.Status.CurrentHosts())
+		err = r.runStmt(loop, grantQuery)
+		if err != nil {
+			r.Log.Error(err, "Failed to grant user global privileges", "Host",
+				loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Query",
+				grantQuery)
+			return false, err
+		}
+	}
+	loop.instance.Status.GlobalPrivileges = globalPrivileges
+
+	loop.instance.Status.DatabaseList = permissions
 	return r.grantStatusUpdate(loop, false)
 }
 
@@ -521,35 +884,41 @@ func (r *DatabaseUserReconciler) grantStatusUpdate(loop *UserLoopContext, empty
 
 	var grant string
 	var update bool
-	var results []map[string]interface{}
-
-	showQuery := "SHOW GRANTS FOR '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'"
 
 	if empty {
 		// Empty out the list. We're loading it fresh.
 		loop.instance.Status.Grants = make([]string, 0)
 	}
 
-	tx := loop.db.Raw(showQuery).Scan(&results)
-	if tx.Error != nil {
-		r.Log.Error(tx.Error, "Failed to get user grants", "Host",
-			loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Query",
-			showQuery)
-		return false, tx.Error
-	}
-
-	for i, row := range results {
-		// Drop the first one in the results.
-		// It's a useless GRANT USAGE statement.
-		// On MariaDB it includes the user's password hash.
-		if i > 0 {
-			for key := range row {
-				grant = fmt.Sprintf("%v", row[key])
-				if !contains(loop.instance.Status.Grants, grant) {
-					r.Log.Info("Existing grants do not contain this one.", "Grant", grant, "Host",
-						loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username)
-					update = true
-					loop.instance.Status.Grants = append(loop.instance.Status.Grants, grant)
+	for _, host := range loop.instance.Status.CurrentHosts() {
+		var results []map[string]interface{}
+		showQuery := "SHOW GRANTS FOR " + mysqlv1alpha1.Account(loop.instance.Status.Username, host)
+
+		tx := loop.db.Raw(showQuery).Scan(&results)
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to get user grants", "Host",
+				loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username, "Query",
+				showQuery)
+			return false, tx.Error
+		}
+
+		for i, row := range results {
+			// Drop the first one in the results.
+			// It's a useless GRANT USAGE statement, or the global privileges.
+			// On MariaDB it includes the user's password hash.
+			if i > 0 {
+				for key := range row {
+					grant = fmt.Sprintf("%v", row[key])
+					// Dynamic global privileges (MySQL 8.0) follow on a line of their own
+					if strings.Contains(grant, " ON *.* TO ") {
+						continue
+					}
+					if !contains(loop.instance.Status.Grants, grant) {
+						r.Log.Info("Existing grants do not contain this one.", "Grant", grant, "Host",
+							loop.adminConnection.Spec.Host, "Name", loop.instance.Status.Username)
+						update = true
+						loop.instance.Status.Grants = append(loop.instance.Status.Grants, grant)
+					}
 				}
 			}
 		}
@@ -558,6 +927,18 @@ func (r *DatabaseUserReconciler) grantStatusUpdate(loop *UserLoopContext, empty
 	return update, nil
 }
 
+// wildcardGrant Whether a database level grant has a schema with unescaped wildcards, made before they were
+// escaped, which also covers the schemas of other names.
+func wildcardGrant(grants []string) bool {
+	for _, grant := range grants {
+		match := schemaGrantRegEx.FindStringSubmatch(grant)
+		if match != nil && unescapedWildcardRegEx.MatchString(match[1]) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *DatabaseUserReconciler) runStmt(loop *UserLoopContext, query string, args ...interface{}) error {
 	tx := loop.db.Exec(query, args...)
 	if tx.Error != nil {
@@ -571,10 +952,18 @@ func (r *DatabaseUserReconciler) runStmt(loop *UserLoopContext, query string, ar
 // This is the finalizer which will DROP the database from the server losing all data.
 func (r *DatabaseUserReconciler) finalizeUser(loop *UserLoopContext) error {
 
-	tx := loop.db.Exec("DROP USER IF EXISTS '" + mysqlv1alpha1.Escape(loop.instance.Status.Username) + "'")
+	tx := loop.db.Exec("DROP USER IF EXISTS " +
+		mysqlv1alpha1.AccountList(loop.instance.Status.Username, loop.instance.Status.CurrentHosts()))
 	if tx.Error != nil {
 		r.Log.Error(tx.Error, "Failed to delete the user")
 	}
+	if rotation := loop.instance.Status.Rotation; rotation != nil && rotation.AlternateUsername != "" {
+		tx = loop.db.Exec("DROP USER IF EXISTS " +
+			mysqlv1alpha1.AccountList(rotation.AlternateUsername, loop.instance.Status.CurrentHosts()))
+		if tx.Error != nil {
+			r.Log.Error(tx.Error, "Failed to delete the alternate user of the rotation")
+		}
+	}
 	r.Log.Info("Successfully, deleted user", "Host", loop.adminConnection.Spec.Host,
 		"Name", loop.instance.Status.Username)
 
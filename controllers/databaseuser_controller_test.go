@@ -1,8 +1,18 @@
 package controllers
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"github.com/cuppett/mysql-dba-operator/orm"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math/big"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -436,4 +446,863 @@ var _ = Describe("DatabaseUser", func() {
 
 		}, NodeTimeout(time.Second*30))
 	})
+
+	Describe("Table Privileges Scenario", func() {
+
+		It("Grants on tables and columns only", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-tables",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			database := &Database{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-database-tables",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Name: "test-database-tables",
+				},
+			}
+			err = k8sClient.Create(ctx, database)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: database.Namespace, Name: database.Name},
+					database)
+				Expect(err).ToNot(HaveOccurred())
+				return database.Status.Message
+			}).WithContext(ctx).Should(Equal("Database in sync"))
+
+			tx := gormDB.Exec("CREATE TABLE " + QuoteIdentifier(database.Status.Name) +
+				".`orders` (id INT PRIMARY KEY, total INT, note VARCHAR(64))")
+			Expect(tx.Error).To(BeNil())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-tables",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-tables",
+					DatabaseList: []DatabasePermission{
+						{
+							Name: "test-database-tables",
+							Tables: []TablePermission{
+								{
+									Name:    "orders",
+									Grants:  []string{"SELECT"},
+									Columns: []ColumnPermission{{Privilege: "UPDATE", Columns: []string{"note"}}},
+								},
+							},
+						},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() int {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return len(userObject.Status.Grants)
+			}).WithContext(ctx).Should(Equal(1))
+
+			// Nothing on the whole database, only the table and its column
+			var count int64
+			gormDB.Model(&orm.MySqlDb{}).Where("User = ?", "test-user-tables").Count(&count)
+			Expect(count).To(Equal(int64(0)))
+			gormDB.Raw("SELECT COUNT(*) FROM mysql.tables_priv WHERE User = ? AND Table_name = ?",
+				"test-user-tables", "orders").Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+			gormDB.Raw("SELECT COUNT(*) FROM mysql.columns_priv WHERE User = ? AND Column_name = ?",
+				"test-user-tables", "note").Scan(&count)
+			Expect(count).To(Equal(int64(1)))
+
+			err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(databaseUser.Status.DatabaseList).To(Equal(databaseUser.Spec.DatabaseList))
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Wildcard Scenario", func() {
+
+		It("Grants only the schema named, not those its wildcards match", func(ctx SpecContext) {
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Two tenants whose schema names differ only in - and _
+			for name, schema := range map[string]string{
+				"test-wildcard-underscore": "test_wildcard_app",
+				"test-wildcard-dash":       "test-wildcard-app",
+			} {
+				database := &Database{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: ServerAdminConnection.Namespace,
+					},
+					Spec: DatabaseSpec{
+						AdminConnection: AdminConnectionRef{
+							Name: ServerAdminConnection.Name,
+						},
+						Name: schema,
+					},
+				}
+				Expect(k8sClient.Create(ctx, database)).To(Succeed())
+				Eventually(func() string {
+					err := k8sClient.Get(ctx, types.NamespacedName{Namespace: database.Namespace, Name: name},
+						database)
+					Expect(err).ToNot(HaveOccurred())
+					return database.Status.Message
+				}).WithContext(ctx).Should(Equal("Database in sync"))
+				Expect(gormDB.Exec("CREATE TABLE " + QuoteIdentifier(schema) + ".`orders` (id INT PRIMARY KEY)").
+					Error).To(Succeed())
+			}
+
+			password := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-wildcard",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				StringData: map[string]string{"password": "W1ldcard-Passw0rd"},
+			}
+			Expect(k8sClient.Create(ctx, password)).To(Succeed())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-wildcard",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-wildcard",
+					Identification: &Identification{
+						ClearText: true,
+						AuthString: &SecretKeySource{
+							SecretKeyRef: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "test-user-wildcard"},
+								Key:                  "password",
+							},
+						},
+					},
+					DatabaseList: []DatabasePermission{{Name: "test-wildcard-underscore"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, databaseUser)).To(Succeed())
+
+			Eventually(func() []string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Namespace: databaseUser.Namespace,
+					Name: databaseUser.Name}, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Grants
+			}).WithContext(ctx).Should(ContainElement(ContainSubstring("ON `test\\_wildcard\\_app`.* TO")))
+
+			conn, err := ServerAdminConnection.GetUserConnection(ctx, "test-user-wildcard", "W1ldcard-Passw0rd",
+				"test_wildcard_app")
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.ExecContext(ctx, "SELECT * FROM `test_wildcard_app`.`orders`")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = conn.ExecContext(ctx, "SELECT * FROM `test-wildcard-app`.`orders`")
+			Expect(err).To(HaveOccurred())
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Invalid Privileges Scenario", func() {
+
+		It("Executes nothing for an unknown privilege", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-injection",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The webhook is not installed here, so only the reconciler stands in the way
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-injection",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-injection",
+					DatabaseList: []DatabasePermission{
+						{
+							Name:   "test-database-grant",
+							Grants: []string{"SELECT ON *.* TO 'test-user-injection'@'%'; --"},
+						},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Message
+			}).WithContext(ctx).Should(HavePrefix("Invalid privileges, database test-database-grant: " +
+				"unknown database privilege"))
+
+			Expect(orm.UserExists(gormDB, "test-user-injection", DefaultHost)).To(BeNil())
+
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Global Privileges Scenario", func() {
+
+		It("Grants only what the AdminConnection permits", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-global",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			adminConnection := &AdminConnection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-global-policy",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: *ServerAdminConnection.Spec.DeepCopy(),
+			}
+			adminConnection.Spec.GlobalPrivilegePolicy = []GlobalPrivilegeRule{
+				{Namespaces: []string{ServerAdminConnection.Namespace}, Privileges: []string{"PROCESS"}},
+			}
+			err = k8sClient.Create(ctx, adminConnection)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-global",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: adminConnection.Name,
+					},
+					Username:         "test-user-global",
+					GlobalPrivileges: []string{"process"},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() []string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.GlobalPrivileges
+			}).WithContext(ctx).Should(Equal([]string{"PROCESS"}))
+
+			var processPriv string
+			gormDB.Raw("SELECT Process_priv FROM mysql.user WHERE User = ?", "test-user-global").Scan(&processPriv)
+			Expect(processPriv).To(Equal("Y"))
+
+			// Taking the privilege away from the namespace
+			Eventually(func() error {
+				err = k8sClient.Get(ctx, types.NamespacedName{Name: adminConnection.Name,
+					Namespace: adminConnection.Namespace}, adminConnection)
+				Expect(err).ToNot(HaveOccurred())
+				adminConnection.Spec.GlobalPrivilegePolicy = nil
+				return k8sClient.Update(ctx, adminConnection)
+			}).WithContext(ctx).Should(BeNil())
+
+			Eventually(func() string {
+				gormDB.Raw("SELECT Process_priv FROM mysql.user WHERE User = ?", "test-user-global").Scan(&processPriv)
+				return processPriv
+			}).WithContext(ctx).Should(Equal("N"))
+
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() *orm.MySqlUser {
+				return orm.UserExists(gormDB, "test-user-global", DefaultHost)
+			}).WithContext(ctx).Should(BeNil())
+			Expect(k8sClient.Delete(ctx, adminConnection)).To(Succeed())
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Hosts Scenario", func() {
+
+		It("Creates and drops an account per host", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-hosts",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-hosts",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-hosts",
+					Hosts:    []string{"10.128.%", "localhost"},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Created user"))
+
+			var hosts []string
+			gormDB.Model(&orm.MySqlUser{}).Where("User = ?", "test-user-hosts").Order("Host").Pluck("Host", &hosts)
+			Expect(hosts).To(Equal([]string{"10.128.%", "localhost"}))
+
+			// Continue to do this until we stop getting 409.
+			Eventually(func() error {
+				err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+				Expect(err).ToNot(HaveOccurred())
+				databaseUser.Spec.Hosts = []string{"10.128.%", "10.129.%"}
+				err = k8sClient.Update(ctx, databaseUser)
+				return err
+			}).WithContext(ctx).Should(BeNil())
+
+			Eventually(func() []string {
+				gormDB.Model(&orm.MySqlUser{}).Where("User = ?", "test-user-hosts").Order("Host").Pluck("Host", &hosts)
+				return hosts
+			}).WithContext(ctx).Should(Equal([]string{"10.128.%", "10.129.%"}))
+
+			err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ServerAdminConnection.UserMine(gormDB, databaseUser)).To(BeTrue())
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Resource Limits Scenario", func() {
+
+		It("Restores drifted limits", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-limits",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			maxUserConnections := int32(5)
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-limits",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username:       "test-user-limits",
+					ResourceLimits: &ResourceLimits{MaxUserConnections: &maxUserConnections},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Created user"))
+
+			account := orm.UserExists(gormDB, "test-user-limits", DefaultHost)
+			Expect(account).ToNot(BeNil())
+			Expect(account.MaxUserConnections).To(Equal(int64(5)))
+
+			// Change the limit underneath
+			tx := gormDB.Exec("ALTER USER 'test-user-limits'@'%' WITH MAX_USER_CONNECTIONS 0")
+			Expect(tx.Error).To(BeNil())
+
+			// Tickle so it gets put back via reconcile.
+			Eventually(func() error {
+				err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+				Expect(err).ToNot(HaveOccurred())
+				databaseUser.Spec.TlsOptions.Required = !databaseUser.Spec.TlsOptions.Required
+				err = k8sClient.Update(ctx, databaseUser)
+				return err
+			}).WithContext(ctx).Should(BeNil())
+
+			Eventually(func() int64 {
+				return orm.UserExists(gormDB, "test-user-limits", DefaultHost).MaxUserConnections
+			}).WithContext(ctx).Should(Equal(int64(5)))
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("TLS Scenario", func() {
+
+		It("Restores a drifted requirement", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-tls",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-tls",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-tls",
+					TlsOptions: TlsOptions{
+						Subject: "/CN=test-user-tls",
+						Issuer:  "/CN=test-ca",
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Created user"))
+
+			account := orm.UserExists(gormDB, "test-user-tls", DefaultHost)
+			Expect(account).ToNot(BeNil())
+			Expect(account.SslType).To(Equal("SPECIFIED"))
+			Expect(account.X509Subject).To(Equal("/CN=test-user-tls"))
+			Expect(account.X509Issuer).To(Equal("/CN=test-ca"))
+
+			// Drop the requirement underneath
+			tx := gormDB.Exec("ALTER USER 'test-user-tls'@'%' REQUIRE NONE")
+			Expect(tx.Error).To(BeNil())
+
+			// Tickle so it gets put back via reconcile, required is implied by the subject.
+			Eventually(func() error {
+				err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+				Expect(err).ToNot(HaveOccurred())
+				databaseUser.Spec.TlsOptions.Required = !databaseUser.Spec.TlsOptions.Required
+				err = k8sClient.Update(ctx, databaseUser)
+				return err
+			}).WithContext(ctx).Should(BeNil())
+
+			Eventually(func() string {
+				return orm.UserExists(gormDB, "test-user-tls", DefaultHost).SslType
+			}).WithContext(ctx).Should(Equal("SPECIFIED"))
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.TlsRequirement
+			}).WithContext(ctx).Should(Equal("SUBJECT '/CN=test-user-tls' AND ISSUER '/CN=test-ca'"))
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Password Policy Scenario", func() {
+
+		It("Reads back the password expiry", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-expiry",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			expireIntervalDays := int32(90)
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-expiry",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username:       "test-user-expiry",
+					PasswordPolicy: &PasswordPolicy{ExpireIntervalDays: &expireIntervalDays},
+				},
+			}
+			err := k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() *int32 {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				if userObject.Status.PasswordPolicy == nil {
+					return nil
+				}
+				return userObject.Status.PasswordPolicy.ExpireIntervalDays
+			}).WithContext(ctx).Should(Equal(&expireIntervalDays))
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Lock Scenario", func() {
+
+		It("Locks and unlocks the account", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-lock",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-lock",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-lock",
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Message
+			}).WithContext(ctx).Should(Equal("Created user"))
+
+			setLocked := func(locked bool) {
+				Eventually(func() error {
+					err = k8sClient.Get(ctx, databaseNamespacedName, databaseUser)
+					Expect(err).ToNot(HaveOccurred())
+					databaseUser.Spec.Locked = locked
+					databaseUser.Spec.KillSessionsOnLock = true
+					err = k8sClient.Update(ctx, databaseUser)
+					return err
+				}).WithContext(ctx).Should(BeNil())
+			}
+
+			setLocked(true)
+			Eventually(func() string {
+				return orm.UserExists(gormDB, "test-user-lock", DefaultHost).AccountLocked
+			}).WithContext(ctx).Should(Equal("Y"))
+			Eventually(func() bool {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.Locked && userObject.Status.LockTime != nil
+			}).WithContext(ctx).Should(BeTrue())
+
+			setLocked(false)
+			Eventually(func() string {
+				return orm.UserExists(gormDB, "test-user-lock", DefaultHost).AccountLocked
+			}).WithContext(ctx).Should(Equal("N"))
+
+		}, NodeTimeout(time.Second*30))
+	})
+
+	Describe("Rotation Scenario", func() {
+
+		It("Swaps accounts on servers without dual passwords", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-rotation",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+			server, err := orm.GetServerInfo(gormDB)
+			Expect(err).ToNot(HaveOccurred())
+			if server.SupportsDualPassword() {
+				Skip("Server rotates with dual passwords")
+			}
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-rotation",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: ServerAdminConnection.Name,
+					},
+					Username: "test-user-rotation",
+					Identification: &Identification{
+						ClearText: true,
+						AuthString: &SecretKeySource{
+							SecretKeyRef: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "test-user-rotation"},
+								Key:                  "password",
+							},
+						},
+					},
+					Rotation: &PasswordRotation{
+						Interval:    metav1.Duration{Duration: 6 * time.Second},
+						GracePeriod: metav1.Duration{Duration: 2 * time.Second},
+						UsernameKey: "username",
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			secretUsername := func() string {
+				secret := &v1.Secret{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, secret)
+				Expect(err).ToNot(HaveOccurred())
+				return string(secret.Data["username"])
+			}
+
+			// Clients are moved to a copy of the account
+			Eventually(secretUsername).WithContext(ctx).Should(Equal("test-user-rotation_r"))
+			Expect(orm.UserExists(gormDB, "test-user-rotation_r", DefaultHost)).ToNot(BeNil())
+
+			// And back to the account with the new password, the copy is dropped afterwards
+			Eventually(secretUsername).WithContext(ctx).Should(Equal("test-user-rotation"))
+			Eventually(func() *orm.MySqlUser {
+				return orm.UserExists(gormDB, "test-user-rotation_r", DefaultHost)
+			}).WithContext(ctx).Should(BeNil())
+
+		}, NodeTimeout(time.Second*60))
+	})
+
+	Describe("Client Certificate Scenario", func() {
+
+		It("Issues a certificate and requires it", func(ctx SpecContext) {
+			databaseNamespacedName := types.NamespacedName{
+				Name:      "test-user-cert",
+				Namespace: ServerAdminConnection.Namespace,
+			}
+
+			cache := make(map[types.UID]*orm.ConnectionDefinition)
+			gormDB, err := ServerAdminConnection.GetDatabaseConnection(ctx, k8sClient, cache)
+			Expect(err).ToNot(HaveOccurred())
+
+			caCertificate, caKey := newTestCertificateAuthority()
+			keyDER, err := x509.MarshalPKCS8PrivateKey(caKey)
+			Expect(err).ToNot(HaveOccurred())
+			caSecret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-client-ca",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Type: v1.SecretTypeTLS,
+				Data: map[string][]byte{
+					v1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertificate.Raw}),
+					v1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+				},
+			}
+			err = k8sClient.Create(ctx, caSecret)
+			Expect(err).ToNot(HaveOccurred())
+
+			adminConnection := &AdminConnection{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-client-ca",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: *ServerAdminConnection.Spec.DeepCopy(),
+			}
+			adminConnection.Spec.ClientCertificateAuthority = &v1.LocalObjectReference{Name: caSecret.Name}
+			err = k8sClient.Create(ctx, adminConnection)
+			Expect(err).ToNot(HaveOccurred())
+
+			databaseUser := &DatabaseUser{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-user-cert",
+					Namespace: ServerAdminConnection.Namespace,
+				},
+				Spec: DatabaseUserSpec{
+					AdminConnection: AdminConnectionRef{
+						Name: adminConnection.Name,
+					},
+					Username:          "test-user-cert",
+					ClientCertificate: &ClientCertificate{KeyType: KeyTypeECDSA},
+				},
+			}
+			err = k8sClient.Create(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() string {
+				userObject := &DatabaseUser{}
+				err := k8sClient.Get(ctx, databaseNamespacedName, userObject)
+				Expect(err).ToNot(HaveOccurred())
+				return userObject.Status.TlsRequirement
+			}).WithContext(ctx).Should(Equal("SUBJECT '/CN=test-user-cert' AND ISSUER '/O=Example/CN=Test CA'"))
+
+			account := orm.UserExists(gormDB, "test-user-cert", DefaultHost)
+			Expect(account).ToNot(BeNil())
+			Expect(account.X509Subject).To(Equal("/CN=test-user-cert"))
+			Expect(account.X509Issuer).To(Equal("/O=Example/CN=Test CA"))
+
+			secret := &v1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-user-cert-client-tls",
+				Namespace: ServerAdminConnection.Namespace}, secret)
+			Expect(err).ToNot(HaveOccurred())
+			block, _ := pem.Decode(secret.Data[v1.TLSCertKey])
+			Expect(block).ToNot(BeNil())
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(certificate.CheckSignatureFrom(caCertificate)).To(Succeed())
+
+			err = k8sClient.Delete(ctx, databaseUser)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() *orm.MySqlUser {
+				return orm.UserExists(gormDB, "test-user-cert", DefaultHost)
+			}).WithContext(ctx).Should(BeNil())
+			Expect(k8sClient.Delete(ctx, adminConnection)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, caSecret)).To(Succeed())
+
+		}, NodeTimeout(time.Second*30))
+
+		DescribeTable("Signing",
+			func(keyType KeyType, validity time.Duration, expectedValidity time.Duration) {
+				caCertificate, caKey := newTestCertificateAuthority()
+				spec := &ClientCertificate{KeyType: keyType, Validity: metav1.Duration{Duration: validity}}
+				notBefore, notAfter, err := certificateValidity(caCertificate, spec, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				certificate, certificatePEM, keyPEM, err := signClientCertificate(caCertificate, caKey, "app", spec,
+					notBefore, notAfter)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = tls.X509KeyPair(certificatePEM, keyPEM)
+				Expect(err).ToNot(HaveOccurred())
+				roots := x509.NewCertPool()
+				roots.AddCert(caCertificate)
+				_, err = certificate.Verify(x509.VerifyOptions{
+					Roots:     roots,
+					KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(distinguishedName(certificate.RawSubject)).To(Equal("/CN=app"))
+				Expect(distinguishedName(certificate.RawIssuer)).To(Equal("/O=Example/CN=Test CA"))
+				Expect(certificate.NotAfter.Sub(certificate.NotBefore)).To(BeNumerically("~", expectedValidity, time.Minute))
+			},
+			Entry("ECDSA with the default validity", KeyTypeECDSA, time.Duration(0), 90*24*time.Hour),
+			Entry("RSA", KeyTypeRSA, 24*time.Hour, 24*time.Hour),
+			Entry("Capped by the certificate authority", KeyTypeECDSA, 10*365*24*time.Hour, 365*24*time.Hour),
+		)
+
+		DescribeTable("Certificate authority validity",
+			func(issued time.Duration, remaining time.Duration, expectedError string) {
+				now := time.Now()
+				caCertificate, _ := newTestCertificateAuthorityValid(now.Add(-issued), now.Add(remaining))
+				_, notAfter, err := certificateValidity(caCertificate, &ClientCertificate{}, now)
+				if expectedError != "" {
+					Expect(err).To(MatchError(ContainSubstring(expectedError)))
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(notAfter).To(Equal(caCertificate.NotAfter))
+			},
+			Entry("Expired", 2*365*24*time.Hour, -365*24*time.Hour, "certificate authority expired"),
+			Entry("Near expiry", 300*24*time.Hour, 65*24*time.Hour, "due to be replaced"),
+			Entry("Expiring before the certificate", 24*time.Hour, 30*24*time.Hour, ""),
+		)
+
+		It("Retries a certificate the authority could not renew", func() {
+			notAfter := metav1.NewTime(time.Now().Add(-time.Hour))
+			user := &DatabaseUser{
+				Spec: DatabaseUserSpec{ClientCertificate: &ClientCertificate{}},
+				Status: DatabaseUserStatus{ClientCertificate: &ClientCertificateStatus{
+					NotBefore: &metav1.Time{Time: notAfter.Add(-90 * 24 * time.Hour)},
+					NotAfter:  &notAfter,
+				}},
+			}
+			Expect(certificateRequeue(user)).To(Equal(certificateRetryInterval))
+		})
+	})
+
+	DescribeTable("swapAccountName",
+		func(statement string, last bool, expected string) {
+			Expect(swapAccountName(statement, "app", "app_r", last)).To(Equal(expected))
+		},
+		Entry("MariaDB create", "CREATE USER `app`@`%` IDENTIFIED BY PASSWORD '*AB'", false,
+			"CREATE USER `app_r`@`%` IDENTIFIED BY PASSWORD '*AB'"),
+		Entry("MySQL 5.7 grant", "GRANT SELECT ON `app`.* TO 'app'@'%'", true,
+			"GRANT SELECT ON `app`.* TO 'app_r'@'%'"),
+		Entry("Grant on a schema of the same name", "GRANT SELECT ON `app`.* TO `app`@`%`", true,
+			"GRANT SELECT ON `app`.* TO `app_r`@`%`"),
+	)
+
+	DescribeTable("wildcardGrant",
+		func(grant string, expected bool) {
+			Expect(wildcardGrant([]string{"GRANT USAGE ON *.* TO `app`@`%`", grant})).To(Equal(expected))
+		},
+		Entry("Unescaped", "GRANT SELECT ON `team_a_app`.* TO `app`@`%`", true),
+		Entry("Percent", "GRANT SELECT ON `team%`.* TO `app`@`%`", true),
+		Entry("Escaped", "GRANT SELECT ON `team\\_a\\_app`.* TO `app`@`%`", false),
+		Entry("No wildcards", "GRANT SELECT ON `app`.* TO `app_user`@`%`", false),
+		Entry("Table", "GRANT SELECT ON `team_a_app`.`orders` TO `app`@`%`", false),
+	)
 })
+
+// newTestCertificateAuthority A self signed certificate authority lasting a year.
+func newTestCertificateAuthority() (*x509.Certificate, crypto.Signer) {
+	notBefore := time.Now().Add(-time.Minute)
+	return newTestCertificateAuthorityValid(notBefore, notBefore.Add(365*24*time.Hour))
+}
+
+// newTestCertificateAuthorityValid A self signed certificate authority valid between the times given.
+func newTestCertificateAuthorityValid(notBefore time.Time, notAfter time.Time) (*x509.Certificate, crypto.Signer) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Example"}, CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	Expect(err).ToNot(HaveOccurred())
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+	return certificate, key
+}
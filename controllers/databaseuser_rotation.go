@@ -0,0 +1,257 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
+
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+)
+
+const defaultRotationUsernameKey = "username"
+
+// userRotate Advances the scheduled password rotation of the user, returning whether a step was taken.
+//
+// With dual passwords the new password is written to the Secret, the ALTER USER applying it retains the current one
+// and DISCARD OLD PASSWORD follows the grace period. Without them clients are moved to a temporary copy of the
+// account, the password of the account changes once the grace period is over, and the copy is dropped when clients
+// have moved back after another grace period.
+func (r *DatabaseUserReconciler) userRotate(ctx context.Context, loop *UserLoopContext) (bool, error) {
+
+	if loop.instance.Spec.Rotation == nil || loop.secret == nil {
+		return false, nil
+	}
+	if loop.instance.Status.Rotation == nil {
+		loop.instance.Status.Rotation = &mysqlv1alpha1.RotationStatus{}
+	}
+	rotation := loop.instance.Status.Rotation
+	now := time.Now()
+
+	if rotation.Phase != mysqlv1alpha1.RotationPhaseIdle {
+		if rotation.PhaseEnd != nil && now.Before(rotation.PhaseEnd.Time) {
+			return false, nil
+		}
+
+		switch rotation.Phase {
+		case mysqlv1alpha1.RotationPhaseRetaining:
+			for _, host := range loop.instance.Status.CurrentHosts() {
+				err := r.runStmt(loop, "ALTER USER "+mysqlv1alpha1.Account(loop.instance.Status.Username, host)+
+					" DISCARD OLD PASSWORD")
+				if err != nil {
+					return false, err
+				}
+			}
+			loop.instance.Status.Message = "Old password discarded"
+
+		case mysqlv1alpha1.RotationPhaseMovingToAlternate:
+			// The account gets the new password once the Secret is read back
+			err := r.writeRotationSecret(ctx, loop, loop.instance.Status.Username,
+				mysqlv1alpha1.GeneratePassword(24, 1, 1, 1))
+			if err != nil {
+				return false, err
+			}
+			startGracePeriod(loop, mysqlv1alpha1.RotationStrategyAccountSwap, mysqlv1alpha1.RotationPhaseMovingBack)
+			loop.instance.Status.Message = "Password rotated"
+			return true, nil
+
+		case mysqlv1alpha1.RotationPhaseMovingBack:
+			err := r.runStmt(loop, "DROP USER IF EXISTS "+
+				mysqlv1alpha1.AccountList(rotation.AlternateUsername, loop.instance.Status.CurrentHosts()))
+			if err != nil {
+				return false, err
+			}
+			rotation.AlternateUsername = ""
+			loop.instance.Status.Message = "Alternate user dropped"
+		}
+
+		rotation.Phase = mysqlv1alpha1.RotationPhaseIdle
+		rotation.PhaseEnd = nil
+		r.Log.Info("Completed password rotation", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.instance.Status.Username, "Strategy", rotation.Strategy)
+		return true, nil
+	}
+
+	if now.Before(loop.instance.RotationDue()) {
+		return false, nil
+	}
+	started := metav1.NewTime(now)
+	rotation.LastRotationTime = &started
+
+	server := orm.ParseServerVersion(loop.adminConnection.Status.ServerVersion)
+	if server.SupportsDualPassword() {
+		// The ALTER USER applying the Secret retains the current password and starts the grace period
+		rotation.Strategy = mysqlv1alpha1.RotationStrategyDualPassword
+		err := r.writeRotationSecret(ctx, loop, loop.instance.Status.Username,
+			mysqlv1alpha1.GeneratePassword(24, 1, 1, 1))
+		if err != nil {
+			return false, err
+		}
+		loop.instance.Status.Message = "Password rotated"
+		r.Log.Info("Rotating password", "Host", loop.adminConnection.Spec.Host,
+			"Name", loop.instance.Status.Username, "Strategy", rotation.Strategy)
+		return true, nil
+	}
+
+	alternate := rotationAlternate(loop.instance.Status.Username)
+	err := r.createAlternate(loop, alternate)
+	if err != nil {
+		return false, err
+	}
+	rotation.AlternateUsername = alternate
+	err = r.writeRotationSecret(ctx, loop, alternate, "")
+	if err != nil {
+		return false, err
+	}
+	startGracePeriod(loop, mysqlv1alpha1.RotationStrategyAccountSwap, mysqlv1alpha1.RotationPhaseMovingToAlternate)
+	loop.instance.Status.Message = "Moving to alternate user"
+	r.Log.Info("Rotating password", "Host", loop.adminConnection.Spec.Host,
+		"Name", loop.instance.Status.Username, "Strategy", rotation.Strategy, "Alternate", alternate)
+	return true, nil
+}
+
+// startGracePeriod Enters a rotation phase lasting the grace period.
+func startGracePeriod(loop *UserLoopContext, strategy mysqlv1alpha1.RotationStrategy, phase mysqlv1alpha1.RotationPhase) {
+	if loop.instance.Status.Rotation == nil {
+		loop.instance.Status.Rotation = &mysqlv1alpha1.RotationStatus{}
+	}
+	end := metav1.NewTime(time.Now().Add(loop.instance.Spec.Rotation.GracePeriod.Duration))
+	loop.instance.Status.Rotation.Strategy = strategy
+	loop.instance.Status.Rotation.Phase = phase
+	loop.instance.Status.Rotation.PhaseEnd = &end
+}
+
+// writeRotationSecret Writes the username and, when given, the password for clients to the Secret.
+func (r *DatabaseUserReconciler) writeRotationSecret(ctx context.Context, loop *UserLoopContext, username string,
+	password string) error {
+
+	usernameKey := loop.instance.Spec.Rotation.UsernameKey
+	if usernameKey == "" {
+		usernameKey = defaultRotationUsernameKey
+	}
+	if loop.secret.Data == nil {
+		loop.secret.Data = make(map[string][]byte)
+	}
+	loop.secret.Data[usernameKey] = []byte(username)
+	if password != "" {
+		loop.secret.Data[loop.instance.Spec.Identification.AuthString.SecretKeyRef.Key] = []byte(password)
+	}
+	err := r.Update(ctx, loop.secret)
+	if err != nil {
+		r.Log.Error(err, "Failure writing the rotated credentials.", "Name", loop.secret.Name,
+			"Namespace", loop.secret.Namespace)
+	}
+	return err
+}
+
+// createAlternate Copies each account of the user, with its current password and privileges, to the alternate name.
+func (r *DatabaseUserReconciler) createAlternate(loop *UserLoopContext, alternate string) error {
+
+	if len(orm.UserHosts(loop.db, alternate)) > 0 {
+		return fmt.Errorf("alternate user %s for the rotation already exists", alternate)
+	}
+
+	for _, host := range loop.instance.Status.CurrentHosts() {
+		account := mysqlv1alpha1.Account(loop.instance.Status.Username, host)
+
+		statements, err := showStatements(loop, "SHOW CREATE USER "+account)
+		if err != nil {
+			return err
+		}
+		grants, err := showStatements(loop, "SHOW GRANTS FOR "+account)
+		if err != nil {
+			return err
+		}
+		for _, statement := range statements {
+			err = r.runStmt(loop, swapAccountName(statement, loop.instance.Status.Username, alternate, false))
+			if err != nil {
+				return err
+			}
+		}
+		for _, grant := range grants {
+			err = r.runStmt(loop, swapAccountName(grant, loop.instance.Status.Username, alternate, true))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// showStatements The statements returned by a SHOW CREATE USER or SHOW GRANTS.
+func showStatements(loop *UserLoopContext, query string) ([]string, error) {
+	var results []map[string]interface{}
+	tx := loop.db.Raw(query).Scan(&results)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	statements := make([]string, 0, len(results))
+	for _, row := range results {
+		for key := range row {
+			statements = append(statements, fmt.Sprintf("%v", row[key]))
+		}
+	}
+	return statements, nil
+}
+
+// swapAccountName Points a statement of SHOW CREATE USER (the first account named) or SHOW GRANTS (the last one)
+// at the alternate user name, whichever way the server quotes it.
+func swapAccountName(statement string, username string, alternate string, last bool) string {
+	quotes := [][2]string{
+		{"`" + strings.ReplaceAll(username, "`", "``") + "`@", "`" + strings.ReplaceAll(alternate, "`", "``") + "`@"},
+		{"'" + mysqlv1alpha1.Escape(username) + "'@", "'" + mysqlv1alpha1.Escape(alternate) + "'@"},
+	}
+	for _, quote := range quotes {
+		index := strings.Index(statement, quote[0])
+		if last {
+			index = strings.LastIndex(statement, quote[0])
+		}
+		if index >= 0 {
+			return statement[:index] + quote[1] + statement[index+len(quote[0]):]
+		}
+	}
+	return statement
+}
+
+// rotationAlternate The temporary user name clients move to during an AccountSwap rotation.
+func rotationAlternate(username string) string {
+	if len(username) > 30 {
+		username = username[:30]
+	}
+	return username + "_r"
+}
+
+// rotationRequeue How long until the password rotation needs attention again, 0 without a rotation schedule.
+func rotationRequeue(user *mysqlv1alpha1.DatabaseUser) time.Duration {
+
+	next := user.RotationDue()
+	if rotation := user.Status.Rotation; rotation != nil && rotation.Phase != mysqlv1alpha1.RotationPhaseIdle &&
+		rotation.PhaseEnd != nil {
+		next = rotation.PhaseEnd.Time
+	}
+	if next.IsZero() {
+		return 0
+	}
+	if wait := time.Until(next); wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	mysqlv1alpha1 "github.com/cuppett/mysql-dba-operator/api/v1alpha1"
+	"github.com/cuppett/mysql-dba-operator/orm"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// databaseTarget The Database, admin connection and server connection used by kinds acting within a Database
+type databaseTarget struct {
+	database        *mysqlv1alpha1.Database
+	adminConnection *mysqlv1alpha1.AdminConnection
+	db              *gorm.DB
+}
+
+// getDatabaseTarget Resolves a Database by name in the namespace and confirms it is created and managed by the
+// operator before anything is run inside it.
+func getDatabaseTarget(ctx context.Context, c client.Client, connections map[types.UID]*orm.ConnectionDefinition,
+	namespace string, name string) (*databaseTarget, error) {
+
+	target := &databaseTarget{database: &mysqlv1alpha1.Database{}}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, target.database)
+	if err != nil {
+		return nil, err
+	}
+
+	target.adminConnection, err = mysqlv1alpha1.GetAdminConnection(ctx, c, namespace, target.database.Spec.AdminConnection)
+	if err != nil {
+		return nil, err
+	}
+	if target.adminConnection == nil {
+		return nil, fmt.Errorf("admin connection for database %s not found", name)
+	}
+
+	target.db, err = target.adminConnection.GetDatabaseConnection(ctx, c, connections)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.database.Status.Name == "" || target.database.Status.CreationTime.IsZero() ||
+		!target.database.CloneComplete() {
+		return nil, fmt.Errorf("database %s is not yet created", name)
+	}
+	if target.database.RenameInProgress() || target.database.Status.Name != target.adminConnection.DatabaseName(target.database) {
+		return nil, fmt.Errorf("database %s is being renamed", name)
+	}
+	if !target.adminConnection.DatabaseMine(target.db, target.database) {
+		return nil, fmt.Errorf("no permission to database %s", name)
+	}
+	return target, nil
+}
+
+// managedUser Resolves a DatabaseUser by name in the namespace and confirms it is created and managed by the
+// operator on the same server as the Database.
+func managedUser(ctx context.Context, c client.Client, namespace string, name string,
+	target *databaseTarget) (*mysqlv1alpha1.DatabaseUser, error) {
+
+	user := &mysqlv1alpha1.DatabaseUser{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, user)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status.Username == "" || user.Status.CreationTime.IsZero() {
+		return nil, fmt.Errorf("user %s is not yet created", user.Name)
+	}
+	adminConnection, err := mysqlv1alpha1.GetAdminConnection(ctx, c, user.Namespace, user.Spec.AdminConnection)
+	if err != nil {
+		return nil, err
+	}
+	if adminConnection == nil || adminConnection.UID != target.adminConnection.UID {
+		return nil, fmt.Errorf("user %s is not on the same server as database %s", user.Name, target.database.Name)
+	}
+	if !adminConnection.UserMine(target.db, user) {
+		return nil, fmt.Errorf("no permission to user %s", user.Name)
+	}
+	return user, nil
+}
+
+// definerAccount An account of a DatabaseUser named as DEFINER, which must live on the same server as the
+// Database.
+func definerAccount(ctx context.Context, c client.Client, namespace string, name string,
+	target *databaseTarget) (string, error) {
+
+	user, err := managedUser(ctx, c, namespace, name, target)
+	if err != nil {
+		return "", err
+	}
+	// Any of the accounts will do, they share their identification and privileges
+	return mysqlv1alpha1.Account(user.Status.Username, user.Status.CurrentHosts()[0]), nil
+}
+
+// scriptSession A session logged in as a DatabaseUser for running SQL supplied by the namespace, so the statements
+// can do no more than the grants of the user permit.
+type scriptSession struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// openScriptSession Logs in as the DatabaseUser with the schema of the Database as the default database. The user
+// must be granted this Database and nothing else, and be identified by a clear text password the operator can read.
+func openScriptSession(ctx context.Context, c client.Client, namespace string, name string,
+	target *databaseTarget) (*scriptSession, error) {
+
+	user, err := managedUser(ctx, c, namespace, name, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.Spec.DatabaseList) != 1 || !user.Spec.ReferencesDatabase(target.database.Name) ||
+		len(user.Spec.GlobalPrivileges) > 0 {
+		return nil, fmt.Errorf("user %s must be granted database %s and nothing else", user.Name,
+			target.database.Name)
+	}
+	identification := user.Spec.Identification
+	if identification == nil || identification.AuthString == nil || !identification.ClearText {
+		return nil, fmt.Errorf("user %s has no clear text password to log in with", user.Name)
+	}
+	tlsOptions := user.EffectiveTlsOptions()
+	if requirement := tlsOptions.Requirement(); requirement != "NONE" && requirement != "SSL" {
+		return nil, fmt.Errorf("user %s requires a client certificate", user.Name)
+	}
+
+	secret, err := mysqlv1alpha1.GetSecret(ctx, c, user.Namespace, &identification.AuthString.SecretKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	password := string(secret.Data[identification.AuthString.SecretKeyRef.Key])
+	// Clients follow the account named in the Secret while a rotation swaps accounts
+	username := user.Status.Username
+	if user.Spec.Rotation != nil {
+		usernameKey := user.Spec.Rotation.UsernameKey
+		if usernameKey == "" {
+			usernameKey = defaultRotationUsernameKey
+		}
+		if secretUsername := string(secret.Data[usernameKey]); secretUsername != "" {
+			username = secretUsername
+		}
+	}
+
+	db, err := target.adminConnection.GetUserConnection(ctx, username, password, target.database.Status.Name)
+	if err != nil {
+		return nil, fmt.Errorf("logging in as user %s: %w", user.Name, err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &scriptSession{db: db, conn: conn}, nil
+}
+
+// exec Runs the statements in order, stopping at the first failure.
+func (s *scriptSession) exec(ctx context.Context, statements []string) error {
+	for _, statement := range statements {
+		_, err := s.conn.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("%w; statement: %s", err, statement)
+		}
+	}
+	return nil
+}
+
+// Close Ends the session.
+func (s *scriptSession) Close() {
+	_ = s.conn.Close()
+	_ = s.db.Close()
+}
+
+// execInSchema Runs the statements in order on a single session using the schema as the default database.
+func execInSchema(gormDB *gorm.DB, schema string, statements []string) error {
+	return gormDB.Connection(func(conn *gorm.DB) error {
+		defer conn.Exec("USE " + orm.DatabaseName)
+
+		tx := conn.Exec("USE " + mysqlv1alpha1.QuoteIdentifier(schema))
+		if tx.Error != nil {
+			return tx.Error
+		}
+		for _, statement := range statements {
+			tx = conn.Exec(statement)
+			if tx.Error != nil {
+				return fmt.Errorf("%w; statement: %s", tx.Error, statement)
+			}
+		}
+		return nil
+	})
+}
+
+// checksum SHA-256 of the content as a hex string
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitStatements Breaks a SQL script into individual statements. Quoted strings, identifiers and comments are
+// respected when looking for the delimiter, and DELIMITER lines change it as the mysql client would, so
+// routine bodies containing ';' can be written as they would be for the client.
+func splitStatements(script string) []string {
+
+	statements := make([]string, 0)
+	delimiter := ";"
+	var current strings.Builder
+
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	var quote byte
+	atLineStart := true
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		if quote != 0 {
+			current.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				current.WriteByte(script[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		if atLineStart && strings.TrimSpace(current.String()) == "" {
+			line := script[i:]
+			if end := strings.IndexByte(line, '\n'); end >= 0 {
+				line = line[:end]
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER") {
+				delimiter = fields[1]
+				current.Reset()
+				i += len(line)
+				continue
+			}
+		}
+		atLineStart = c == '\n'
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteByte(c)
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+			// Line comments are dropped
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*") && !strings.HasPrefix(script[i:], "/*!"):
+			// Block comments are dropped, executable comments kept
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter) - 1
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
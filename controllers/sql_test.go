@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL", func() {
+
+	DescribeTable("splitStatements",
+		func(script string, expected []string) {
+			Expect(splitStatements(script)).To(Equal(expected))
+		},
+		Entry("Empty script", "", []string{}),
+		Entry("Single statement without delimiter", "SELECT 1", []string{"SELECT 1"}),
+		Entry("Multiple statements", "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);\n",
+			[]string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}),
+		Entry("Delimiter inside strings", "INSERT INTO a VALUES ('x;y', \"z;\", 'it\\'s;');",
+			[]string{"INSERT INTO a VALUES ('x;y', \"z;\", 'it\\'s;')"}),
+		Entry("Delimiter inside identifiers", "CREATE TABLE `a;b` (id INT);",
+			[]string{"CREATE TABLE `a;b` (id INT)"}),
+		Entry("Comments dropped", "-- first;\n# second;\nSELECT /* inline; */ 1;",
+			[]string{"SELECT  1"}),
+		Entry("Executable comments kept", "/*!40101 SET NAMES utf8 */;", []string{"/*!40101 SET NAMES utf8 */"}),
+		Entry("Changed delimiter",
+			"DELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END//\nDELIMITER ;\nSELECT 3;",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "SELECT 3"}),
+	)
+})
@@ -19,11 +19,13 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"github.com/cuppett/mysql-dba-operator/orm"
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/types"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -61,6 +63,9 @@ func main() {
 	var connectionCache = make(map[types.UID]*orm.ConnectionDefinition)
 	var enableHTTP2 bool
 	var secureMetrics bool
+	var statisticsInterval time.Duration
+	var charsetValidation string
+	var charsetQueryServer bool
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", secureMetrics, "If the metrics endpoint should be served securely.")
@@ -69,6 +74,13 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", enableHTTP2, "If HTTP/2 should be enabled for the metrics and webhook servers.")
+	flag.DurationVar(&statisticsInterval, "statistics-interval", 5*time.Minute,
+		"How often database size statistics are refreshed from the server.")
+	flag.StringVar(&charsetValidation, "charset-validation", string(mysqlv1alpha1.CharsetValidationStrict),
+		"How Databases are admitted when their AdminConnection has not reported the server character sets, "+
+			"strict rejects them and lenient admits them with a warning for the operator to check.")
+	flag.BoolVar(&charsetQueryServer, "charset-validation-query-server", false,
+		"In strict mode, query the server for character sets when the AdminConnection has not reported them.")
 	opts := zap.Options{
 		Development: true,
 		TimeEncoder: zapcore.ISO8601TimeEncoder,
@@ -78,6 +90,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if charsetValidation != string(mysqlv1alpha1.CharsetValidationStrict) &&
+		charsetValidation != string(mysqlv1alpha1.CharsetValidationLenient) {
+		setupLog.Error(fmt.Errorf("unknown policy %q", charsetValidation), "invalid --charset-validation")
+		os.Exit(1)
+	}
+
 	disableHTTP2 := func(c *tls.Config) {
 		if enableHTTP2 {
 			return
@@ -110,15 +128,22 @@ func main() {
 	}
 
 	if err = (&controllers.DatabaseReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("Database"),
-		Scheme:      mgr.GetScheme(),
-		Connections: connectionCache,
+		Client:             mgr.GetClient(),
+		Log:                ctrl.Log.WithName("controllers").WithName("Database"),
+		Scheme:             mgr.GetScheme(),
+		Connections:        connectionCache,
+		StatisticsInterval: statisticsInterval,
+		Recorder:           mgr.GetEventRecorderFor("database-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Database")
 		os.Exit(1)
 	}
 
+	mysqlv1alpha1.ConfigureDatabaseWebhook(mysqlv1alpha1.DatabaseWebhookOptions{
+		CharsetValidation: mysqlv1alpha1.CharsetValidation(charsetValidation),
+		QueryServer:       charsetQueryServer,
+		Connections:       connectionCache,
+	})
 	if err = (&mysqlv1alpha1.Database{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Database")
 		os.Exit(1)
@@ -132,6 +157,10 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "DatabaseUser")
 		os.Exit(1)
 	}
+	if err = (&mysqlv1alpha1.DatabaseUser{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "DatabaseUser")
+		os.Exit(1)
+	}
 	if err = (&controllers.AdminConnectionReconciler{
 		Client:      mgr.GetClient(),
 		Log:         ctrl.Log.WithName("controllers").WithName("AdminConnection"),
@@ -141,6 +170,42 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "AdminConnection")
 		os.Exit(1)
 	}
+	if err = (&controllers.DatabaseMigrationReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("DatabaseMigration"),
+		Scheme:      mgr.GetScheme(),
+		Connections: connectionCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseMigration")
+		os.Exit(1)
+	}
+	if err = (&controllers.DatabaseTableReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("DatabaseTable"),
+		Scheme:      mgr.GetScheme(),
+		Connections: connectionCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseTable")
+		os.Exit(1)
+	}
+	if err = (&controllers.DatabaseRoutineReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("DatabaseRoutine"),
+		Scheme:      mgr.GetScheme(),
+		Connections: connectionCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseRoutine")
+		os.Exit(1)
+	}
+	if err = (&controllers.DatabaseEventReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("DatabaseEvent"),
+		Scheme:      mgr.GetScheme(),
+		Connections: connectionCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatabaseEvent")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
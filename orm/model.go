@@ -18,6 +18,7 @@ package orm
 
 import (
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
@@ -41,6 +42,8 @@ type ManagedUser struct {
 	Namespace string `gorm:"size:64"`
 	Name      string `gorm:"size:64"`
 	Username  string `gorm:"size:32"`
+	// Comma separated hosts of the user@host accounts created, empty for users created before hosts were tracked
+	Hosts     string `gorm:"size:1024"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -49,10 +52,48 @@ func (ManagedUser) TableName() string {
 	return DatabaseName + ".managed_users"
 }
 
+// HostList The hosts of the managed accounts, users created before hosts were tracked only have the any host.
+func (in *ManagedUser) HostList() []string {
+	if in.Hosts == "" {
+		return []string{"%"}
+	}
+	return strings.Split(in.Hosts, ",")
+}
+
+// SetHosts Records the hosts of the managed accounts.
+func (in *ManagedUser) SetHosts(hosts []string) {
+	in.Hosts = strings.Join(hosts, ",")
+}
+
+type MigrationHistory struct {
+	DatabaseUuid  string `gorm:"primaryKey;size:36"`
+	Version       string `gorm:"primaryKey;size:64"`
+	MigrationUuid string `gorm:"size:36"`
+	Checksum      string `gorm:"size:64"`
+	AppliedAt     time.Time
+}
+
+func (MigrationHistory) TableName() string {
+	return DatabaseName + ".migration_history"
+}
+
+type InitScriptRun struct {
+	DatabaseUuid string `gorm:"primaryKey;size:36"`
+	Position     int    `gorm:"primaryKey;autoIncrement:false"`
+	Checksum     string `gorm:"size:64"`
+	AppliedAt    time.Time
+}
+
+func (InitScriptRun) TableName() string {
+	return DatabaseName + ".init_script_runs"
+}
+
 type DatabaseSchema struct {
 	SchemaName          string `gorm:"size:64;column:SCHEMA_NAME"`
 	DefaultCharacterSet string `gorm:"size:64;column:DEFAULT_CHARACTER_SET_NAME"`
 	DefaultCollation    string `gorm:"size:64;column:DEFAULT_COLLATION_NAME"`
+	// YES or NO, MySQL 8.0.16+ only and empty elsewhere
+	DefaultEncryption string `gorm:"size:10;column:DEFAULT_ENCRYPTION"`
 }
 
 func (DatabaseSchema) TableName() string {
@@ -63,6 +104,25 @@ type MySqlUser struct {
 	Host   string `gorm:"primaryKey;size:255;column:Host"`
 	User   string `gorm:"primaryKey;size:32;column:User"`
 	Plugin string `gorm:"size:64;column:plugin"`
+	// Resource limits of the account, 0 is unlimited
+	MaxQuestions       int64 `gorm:"column:max_questions"`
+	MaxUpdates         int64 `gorm:"column:max_updates"`
+	MaxConnections     int64 `gorm:"column:max_connections"`
+	MaxUserConnections int64 `gorm:"column:max_user_connections"`
+	// Password policy of the account, NULL where the server default applies
+	PasswordLifetime       *int64  `gorm:"column:password_lifetime"`
+	PasswordReuseHistory   *int64  `gorm:"column:Password_reuse_history"`
+	PasswordReuseTime      *int64  `gorm:"column:Password_reuse_time"`
+	PasswordRequireCurrent *string `gorm:"column:Password_require_current"`
+	// JSON holding Password_locking on MySQL 8.0.19+
+	UserAttributes *string `gorm:"column:User_attributes"`
+	// Y when the account is locked (MySQL 5.7.6+, MariaDB 10.4.2+)
+	AccountLocked string `gorm:"column:account_locked"`
+	// REQUIRE options of the account, ssl_type is one of '', ANY, X509 or SPECIFIED
+	SslType     string `gorm:"column:ssl_type"`
+	SslCipher   string `gorm:"column:ssl_cipher"`
+	X509Issuer  string `gorm:"column:x509_issuer"`
+	X509Subject string `gorm:"column:x509_subject"`
 }
 
 func (MySqlUser) TableName() string {
@@ -89,12 +149,33 @@ func DatabaseExists(gormDB *gorm.DB, name string) *DatabaseSchema {
 	return nil
 }
 
-func UserExists(gormDB *gorm.DB, name string) *MySqlUser {
+func UserExists(gormDB *gorm.DB, name string, host string) *MySqlUser {
 	var user MySqlUser
-	gormDB.First(&MySqlUser{}, "user = ?", name).Scan(&user)
+	gormDB.First(&MySqlUser{}, "user = ? AND host = ?", name, host).Scan(&user)
 
 	if user.User != "" {
 		return &user
 	}
 	return nil
 }
+
+// UserSessions The ids of the connections of the user name from any host.
+func UserSessions(gormDB *gorm.DB, name string) []int64 {
+	var ids []int64
+	gormDB.Raw("SELECT ID FROM INFORMATION_SCHEMA.PROCESSLIST WHERE USER = ?", name).Scan(&ids)
+	return ids
+}
+
+// UserHosts The hosts of every account on the server with the user name.
+func UserHosts(gormDB *gorm.DB, name string) []string {
+	var hosts []string
+	gormDB.Model(&MySqlUser{}).Where("user = ?", name).Order("host").Pluck("host", &hosts)
+	return hosts
+}
+
+// SchemaVersion The most recently applied migration version for the database, or empty when there are none.
+func SchemaVersion(gormDB *gorm.DB, databaseUuid string) string {
+	var history MigrationHistory
+	gormDB.Where("database_uuid = ?", databaseUuid).Order("applied_at DESC").Limit(1).Find(&history)
+	return history.Version
+}
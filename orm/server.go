@@ -0,0 +1,111 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package orm
+
+import (
+	"gorm.io/gorm"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	FlavorMySQL   = "MySQL"
+	FlavorMariaDB = "MariaDB"
+)
+
+var versionRegEx = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// ServerInfo The flavor and version of the server as reported by VERSION()
+type ServerInfo struct {
+	Flavor  string
+	Version string
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+// ParseServerVersion Interprets a VERSION() string such as 8.0.36 or 11.0.2-MariaDB-1:11.0.2+maria~ubu2204
+func ParseServerVersion(version string) *ServerInfo {
+	info := &ServerInfo{Flavor: FlavorMySQL, Version: version}
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		info.Flavor = FlavorMariaDB
+	}
+	if matches := versionRegEx.FindStringSubmatch(version); matches != nil {
+		info.Major, _ = strconv.Atoi(matches[1])
+		info.Minor, _ = strconv.Atoi(matches[2])
+		info.Patch, _ = strconv.Atoi(matches[3])
+	}
+	return info
+}
+
+func GetServerInfo(gormDB *gorm.DB) (*ServerInfo, error) {
+	var version string
+	tx := gormDB.Raw("SELECT VERSION()").Scan(&version)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return ParseServerVersion(version), nil
+}
+
+// AtLeast Whether the server version is the same or newer than the one given.
+func (in *ServerInfo) AtLeast(major, minor, patch int) bool {
+	if in.Major != major {
+		return in.Major > major
+	}
+	if in.Minor != minor {
+		return in.Minor > minor
+	}
+	return in.Patch >= patch
+}
+
+func (in *ServerInfo) IsMariaDB() bool {
+	return in.Flavor == FlavorMariaDB
+}
+
+// SupportsReadOnlySchema Whether ALTER DATABASE ... READ ONLY is available (MySQL 8.0.22+)
+func (in *ServerInfo) SupportsReadOnlySchema() bool {
+	return !in.IsMariaDB() && in.AtLeast(8, 0, 22)
+}
+
+// SupportsPasswordExpire Whether accounts take PASSWORD EXPIRE (MySQL 5.7.4+, MariaDB 10.4.3+)
+func (in *ServerInfo) SupportsPasswordExpire() bool {
+	if in.IsMariaDB() {
+		return in.AtLeast(10, 4, 3)
+	}
+	return in.AtLeast(5, 7, 4)
+}
+
+// SupportsPasswordReuse Whether accounts take PASSWORD HISTORY and PASSWORD REUSE INTERVAL (MySQL 8.0.3+)
+func (in *ServerInfo) SupportsPasswordReuse() bool {
+	return !in.IsMariaDB() && in.AtLeast(8, 0, 3)
+}
+
+// SupportsPasswordRequireCurrent Whether accounts take PASSWORD REQUIRE CURRENT (MySQL 8.0.13+)
+func (in *ServerInfo) SupportsPasswordRequireCurrent() bool {
+	return !in.IsMariaDB() && in.AtLeast(8, 0, 13)
+}
+
+// SupportsFailedLoginTracking Whether accounts take FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME (MySQL 8.0.19+)
+func (in *ServerInfo) SupportsFailedLoginTracking() bool {
+	return !in.IsMariaDB() && in.AtLeast(8, 0, 19)
+}
+
+// SupportsDualPassword Whether ALTER USER takes RETAIN CURRENT PASSWORD and DISCARD OLD PASSWORD (MySQL 8.0.14+)
+func (in *ServerInfo) SupportsDualPassword() bool {
+	return !in.IsMariaDB() && in.AtLeast(8, 0, 14)
+}